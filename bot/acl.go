@@ -2,6 +2,9 @@ package bot
 
 import (
 	"log"
+	"path"
+	"sort"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/sgt-kabukiman/kabukibot/twitch"
@@ -12,6 +15,7 @@ const (
 	ACL_ALL           = "$all"
 	ACL_MODERATORS    = "$mods"
 	ACL_SUBSCRIBERS   = "$subs"
+	ACL_VIPS          = "$vips"
 	ACL_TURBO_USERS   = "$turbos"
 	ACL_TWITCH_STAFF  = "$staff"
 	ACL_TWITCH_ADMINS = "$admins"
@@ -27,14 +31,31 @@ type ACL struct {
 	log         Logger
 	db          *sqlx.DB
 	permissions permissionMap
+	denials     permissionMap           // explicit denials, which beat any grant
+	groups      map[string]usernameList // channel-defined groups, without the leading '$'
+	expiries    map[grantKey]time.Time  // only for temporary grants
+	roster      *Roster                 // who Twitch considers a moderator etc.
+	now         func() time.Time
 }
 
-func NewACL(channel string, operator string, log Logger, db *sqlx.DB) *ACL {
-	return &ACL{channel, strings.ToLower(operator), strings.ToLower(strings.TrimPrefix(operator, "#")), log, db, make(permissionMap)}
+// Denials are stored next to the grants, with the user ident prefixed by this.
+const denialPrefix = "!"
+
+type grantKey struct {
+	permission string
+	userIdent  string
+}
+
+func NewACL(channel string, operator string, log Logger, db *sqlx.DB, roster *Roster) *ACL {
+	return &ACL{channel, strings.ToLower(operator), strings.ToLower(strings.TrimPrefix(operator, "#")), log, db, make(permissionMap), make(permissionMap), make(map[string]usernameList), make(map[grantKey]time.Time), roster, time.Now}
+}
+
+func (self *ACL) setOperator(operator string) {
+	self.operator = strings.ToLower(operator)
 }
 
 func ACLGroups() []string {
-	return []string{ACL_ALL, ACL_MODERATORS, ACL_SUBSCRIBERS, ACL_TURBO_USERS, ACL_TWITCH_STAFF, ACL_TWITCH_ADMINS}
+	return []string{ACL_ALL, ACL_MODERATORS, ACL_SUBSCRIBERS, ACL_VIPS, ACL_TURBO_USERS, ACL_TWITCH_STAFF, ACL_TWITCH_ADMINS}
 }
 
 func (self *ACL) AllowedUsers(permission string) usernameList {
@@ -47,15 +68,199 @@ func (self *ACL) AllowedUsers(permission string) usernameList {
 }
 
 func (self *ACL) IsUsername(name string) bool {
+	return !strings.HasPrefix(name, "$")
+}
+
+// IsGroup tells whether the name (like "$mods") is a built-in or channel-defined group
+func (self *ACL) IsGroup(name string) bool {
 	name = strings.ToLower(name)
 
 	for _, group := range ACLGroups() {
 		if group == name {
-			return false
+			return true
 		}
 	}
 
-	return true
+	_, exists := self.groups[strings.TrimPrefix(name, "$")]
+
+	return strings.HasPrefix(name, "$") && exists
+}
+
+// Groups returns the names of all channel-defined groups (without the '$')
+func (self *ACL) Groups() []string {
+	result := make([]string, 0, len(self.groups))
+
+	for name := range self.groups {
+		result = append(result, name)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+func (self *ACL) GroupMembers(group string) (usernameList, bool) {
+	members, exists := self.groups[strings.ToLower(group)]
+
+	return members, exists
+}
+
+// Members works like GroupMembers, but also resolves $mods, $subs and $vips to
+// the users in the roster. Other built-in groups cannot be listed.
+func (self *ACL) Members(group string) (usernameList, bool) {
+	group = strings.TrimPrefix(strings.ToLower(group), "$")
+
+	switch "$" + group {
+	case ACL_MODERATORS:
+		return usernameList(self.roster.Moderators()), true
+	case ACL_SUBSCRIBERS:
+		return usernameList(self.roster.Subscribers()), true
+	case ACL_VIPS:
+		return usernameList(self.roster.VIPs()), true
+	}
+
+	return self.GroupMembers(group)
+}
+
+func (self *ACL) CreateGroup(group string) (bool, error) {
+	group = strings.ToLower(group)
+
+	// do not shadow the built-in groups
+	for _, builtIn := range ACLGroups() {
+		if builtIn == "$"+group {
+			return false, nil
+		}
+	}
+
+	if _, exists := self.groups[group]; exists {
+		return false, nil
+	}
+
+	_, err := self.db.Exec("INSERT INTO acl_groups (channel, name) VALUES (?, ?)", self.channel, group)
+	if err != nil {
+		return false, err
+	}
+
+	self.groups[group] = make(usernameList, 0)
+
+	self.log.Debug("Created ACL group %s in %s.", group, self.channel)
+
+	return true, nil
+}
+
+// DeleteGroup removes the group, its members and all permissions granted to it
+func (self *ACL) DeleteGroup(group string) (bool, error) {
+	group = strings.ToLower(group)
+
+	if _, exists := self.groups[group]; !exists {
+		return false, nil
+	}
+
+	tx, err := self.db.Beginx()
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec("DELETE FROM acl WHERE channel = ? AND user_ident IN (?, ?)", self.channel, "$"+group, denialPrefix+"$"+group)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM acl_group_members WHERE channel = ? AND group_name = ?", self.channel, group)
+	}
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM acl_groups WHERE channel = ? AND name = ?", self.channel, group)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	delete(self.groups, group)
+
+	for permission := range self.permissions {
+		self.revoke("$"+group, permission)
+	}
+
+	for permission, denied := range self.denials {
+		remaining := make(usernameList, 0, len(denied))
+
+		for _, ident := range denied {
+			if ident != "$"+group {
+				remaining = append(remaining, ident)
+			}
+		}
+
+		if len(remaining) > 0 {
+			self.denials[permission] = remaining
+		} else {
+			delete(self.denials, permission)
+		}
+	}
+
+	self.log.Debug("Deleted ACL group %s in %s.", group, self.channel)
+
+	return true, nil
+}
+
+// AddGroupMember adds a user to a group; groups cannot contain other groups
+func (self *ACL) AddGroupMember(group string, username string) (bool, error) {
+	group = strings.ToLower(group)
+	username = strings.ToLower(username)
+
+	members, exists := self.groups[group]
+	if !exists || !self.IsUsername(username) {
+		return false, nil
+	}
+
+	for _, member := range members {
+		if member == username {
+			return false, nil
+		}
+	}
+
+	_, err := self.db.Exec("INSERT INTO acl_group_members (channel, group_name, username) VALUES (?, ?, ?)", self.channel, group, username)
+	if err != nil {
+		return false, err
+	}
+
+	self.groups[group] = append(members, username)
+
+	return true, nil
+}
+
+func (self *ACL) RemoveGroupMember(group string, username string) (bool, error) {
+	group = strings.ToLower(group)
+	username = strings.ToLower(username)
+
+	members, exists := self.groups[group]
+	if !exists {
+		return false, nil
+	}
+
+	idx := -1
+
+	for i, member := range members {
+		if member == username {
+			idx = i
+			break
+		}
+	}
+
+	if idx == -1 {
+		return false, nil
+	}
+
+	_, err := self.db.Exec("DELETE FROM acl_group_members WHERE channel = ? AND group_name = ? AND username = ?", self.channel, group, username)
+	if err != nil {
+		return false, err
+	}
+
+	self.groups[group] = append(members[:idx], members[(idx+1):]...)
+
+	return true, nil
 }
 
 func (self *ACL) IsAllowed(user twitch.User, permission string) bool {
@@ -66,11 +271,83 @@ func (self *ACL) IsAllowed(user twitch.User, permission string) bool {
 		return true
 	}
 
-	allowed := self.AllowedUsers(permission)
-	if len(allowed) == 0 {
+	self.pruneExpired()
+
+	// a denial wins over any grant, no matter how specific
+	if self.IsDenied(user, permission) {
 		return false
 	}
 
+	// exact grants are the common case, so check them first
+	if self.isGranted(user, name, self.AllowedUsers(permission)) {
+		return true
+	}
+
+	// then check grants like "use_*_cmd"
+	for pattern, allowed := range self.permissions {
+		if IsPermissionPattern(pattern) && MatchesPermission(pattern, permission) && self.isGranted(user, name, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDenied tells whether the user has explicitly been denied the permission,
+// either directly or via a pattern like "use_*_cmd".
+func (self *ACL) IsDenied(user twitch.User, permission string) bool {
+	name := strings.ToLower(user.Name)
+
+	for pattern, denied := range self.denials {
+		if pattern == permission || (IsPermissionPattern(pattern) && MatchesPermission(pattern, permission)) {
+			if self.isGranted(user, name, denied) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IsOperator tells whether the user is the configured bot operator.
+func (self *ACL) IsOperator(user twitch.User) bool {
+	return strings.ToLower(user.Name) == self.operator
+}
+
+// IsTrusted tells whether the user is the operator, the channel owner or one
+// of its moderators. These users are never held back by spam protections.
+func (self *ACL) IsTrusted(user twitch.User) bool {
+	name := strings.ToLower(user.Name)
+
+	if name == self.operator || name == self.broadcaster {
+		return true
+	}
+
+	if user.Type == twitch.Moderator || user.Type == twitch.GlobalModerator {
+		return true
+	}
+
+	roles, _ := self.roster.Roles(name)
+
+	return roles.Moderator
+}
+
+// IsPermissionPattern tells whether the permission contains wildcards
+func IsPermissionPattern(permission string) bool {
+	return strings.Contains(permission, "*")
+}
+
+// MatchesPermission tells whether a permission pattern like "use_*_cmd"
+// matches the given permission
+func MatchesPermission(pattern string, permission string) bool {
+	matched, _ := path.Match(pattern, permission)
+	return matched
+}
+
+func (self *ACL) isGranted(user twitch.User, name string, allowed usernameList) bool {
+	// the user might have been constructed from just a name, so ask the roster, too
+	roles, _ := self.roster.Roles(name)
+
 	for _, ident := range allowed {
 		allowed := false
 
@@ -78,9 +355,12 @@ func (self *ACL) IsAllowed(user twitch.User, permission string) bool {
 		case ACL_ALL:
 			allowed = true
 		case ACL_MODERATORS:
-			allowed = (user.Type == twitch.Moderator) || (user.Type == twitch.GlobalModerator)
+			allowed = (user.Type == twitch.Moderator) || (user.Type == twitch.GlobalModerator) || roles.Moderator
 		case ACL_SUBSCRIBERS:
-			allowed = user.Subscriber
+			allowed = user.Subscriber || roles.Subscriber
+		case ACL_VIPS:
+			// Twitch only tells us via badges, so the roster is all we have
+			allowed = roles.VIP
 		case ACL_TURBO_USERS:
 			allowed = user.Turbo
 		case ACL_TWITCH_STAFF:
@@ -88,7 +368,17 @@ func (self *ACL) IsAllowed(user twitch.User, permission string) bool {
 		case ACL_TWITCH_ADMINS:
 			allowed = user.Type == twitch.TwitchAdmin
 		default:
-			allowed = name == ident
+			if strings.HasPrefix(ident, "$") {
+				// groups only contain usernames, so there is no need to recurse
+				for _, member := range self.groups[strings.TrimPrefix(ident, "$")] {
+					if member == name {
+						allowed = true
+						break
+					}
+				}
+			} else {
+				allowed = name == ident
+			}
 		}
 
 		if allowed {
@@ -99,49 +389,184 @@ func (self *ACL) IsAllowed(user twitch.User, permission string) bool {
 	return false
 }
 
-func (self *ACL) Allow(userIdent string, permission string) bool {
+// Expiry returns when a temporary grant ends; the second value is false for
+// permanent grants.
+func (self *ACL) Expiry(userIdent string, permission string) (time.Time, bool) {
+	expires, temporary := self.expiries[grantKey{permission, strings.ToLower(userIdent)}]
+
+	return expires, temporary
+}
+
+// Expired grants are removed lazily whenever permissions are checked instead of
+// by a background sweep. This means stale rows can linger in the database while
+// nobody uses the channel, but the ACL does not need its own goroutine and is never
+// accessed concurrently. There are usually only a handful of temporary grants, so
+// checking all of them on each access is cheap.
+func (self *ACL) pruneExpired() {
+	now := self.now()
+
+	for key, expires := range self.expiries {
+		if !now.Before(expires) {
+			_, err := self.Deny(key.userIdent, key.permission)
+			if err != nil {
+				// the row is loaded again on the next start, together with its
+				// expiry, and pruned then; so the grant can safely end right away
+				self.log.Error("Could not remove expired ACL entry from the database: %s", err.Error())
+				self.revoke(key.userIdent, key.permission)
+			}
+		}
+	}
+}
+
+func (self *ACL) Allow(userIdent string, permission string) (bool, error) {
+	return self.allow(userIdent, permission, time.Time{})
+}
+
+// AllowFor grants a permission that expires after the given duration.
+func (self *ACL) AllowFor(userIdent string, permission string, duration time.Duration) (bool, error) {
+	return self.allow(userIdent, permission, self.now().Add(duration))
+}
+
+// a zero expiry time grants the permission permanently
+func (self *ACL) allow(userIdent string, permission string, expires time.Time) (bool, error) {
 	userIdent = strings.ToLower(userIdent)
 
 	// allowing something for the owner is pointless
 	if self.broadcaster == userIdent {
-		return false
+		return false, nil
 	}
 
-	// create skeleton structure for permissions
-	_, ok := self.permissions[permission]
-	if !ok {
-		self.permissions[permission] = make(usernameList, 0)
+	// granting something again ends an explicit denial
+	lifted, err := self.Lift(userIdent, permission)
+	if err != nil {
+		return false, err
 	}
 
-	// check if the permission is already set
-	exists := false
+	exists := self.isGrantedTo(userIdent, permission)
+	key := grantKey{permission, userIdent}
+	oldExpiry, _ := self.expiries[key]
+
+	if exists && oldExpiry.Equal(expires) {
+		return lifted, nil
+	}
 
+	if exists {
+		// just turn a temporary grant into a permanent one or change its expiry
+		_, err := self.db.Exec("UPDATE acl SET expires = ? WHERE channel = ? AND permission = ? AND user_ident = ?", unixOrZero(expires), self.channel, permission, userIdent)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		_, err := self.db.Exec("INSERT INTO acl (channel, permission, user_ident, expires) VALUES (?,?,?,?)", self.channel, permission, userIdent, unixOrZero(expires))
+		if err != nil {
+			return false, err
+		}
+
+		self.permissions[permission] = append(self.permissions[permission], userIdent)
+		self.log.Debug("Allowed %s for %s in %s.", permission, userIdent, self.channel)
+	}
+
+	if expires.IsZero() {
+		delete(self.expiries, key)
+	} else {
+		self.expiries[key] = expires
+	}
+
+	return true, nil
+}
+
+func (self *ACL) Deny(userIdent string, permission string) (bool, error) {
+	userIdent = strings.ToLower(userIdent)
+
+	if !self.isGrantedTo(userIdent, permission) {
+		return false, nil
+	}
+
+	_, err := self.db.Exec("DELETE FROM acl WHERE channel = ? AND permission = ? AND user_ident = ?", self.channel, permission, userIdent)
+	if err != nil {
+		return false, err
+	}
+
+	self.revoke(userIdent, permission)
+	self.log.Debug("Denied %s for %s in %s.", permission, userIdent, self.channel)
+
+	return true, nil
+}
+
+// isGrantedTo tells whether the permission has been granted to exactly this ident
+func (self *ACL) isGrantedTo(userIdent string, permission string) bool {
 	for _, ident := range self.permissions[permission] {
 		if ident == userIdent {
-			exists = true
-			break
+			return true
 		}
 	}
 
-	if exists {
-		return false
+	return false
+}
+
+// Forbid explicitly denies a permission (or a pattern of them), so that the
+// user cannot use it even if another grant, like one for $all, allows it.
+func (self *ACL) Forbid(userIdent string, permission string) (bool, error) {
+	userIdent = strings.ToLower(userIdent)
+
+	// the owner can do anything anyway
+	if self.broadcaster == userIdent {
+		return false, nil
 	}
 
-	self.permissions[permission] = append(self.permissions[permission], userIdent)
+	for _, ident := range self.denials[permission] {
+		if ident == userIdent {
+			return false, nil
+		}
+	}
 
-	_, err := self.db.Exec("INSERT INTO acl (channel, permission, user_ident) VALUES (?,?,?)", self.channel, permission, userIdent)
+	_, err := self.db.Exec("INSERT INTO acl (channel, permission, user_ident, expires) VALUES (?,?,?,?)", self.channel, permission, denialPrefix+userIdent, 0)
 	if err != nil {
-		log.Fatal("Could not add ACL entry to the database: " + err.Error())
+		return false, err
 	}
 
-	self.log.Debug("Allowed %s for %s in %s.", permission, userIdent, self.channel)
+	self.denials[permission] = append(self.denials[permission], userIdent)
+	self.log.Debug("Forbade %s for %s in %s.", permission, userIdent, self.channel)
 
-	return true
+	return true, nil
 }
 
-func (self *ACL) Deny(userIdent string, permission string) bool {
+// Lift removes an explicit denial, without granting anything.
+func (self *ACL) Lift(userIdent string, permission string) (bool, error) {
 	userIdent = strings.ToLower(userIdent)
 
+	denied := self.denials[permission]
+	idx := -1
+
+	for i, ident := range denied {
+		if ident == userIdent {
+			idx = i
+			break
+		}
+	}
+
+	if idx == -1 {
+		return false, nil
+	}
+
+	_, err := self.db.Exec("DELETE FROM acl WHERE channel = ? AND permission = ? AND user_ident = ?", self.channel, permission, denialPrefix+userIdent)
+	if err != nil {
+		return false, err
+	}
+
+	if len(denied) > 1 {
+		self.denials[permission] = append(denied[:idx], denied[(idx+1):]...)
+	} else {
+		delete(self.denials, permission)
+	}
+
+	self.log.Debug("Lifted the denial of %s for %s in %s.", permission, userIdent, self.channel)
+
+	return true, nil
+}
+
+// revoke removes a grant from memory only
+func (self *ACL) revoke(userIdent string, permission string) bool {
 	userList, ok := self.permissions[permission]
 	if !ok {
 		return false
@@ -160,6 +585,8 @@ func (self *ACL) Deny(userIdent string, permission string) bool {
 		return false
 	}
 
+	delete(self.expiries, grantKey{permission, userIdent})
+
 	// remove element or kill list alltogether if this was the last user
 	if len(userList) > 1 {
 		self.permissions[permission] = append(userList[:idx], userList[(idx+1):]...)
@@ -167,34 +594,114 @@ func (self *ACL) Deny(userIdent string, permission string) bool {
 		delete(self.permissions, permission)
 	}
 
-	_, err := self.db.Exec("DELETE FROM acl WHERE channel = ? AND permission = ? AND user_ident = ?", self.channel, permission, userIdent)
+	return true
+}
+
+// DeletePermission removes all grants and denials of a permission. Like with
+// RenamePermission, the database is changed in a transaction together with
+// whatever update callback makes (it can be nil).
+func (self *ACL) DeletePermission(permission string, update func(*sqlx.Tx) error) error {
+	tx, err := self.db.Beginx()
 	if err != nil {
-		log.Fatal("Could not delete ACL entry from the database: " + err.Error())
+		return err
 	}
 
-	self.log.Debug("Denied %s for %s in %s.", permission, userIdent, self.channel)
+	if update != nil {
+		if err := update(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 
-	return true
-}
+	// even without grants, there can be denials (or leftovers) in the database
+	_, err = tx.Exec("DELETE FROM acl WHERE channel = ? AND permission = ?", self.channel, permission)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
-func (self *ACL) DeletePermission(permission string) {
-	_, ok := self.permissions[permission]
-	if !ok {
-		return
+	if err := tx.Commit(); err != nil {
+		return err
 	}
 
 	delete(self.permissions, permission)
+	delete(self.denials, permission)
 
-	_, err := self.db.Exec("DELETE FROM acl WHERE channel = ? AND permission = ?", self.channel, permission)
-	if err != nil {
-		log.Fatal("Could not delete ACL entries from the database: " + err.Error())
+	for key := range self.expiries {
+		if key.permission == permission {
+			delete(self.expiries, key)
+		}
 	}
 
 	self.log.Debug("Removed all %s permissions for %s.", permission, self.channel)
+
+	return nil
+}
+
+// RenamePermission moves all grants (including temporary ones) over to a new
+// permission. Grants that already existed for the new permission are replaced.
+// The database is changed in a transaction, together with whatever update
+// callback makes (it can be nil). If anything fails, nothing is changed at all.
+func (self *ACL) RenamePermission(from string, to string, update func(*sqlx.Tx) error) error {
+	tx, err := self.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if update != nil {
+		if err := update(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec("DELETE FROM acl WHERE channel = ? AND permission = ?", self.channel, to)
+	if err == nil {
+		_, err = tx.Exec("UPDATE acl SET permission = ? WHERE channel = ? AND permission = ?", to, self.channel, from)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	delete(self.permissions, to)
+	delete(self.denials, to)
+
+	for key := range self.expiries {
+		if key.permission == to {
+			delete(self.expiries, key)
+		}
+	}
+
+	if allowed, ok := self.permissions[from]; ok {
+		delete(self.permissions, from)
+		self.permissions[to] = allowed
+	}
+
+	if denied, ok := self.denials[from]; ok {
+		delete(self.denials, from)
+		self.denials[to] = denied
+	}
+
+	for key, expires := range self.expiries {
+		if key.permission == from {
+			delete(self.expiries, key)
+			self.expiries[grantKey{to, key.userIdent}] = expires
+		}
+	}
+
+	self.log.Debug("Renamed %s permissions to %s for %s.", from, to, self.channel)
+
+	return nil
 }
 
 func (self *ACL) loadData() {
-	rows, err := self.db.Query("SELECT permission, user_ident FROM acl WHERE channel = ? ORDER BY permission", self.channel)
+	rows, err := self.db.Query("SELECT permission, user_ident, expires FROM acl WHERE channel = ? ORDER BY permission", self.channel)
 	if err != nil {
 		self.log.Fatal("Could not query ACL data: %s", err.Error())
 	}
@@ -207,11 +714,21 @@ func (self *ACL) loadData() {
 
 	for rows.Next() {
 		var permission, userIdent string
+		var expires int64
 
-		if err := rows.Scan(&permission, &userIdent); err != nil {
+		if err := rows.Scan(&permission, &userIdent, &expires); err != nil {
 			log.Fatal(err)
 		}
 
+		if strings.HasPrefix(userIdent, denialPrefix) {
+			self.denials[permission] = append(self.denials[permission], strings.TrimPrefix(userIdent, denialPrefix))
+			continue
+		}
+
+		if expires > 0 {
+			self.expiries[grantKey{permission, userIdent}] = time.Unix(expires, 0)
+		}
+
 		if permission != lastPerm {
 			if lastPerm != "" {
 				self.permissions[lastPerm] = newUserList
@@ -234,4 +751,46 @@ func (self *ACL) loadData() {
 	}
 
 	self.log.Debug("Loaded %d ACL entries for %s.", rowCount, self.channel)
+
+	self.loadGroups()
+}
+
+// temporary grants store their expiry as a unix timestamp, permanent ones use 0
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.Unix()
+}
+
+type aclGroupMember struct {
+	Group    string `db:"group_name"`
+	Username string `db:"username"`
+}
+
+func (self *ACL) loadGroups() {
+	groups := make([]string, 0)
+
+	err := self.db.Select(&groups, "SELECT name FROM acl_groups WHERE channel = ? ORDER BY name", self.channel)
+	if err != nil {
+		self.log.Fatal("Could not query ACL groups: %s", err.Error())
+	}
+
+	for _, group := range groups {
+		self.groups[group] = make(usernameList, 0)
+	}
+
+	members := make([]aclGroupMember, 0)
+
+	err = self.db.Select(&members, "SELECT group_name, username FROM acl_group_members WHERE channel = ? ORDER BY username", self.channel)
+	if err != nil {
+		self.log.Fatal("Could not query ACL group members: %s", err.Error())
+	}
+
+	for _, member := range members {
+		if list, exists := self.groups[member.Group]; exists {
+			self.groups[member.Group] = append(list, member.Username)
+		}
+	}
 }
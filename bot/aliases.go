@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// aliases defined at runtime are kept in the storage under this name
+const aliasStorage = "aliases"
+
+// The AliasRegistry maps alternative names to commands, so that e.g. !announce
+// can run !say without the plugin knowing about it. Aliases are resolved
+// before plugins see a message. They either come from the configuration or are
+// defined by the operator while the bot is running; the latter win and are
+// kept in the storage.
+type AliasRegistry struct {
+	storage    *Storage
+	configured map[string]string
+	defined    map[string]string
+	mutex      sync.RWMutex
+}
+
+func NewAliasRegistry(storage *Storage) *AliasRegistry {
+	return &AliasRegistry{
+		storage:    storage,
+		configured: make(map[string]string),
+		defined:    make(map[string]string),
+	}
+}
+
+// configure replaces the aliases from the configuration
+func (self *AliasRegistry) configure(aliases map[string]string) {
+	configured := make(map[string]string)
+
+	for alias, command := range aliases {
+		configured[normalizeAlias(alias)] = normalizeAlias(command)
+	}
+
+	self.mutex.Lock()
+	self.configured = configured
+	self.mutex.Unlock()
+}
+
+// load reads the aliases defined at runtime from the storage
+func (self *AliasRegistry) load() {
+	defined := make(map[string]string)
+
+	for alias, command := range self.storage.All(aliasStorage, "") {
+		defined[alias] = command
+	}
+
+	self.mutex.Lock()
+	self.defined = defined
+	self.mutex.Unlock()
+}
+
+// Define makes the alias run the command. Aliases cannot point to other
+// aliases, so that resolving never needs more than one step.
+func (self *AliasRegistry) Define(alias string, command string) error {
+	alias = normalizeAlias(alias)
+	command = normalizeAlias(command)
+
+	if len(alias) == 0 || len(command) == 0 || alias == command {
+		return errors.New("An alias needs a name and a different command.")
+	}
+
+	if _, isAlias := self.Resolve(command); isAlias {
+		return errors.New("!" + command + " is an alias itself.")
+	}
+
+	err := self.storage.Set(aliasStorage, "", alias, command)
+	if err != nil {
+		return err
+	}
+
+	self.mutex.Lock()
+	self.defined[alias] = command
+	self.mutex.Unlock()
+
+	return nil
+}
+
+// Remove deletes an alias that has been defined at runtime; aliases from the
+// configuration stay until the configuration is changed.
+func (self *AliasRegistry) Remove(alias string) (bool, error) {
+	alias = normalizeAlias(alias)
+
+	self.mutex.RLock()
+	_, exists := self.defined[alias]
+	self.mutex.RUnlock()
+
+	if !exists {
+		return false, nil
+	}
+
+	err := self.storage.Delete(aliasStorage, "", alias)
+	if err != nil {
+		return false, err
+	}
+
+	self.mutex.Lock()
+	delete(self.defined, alias)
+	self.mutex.Unlock()
+
+	return true, nil
+}
+
+// Resolve returns the command the alias stands for.
+func (self *AliasRegistry) Resolve(alias string) (string, bool) {
+	alias = normalizeAlias(alias)
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	if command, okay := self.defined[alias]; okay {
+		return command, true
+	}
+
+	command, okay := self.configured[alias]
+
+	return command, okay
+}
+
+// Aliases returns all known aliases, sorted by their name.
+func (self *AliasRegistry) Aliases() []string {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	result := make([]string, 0, len(self.configured)+len(self.defined))
+
+	for alias := range self.configured {
+		if _, overridden := self.defined[alias]; !overridden {
+			result = append(result, alias)
+		}
+	}
+
+	for alias := range self.defined {
+		result = append(result, alias)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// rewrite replaces an aliased command at the start of the text with the
+// command it stands for; the arguments are kept as they are.
+func (self *AliasRegistry) rewrite(trigger string, text string) string {
+	if !strings.HasPrefix(text, trigger) {
+		return text
+	}
+
+	rest := strings.TrimPrefix(text, trigger)
+	name := rest
+
+	if end := strings.IndexFunc(rest, unicode.IsSpace); end >= 0 {
+		name = rest[:end]
+	}
+
+	command, okay := self.Resolve(name)
+	if !okay {
+		return text
+	}
+
+	return trigger + command + rest[len(name):]
+}
+
+// aliases and commands can be given with or without the default trigger
+func normalizeAlias(name string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), DefaultTrigger))
+}
@@ -2,6 +2,11 @@ package bot
 
 import (
 	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/sgt-kabukiman/kabukibot/twitch"
@@ -17,7 +22,18 @@ type Channel interface {
 	ACL() *ACL
 	EnablePlugin(string) bool
 	DisablePlugin(string) bool
+	ReloadPlugin(string) bool
 	Sender() Sender
+	Trigger() string
+	SetTrigger(string) bool
+	Throttle() (int, time.Duration)
+	SetThrottle(int, time.Duration)
+	DisabledCommands() []string
+	IsCommandDisabled(string) bool
+	DisableCommand(string) bool
+	EnableCommand(string) bool
+	IsModerator() bool
+	Roster() *Roster
 }
 
 type channelWorker struct {
@@ -29,8 +45,18 @@ type channelWorker struct {
 	database       *sqlx.DB
 	log            Logger
 	acl            *ACL
+	roster         *Roster
 	workers        []pluginWorkerStruct
+	workersMutex   sync.RWMutex // only needed when reading from other goroutines
 	sender         *channelSender
+	inbound        *inboundFilters
+	aliases        *AliasRegistry
+	metrics        *metrics
+	trigger        string // what commands start with, "!" by default
+	throttle       *commandThrottle
+	disabled       map[string]bool // commands that are hidden in this channel
+	botName        string
+	ownChannel     bool // the bot is always a moderator in its own channel
 }
 
 type pluginRow struct {
@@ -39,6 +65,9 @@ type pluginRow struct {
 
 func newChannelWorker(channel string, bot *Kabukibot) *channelWorker {
 	workers := make([]pluginWorkerStruct, 0)
+	botName := strings.ToLower(bot.BotUsername())
+	ownChannel := channel == "#"+botName
+	roster := NewRoster()
 
 	cw := &channelWorker{
 		channel:        channel,
@@ -48,9 +77,43 @@ func newChannelWorker(channel string, bot *Kabukibot) *channelWorker {
 		alive:          make(chan struct{}),
 		database:       bot.Database(),
 		log:            bot.Logger(),
-		acl:            NewACL(channel, bot.OpUsername(), bot.Logger(), bot.Database()),
+		metrics:        bot.metrics,
+		acl:            NewACL(channel, bot.OpUsername(), bot.Logger(), bot.Database(), roster),
+		roster:         roster,
 		workers:        nil,
-		sender:         newChannelSender(bot.twitch, channel),
+		trigger:        DefaultTrigger,
+		throttle:       newCommandThrottle(bot.now),
+		disabled:       make(map[string]bool),
+		sender:         newChannelSender(bot.limiter, bot.whispers, bot.outbound, bot.dryRun, channel, ownChannel),
+		inbound:        bot.inbound,
+		aliases:        bot.aliases,
+		botName:        botName,
+		ownChannel:     ownChannel,
+	}
+
+	cw.acl.now = bot.now
+
+	// channels can use something other than "!" for their commands
+	trigger := ""
+	bot.Database().Get(&trigger, "SELECT value FROM channel_settings WHERE channel = ? AND name = ?", channel, "trigger")
+
+	if len(trigger) > 0 {
+		cw.trigger = trigger
+	}
+
+	// channels can limit how many commands each user may send
+	limit, interval := 0, 0
+	bot.Database().Get(&limit, "SELECT value FROM channel_settings WHERE channel = ? AND name = ?", channel, "throttle_limit")
+	bot.Database().Get(&interval, "SELECT value FROM channel_settings WHERE channel = ? AND name = ?", channel, "throttle_interval")
+
+	cw.throttle.configure(limit, time.Duration(interval)*time.Second)
+
+	// channels can turn off single commands without disabling their plugin
+	disabled := make([]string, 0)
+	bot.Database().Select(&disabled, "SELECT command FROM disabled_commands WHERE channel = ?", channel)
+
+	for _, command := range disabled {
+		cw.disabled[command] = true
 	}
 
 	// find out what plugins have been enabled for the channel
@@ -75,11 +138,28 @@ func newChannelWorker(channel string, bot *Kabukibot) *channelWorker {
 		})
 	}
 
+	// plugins with a higher priority get to see messages first
+	sort.Stable(byPriority(workers))
+
 	cw.workers = workers
 
 	return cw
 }
 
+type byPriority []pluginWorkerStruct
+
+func (a byPriority) Len() int {
+	return len(a)
+}
+
+func (a byPriority) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a byPriority) Less(i, j int) bool {
+	return pluginPriority(a[i].Plugin) > pluginPriority(a[j].Plugin)
+}
+
 func (self *channelWorker) Name() string {
 	return self.channel
 }
@@ -122,6 +202,10 @@ func (self *channelWorker) Sender() Sender {
 	return self.sender
 }
 
+func (self *channelWorker) Roster() *Roster {
+	return self.roster
+}
+
 func (self *channelWorker) ACL() *ACL {
 	return self.acl
 }
@@ -133,7 +217,10 @@ func (self *channelWorker) EnablePlugin(name string) bool {
 		return false
 	}
 
+	self.workersMutex.Lock()
 	worker.Enabled = true
+	self.workersMutex.Unlock()
+
 	worker.Worker.Enable()
 
 	self.database.Exec("INSERT INTO plugin (channel, plugin) VALUES (?, ?)", self.channel, name)
@@ -148,7 +235,10 @@ func (self *channelWorker) DisablePlugin(name string) bool {
 		return false
 	}
 
+	self.workersMutex.Lock()
 	worker.Enabled = false
+	self.workersMutex.Unlock()
+
 	worker.Worker.Disable()
 
 	self.database.Exec("DELETE FROM plugin WHERE channel = ? AND plugin = ?", self.channel, name)
@@ -156,6 +246,117 @@ func (self *channelWorker) DisablePlugin(name string) bool {
 	return true
 }
 
+// ReloadPlugin makes an enabled plugin re-read its data; it returns false if
+// the plugin is not enabled.
+func (self *channelWorker) ReloadPlugin(name string) bool {
+	worker := self.findWorker(name)
+
+	if worker == nil || !worker.Enabled {
+		return false
+	}
+
+	worker.Worker.Reload()
+
+	return true
+}
+
+func (self *channelWorker) Trigger() string {
+	return self.trigger
+}
+
+func (self *channelWorker) SetTrigger(trigger string) bool {
+	if trigger == self.trigger {
+		return false
+	}
+
+	self.database.Exec("DELETE FROM channel_settings WHERE channel = ? AND name = ?", self.channel, "trigger")
+
+	if trigger != DefaultTrigger {
+		self.database.Exec("INSERT INTO channel_settings (channel, name, value) VALUES (?, ?, ?)", self.channel, "trigger", trigger)
+	}
+
+	self.trigger = trigger
+
+	return true
+}
+
+// Throttle returns how many commands a single user may send per interval. A
+// limit of zero means that commands are not throttled.
+func (self *channelWorker) Throttle() (int, time.Duration) {
+	return self.throttle.limit, self.throttle.interval
+}
+
+// SetThrottle changes the per-user command limit; use a limit of zero to turn
+// the throttle off. The interval is stored with a precision of seconds.
+func (self *channelWorker) SetThrottle(limit int, interval time.Duration) {
+	if limit <= 0 || interval < time.Second {
+		limit, interval = 0, 0
+	}
+
+	interval = interval - interval%time.Second
+
+	self.database.Exec("DELETE FROM channel_settings WHERE channel = ? AND name IN (?, ?)", self.channel, "throttle_limit", "throttle_interval")
+
+	if limit > 0 {
+		self.database.Exec("INSERT INTO channel_settings (channel, name, value) VALUES (?, ?, ?)", self.channel, "throttle_limit", strconv.Itoa(limit))
+		self.database.Exec("INSERT INTO channel_settings (channel, name, value) VALUES (?, ?, ?)", self.channel, "throttle_interval", strconv.Itoa(int(interval/time.Second)))
+	}
+
+	self.throttle.configure(limit, interval)
+}
+
+// DisabledCommands returns the sorted commands that are hidden in this channel.
+func (self *channelWorker) DisabledCommands() []string {
+	commands := make([]string, 0, len(self.disabled))
+
+	for command := range self.disabled {
+		commands = append(commands, command)
+	}
+
+	sort.Strings(commands)
+
+	return commands
+}
+
+func (self *channelWorker) IsCommandDisabled(command string) bool {
+	return self.disabled[strings.ToLower(command)]
+}
+
+// DisableCommand hides the command (without the trigger) in this channel, as
+// if no plugin provided it. It returns false if it was already disabled.
+func (self *channelWorker) DisableCommand(command string) bool {
+	command = strings.ToLower(command)
+
+	if self.disabled[command] {
+		return false
+	}
+
+	self.disabled[command] = true
+	self.database.Exec("INSERT INTO disabled_commands (channel, command) VALUES (?, ?)", self.channel, command)
+
+	return true
+}
+
+// EnableCommand makes a disabled command available again; it returns false
+// if the command was not disabled.
+func (self *channelWorker) EnableCommand(command string) bool {
+	command = strings.ToLower(command)
+
+	if !self.disabled[command] {
+		return false
+	}
+
+	delete(self.disabled, command)
+	self.database.Exec("DELETE FROM disabled_commands WHERE channel = ? AND command = ?", self.channel, command)
+
+	return true
+}
+
+// IsModerator tells whether the bot is a moderator in this channel.
+func (self *channelWorker) IsModerator() bool {
+	return self.sender.isModerator()
+}
+
 func (self *channelWorker) Input() chan<- twitch.IncomingMessage {
 	return self.inputChannel
 }
@@ -204,7 +405,22 @@ func (self *channelWorker) Work() {
 
 			// determine the plugins to hand this message to
 			switch msg := newMsg.(type) {
+			case reconfiguration:
+				self.acl.setOperator(msg.config.Operator)
+
 			case TextMessage:
+				msg.trigger = self.trigger
+				msg.normalized = self.aliases.rewrite(msg.trigger, self.inbound.apply(self.channel, msg.Text))
+				msg.hidden = self.disabled[msg.Command()]
+				self.roster.Update(msg.User, msg.Tags)
+
+				// commands by users sending too many of them are ignored, but the
+				// message still has to pass the moderation plugins
+				if len(msg.Command()) > 0 && !self.acl.IsTrusted(msg.User) && !self.throttle.allow(msg.User.Name) {
+					self.log.Debug("Dropped command %s by %s in %s, the user is being throttled.", msg.Command(), msg.User.Name, self.channel)
+					msg.hidden = true
+				}
+
 				for _, worker := range self.workers {
 					if !worker.Enabled {
 						continue
@@ -213,9 +429,17 @@ func (self *channelWorker) Work() {
 					asserted, okay := worker.Worker.(textMessageWorker)
 					if okay {
 						asserted.HandleTextMessage(&msg, self.sender.newResponder(&msg))
+
+						if msg.IsPropagationStopped() {
+							break
+						}
 					}
 				}
 
+				if msg.IsProcessed() && len(msg.Command()) > 0 {
+					self.metrics.commandUsed(self.channel, msg.Command())
+				}
+
 			case twitch.RoomStateMessage:
 				for _, worker := range self.workers {
 					if !worker.Enabled {
@@ -240,6 +464,18 @@ func (self *channelWorker) Work() {
 					}
 				}
 
+			case twitch.ClearMsgMessage:
+				for _, worker := range self.workers {
+					if !worker.Enabled {
+						continue
+					}
+
+					asserted, okay := worker.Worker.(clearMsgMessageWorker)
+					if okay {
+						asserted.HandleClearMsgMessage(&msg, self.sender)
+					}
+				}
+
 			case twitch.SubscriberNotificationMessage:
 				for _, worker := range self.workers {
 					if !worker.Enabled {
@@ -251,6 +487,58 @@ func (self *channelWorker) Work() {
 						asserted.HandleSubscriberNotificationMessage(&msg, self.sender)
 					}
 				}
+
+			case twitch.RaidMessage:
+				for _, worker := range self.workers {
+					if !worker.Enabled {
+						continue
+					}
+
+					asserted, okay := worker.Worker.(raidMessageWorker)
+					if okay {
+						asserted.HandleRaidMessage(&msg, self.sender)
+					}
+				}
+
+			case twitch.RawLineMessage:
+				for _, worker := range self.workers {
+					if !worker.Enabled {
+						continue
+					}
+
+					asserted, okay := worker.Worker.(rawLineWorker)
+					if okay {
+						asserted.HandleRawLine(&msg, self.sender)
+					}
+				}
+
+			case twitch.ModeMessage:
+				self.roster.SetModerator(msg.User, msg.Moderator)
+
+				// Twitch repeats the bot's status after every message it sends,
+				// so plugins only hear about actual changes.
+				if strings.ToLower(msg.User) == self.botName {
+					if self.ownChannel || !self.sender.setModerator(msg.Moderator) {
+						continue
+					}
+
+					if msg.Moderator {
+						self.log.Info("The bot is now a moderator in %s.", self.channel)
+					} else {
+						self.log.Info("The bot is no longer a moderator in %s.", self.channel)
+					}
+				}
+
+				for _, worker := range self.workers {
+					if !worker.Enabled {
+						continue
+					}
+
+					asserted, okay := worker.Worker.(modeMessageWorker)
+					if okay {
+						asserted.HandleModeMessage(&msg, self.sender)
+					}
+				}
 			}
 
 		case <-self.leaveSignal:
@@ -264,15 +552,78 @@ func (self *channelWorker) Work() {
 	}
 }
 
+// When leaving a channel, enabled workers are first disabled, then every worker
+// is told that we parted. The same goes for shutting down. A panicking worker
+// does not keep the others from being stopped.
 func (self *channelWorker) partWorkers() {
-	for _, worker := range self.workers {
-		worker.Worker.Part()
-	}
+	self.roster.Clear()
+	self.stopWorkers("part", func(worker PluginWorker) {
+		worker.Part()
+	})
+	self.removeLeftoverListeners()
 }
 
 func (self *channelWorker) shutdownWorkers() {
-	for _, worker := range self.workers {
-		worker.Worker.Shutdown()
+	self.stopWorkers("shutdown", func(worker PluginWorker) {
+		worker.Shutdown()
+	})
+}
+
+func (self *channelWorker) stopWorkers(reason string, stop func(PluginWorker)) {
+	for idx, worker := range self.workers {
+		if worker.Enabled && self.safely(worker.Plugin.Name(), "disable", worker.Worker.Disable) {
+			self.workersMutex.Lock()
+			self.workers[idx].Enabled = false
+			self.workersMutex.Unlock()
+		}
+
+		self.safely(worker.Plugin.Name(), reason, func() {
+			stop(worker.Worker)
+		})
+	}
+}
+
+// safely returns false if the callback panicked
+func (self *channelWorker) safely(plugin string, action string, callback func()) (okay bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			self.log.Error("Plugin %s panicked during %s in %s: %v", plugin, action, self.channel, err)
+			okay = false
+		}
+	}()
+
+	callback()
+
+	return true
+}
+
+// Workers that could not be disabled properly would still be listening, so
+// they are removed by force. Over a long uptime with many channels, such
+// leftovers would pile up otherwise.
+func (self *channelWorker) removeLeftoverListeners() {
+	self.workersMutex.Lock()
+	defer self.workersMutex.Unlock()
+
+	for idx, worker := range self.workers {
+		if !worker.Enabled {
+			continue
+		}
+
+		events := make([]string, 0)
+
+		for event, listens := range listenerTests {
+			if listens(worker.Worker) {
+				events = append(events, event)
+			}
+		}
+
+		sort.Strings(events)
+
+		if len(events) > 0 {
+			self.log.Warning("Plugin %s was still listening for %s in %s after parting, removing it.", worker.Plugin.Name(), strings.Join(events, ", "), self.channel)
+		}
+
+		self.workers[idx].Enabled = false
 	}
 }
 
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+)
+
+// The CommandRegistry keeps track of which plugin owns which command. Only
+// commands without the bot's prefix (like !quote) need to be registered, as
+// those are the ones plugins (and custom commands) can fight over.
+type CommandRegistry struct {
+	log    Logger
+	owners map[string]string
+	mutex  sync.RWMutex
+}
+
+func NewCommandRegistry(log Logger) *CommandRegistry {
+	return &CommandRegistry{log, make(map[string]string), sync.RWMutex{}}
+}
+
+// Register claims the given commands for the owner (usually the plugin's name).
+// Commands that are already owned by someone else are not taken over; each
+// collision is logged and makes Register return false.
+func (self *CommandRegistry) Register(owner string, commands ...string) bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	okay := true
+
+	for _, command := range commands {
+		command = strings.ToLower(command)
+
+		current, exists := self.owners[command]
+		if exists && current != owner {
+			self.log.Warning("The %s plugin tried to register !%s, but that command is already provided by the %s plugin.", owner, command, current)
+			okay = false
+			continue
+		}
+
+		self.owners[command] = owner
+	}
+
+	return okay
+}
+
+// Unregister releases the commands, but only those that are actually owned by the owner.
+func (self *CommandRegistry) Unregister(owner string, commands ...string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for _, command := range commands {
+		command = strings.ToLower(command)
+
+		if self.owners[command] == owner {
+			delete(self.owners, command)
+		}
+	}
+}
+
+// Owner returns who registered the command.
+func (self *CommandRegistry) Owner(command string) (string, bool) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	owner, exists := self.owners[strings.ToLower(command)]
+
+	return owner, exists
+}
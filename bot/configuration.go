@@ -3,7 +3,13 @@ package bot
 import (
 	"errors"
 	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 
+	"github.com/sgt-kabukiman/kabukibot/twitch"
 	"gopkg.in/yaml.v2"
 )
 
@@ -15,13 +21,51 @@ type Configuration struct {
 		Password string
 	}
 	Database struct {
-		DSN string `yaml:"DSN"`
+		Driver string // mysql (the default) or sqlite3
+		DSN    string `yaml:"DSN"`
 	}
 	IRC struct {
-		Host string
-		Port int
+		Host         string
+		Port         int
+		Capabilities []string // tags, commands and/or membership; all of them by default
+		IdleTimeout  int      `yaml:"idleTimeout"` // in seconds, before PINGing Twitch
 	}
+	RateLimit struct {
+		Messages  int // per interval
+		Moderator int // per interval, for channels where the bot is a moderator
+		Interval  int // in seconds
+	} `yaml:"rateLimit"`
+	WhisperLimit struct {
+		Messages          int // per interval
+		Interval          int // in seconds
+		Recipients        int // distinct users per recipient interval
+		RecipientInterval int `yaml:"recipientInterval"` // in seconds
+	} `yaml:"whisperLimit"`
+	Reconnect struct {
+		Delay    int // in seconds, doubled after each failed attempt
+		MaxDelay int `yaml:"maxDelay"` // in seconds
+	}
+	TwitchAPI struct {
+		ClientID string `yaml:"clientId"`
+		Token    string
+		BaseURL  string `yaml:"baseUrl"`  // only useful for testing
+		CacheTTL int    `yaml:"cacheTtl"` // in seconds
+	} `yaml:"twitchApi"`
+	Metrics struct {
+		Address string // host:port to serve Prometheus metrics on, disabled if empty
+	}
+	LogLevel string `yaml:"logLevel"` // debug, info, warning or error
+	// Twitch drops a message that is identical to the previous one, so repeated
+	// messages can be altered with an invisible character
+	DeduplicateMessages bool `yaml:"deduplicateMessages"`
+	// in dry-run mode, everything the bot would send is only logged
+	DryRun bool `yaml:"dryRun"`
+	// alternative names for commands, like "announce: say"; changes to them
+	// are applied when the configuration is reloaded
+	Aliases map[string]string
 	Plugins map[string]interface{}
+
+	filename string
 }
 
 func LoadConfiguration(filename string) (*Configuration, error) {
@@ -37,13 +81,183 @@ func LoadConfiguration(filename string) (*Configuration, error) {
 		return &config, errors.New("Could not load configuration file '" + filename + "'.")
 	}
 
-	if len(config.Operator) == 0 {
-		return &config, errors.New("You must configure an operator.")
+	// environment variables take precedence over the file
+	err = applyEnvironment(reflect.ValueOf(&config).Elem(), envPrefix)
+	if err != nil {
+		return &config, err
 	}
 
+	err = config.Validate()
+	if err != nil {
+		return &config, err
+	}
+
+	config.filename = filename
+
 	return &config, nil
 }
 
+// ConfigurationErrors lists everything that is wrong with a configuration, so
+// that it can be fixed in one go.
+type ConfigurationErrors []string
+
+func (self ConfigurationErrors) Error() string {
+	return "Invalid configuration: " + strings.Join(self, " ")
+}
+
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]{1,25}$`)
+
+// Validate checks that everything required to run the bot has been configured.
+func (self *Configuration) Validate() error {
+	problems := make(ConfigurationErrors, 0)
+
+	if len(self.Account.Username) == 0 {
+		problems = append(problems, "You must configure the account username.")
+	}
+
+	if !strings.HasPrefix(self.Account.Password, "oauth:") {
+		problems = append(problems, "The account password must be an OAuth token (oauth:...).")
+	}
+
+	if len(self.Database.DSN) == 0 {
+		problems = append(problems, "You must configure the database DSN.")
+	}
+
+	if driver := self.DatabaseDriver(); driver != "mysql" && driver != "sqlite3" {
+		problems = append(problems, "Unknown database driver '"+driver+"' configured.")
+	}
+
+	if len(self.Operator) == 0 {
+		problems = append(problems, "You must configure an operator.")
+	} else if !usernameRegex.MatchString(self.Operator) {
+		problems = append(problems, "The operator '"+self.Operator+"' is not a valid Twitch username.")
+	}
+
+	for _, capability := range self.IRC.Capabilities {
+		if !isKnownCapability(capability) {
+			problems = append(problems, "Unknown IRC capability '"+capability+"' configured.")
+		}
+	}
+
+	if _, okay := self.Level(); !okay && len(self.LogLevel) > 0 {
+		problems = append(problems, "Unknown log level '"+self.LogLevel+"' configured.")
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+// DatabaseDriver returns the name of the database/sql driver to connect with.
+func (self *Configuration) DatabaseDriver() string {
+	if len(self.Database.Driver) == 0 {
+		return "mysql"
+	}
+
+	return self.Database.Driver
+}
+
+// Capabilities returns the IRCv3 capabilities to request from Twitch.
+func (self *Configuration) Capabilities() []string {
+	if len(self.IRC.Capabilities) == 0 {
+		return twitch.DefaultCapabilities
+	}
+
+	return self.IRC.Capabilities
+}
+
+func isKnownCapability(capability string) bool {
+	for _, known := range twitch.DefaultCapabilities {
+		if known == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Every setting (except plugin settings) can be overridden by an environment
+// variable named after its path in the YAML file, e.g. KABUKIBOT_DATABASE_DSN
+// or KABUKIBOT_ACCOUNT_PASSWORD.
+const envPrefix = "KABUKIBOT"
+
+func applyEnvironment(value reflect.Value, prefix string) error {
+	t := value.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := prefix + "_" + strings.ToUpper(yamlKey(field))
+		target := value.Field(i)
+
+		switch target.Kind() {
+		case reflect.Struct:
+			err := applyEnvironment(target, name)
+			if err != nil {
+				return err
+			}
+
+		case reflect.String:
+			if env, okay := os.LookupEnv(name); okay {
+				target.SetString(env)
+			}
+
+		case reflect.Int:
+			if env, okay := os.LookupEnv(name); okay {
+				number, err := strconv.Atoi(env)
+				if err != nil {
+					return errors.New("The environment variable " + name + " must be a number.")
+				}
+
+				target.SetInt(int64(number))
+			}
+
+		case reflect.Bool:
+			if env, okay := os.LookupEnv(name); okay {
+				flag, err := strconv.ParseBool(env)
+				if err != nil {
+					return errors.New("The environment variable " + name + " must be true or false.")
+				}
+
+				target.SetBool(flag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// yamlKey mimics how the yaml package names a field
+func yamlKey(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if len(tag) > 0 {
+		return tag
+	}
+
+	return strings.ToLower(field.Name)
+}
+
+// Level returns the configured log level; okay is false if none was configured.
+func (self *Configuration) Level() (level int, okay bool) {
+	switch strings.ToLower(self.LogLevel) {
+	case "debug":
+		return LogLevelDebug, true
+	case "info":
+		return LogLevelInfo, true
+	case "warning":
+		return LogLevelWarning, true
+	case "error":
+		return LogLevelError, true
+	}
+
+	return 0, false
+}
+
 func (self *Configuration) PluginConfig(plugin string, dest interface{}) error {
 	data, exists := self.Plugins[plugin]
 
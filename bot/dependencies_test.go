@@ -0,0 +1,50 @@
+package bot
+
+import "testing"
+
+type namedPlugin struct {
+	name         string
+	dependencies []string
+}
+
+func (self *namedPlugin) Name() string                              { return self.name }
+func (self *namedPlugin) Setup(*Kabukibot)                          {}
+func (self *namedPlugin) CreateWorker(channel Channel) PluginWorker { return nil }
+func (self *namedPlugin) Dependencies() []string                    { return self.dependencies }
+
+func TestPluginName(t *testing.T) {
+	if name := pluginName(&namedPlugin{name: "quotes"}); name != "quotes" {
+		t.Errorf("expected the plugin's name to be used, but got %q.", name)
+	}
+
+	// core plugins have no name, so their package has to do
+	if name := pluginName(&namedPlugin{}); name != "bot" {
+		t.Errorf("expected the package name to be used, but got %q.", name)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	tests := []struct {
+		plugins []Plugin
+		okay    bool
+	}{
+		{[]Plugin{&namedPlugin{name: "quotes"}}, true},
+		{[]Plugin{&namedPlugin{name: "quotes", dependencies: []string{"acl"}}}, false},
+		{[]Plugin{&namedPlugin{name: "quotes", dependencies: []string{"acl"}}, &namedPlugin{name: "acl"}}, true},
+
+		// dependencies are resolved by name, not by where a plugin lives
+		{[]Plugin{&namedPlugin{name: "quotes", dependencies: []string{"bot"}}, &namedPlugin{name: "acl"}}, false},
+		{[]Plugin{&namedPlugin{name: "quotes", dependencies: []string{"bot"}}, &namedPlugin{}}, true},
+	}
+
+	for idx, test := range tests {
+		bot := &Kabukibot{plugins: test.plugins}
+		err := bot.checkDependencies()
+
+		if test.okay && err != nil {
+			t.Errorf("case %d: expected no error, but got: %s", idx, err)
+		} else if !test.okay && err == nil {
+			t.Errorf("case %d: expected a missing dependency to be reported.", idx)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package bot
+
+// Levenshtein returns how many single-character insertions, deletions and
+// substitutions it takes to turn a into b. Once the distance is known to be
+// larger than max, it gives up and returns max+1; a negative max means no limit.
+func Levenshtein(a string, b string, max int) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	// keep the rows short
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+
+	if max >= 0 && len(ra)-len(rb) > max {
+		return max + 1
+	}
+
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		best := current[0]
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+
+			if current[j] < best {
+				best = current[j]
+			}
+		}
+
+		// the distance can only grow from here
+		if max >= 0 && best > max {
+			return max + 1
+		}
+
+		previous, current = current, previous
+	}
+
+	distance := previous[len(rb)]
+	if max >= 0 && distance > max {
+		return max + 1
+	}
+
+	return distance
+}
+
+func minInt(first int, others ...int) int {
+	for _, n := range others {
+		if n < first {
+			first = n
+		}
+	}
+
+	return first
+}
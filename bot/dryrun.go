@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+// dryRun decides which senders only log what they would send instead of
+// sending it, so that new plugins can be tried out against live chat. It can
+// be enabled for all channels at once or for single channels.
+type dryRun struct {
+	log      Logger
+	global   bool
+	channels map[string]bool
+	mutex    sync.RWMutex
+}
+
+func newDryRun(log Logger, global bool) *dryRun {
+	return &dryRun{log: log, global: global, channels: make(map[string]bool)}
+}
+
+func (self *dryRun) enabled(channel string) bool {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	return self.global || self.channels[channel]
+}
+
+// set toggles a single channel, or all channels if it is empty
+func (self *dryRun) set(channel string, enabled bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if len(channel) == 0 {
+		self.global = enabled
+	} else if enabled {
+		self.channels[channel] = true
+	} else {
+		delete(self.channels, channel)
+	}
+}
+
+// intercept logs the message and returns true if it must not be sent
+func (self *dryRun) intercept(channel string, msg twitch.OutgoingMessage) bool {
+	if !self.enabled(channel) {
+		return false
+	}
+
+	self.log.Info("[dry-run] %s: %s", channel, msg.IrcMessage().String())
+
+	return true
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"strings"
+	"sync"
+)
+
+// An OutboundFilter sees every text before it is sent to a channel (or
+// whispered from it) and can change it or, by returning false, drop it. Chat
+// commands like .timeout are never filtered.
+type OutboundFilter func(channel string, text string) (string, bool)
+
+// outboundFilters is the pipeline shared by all senders. Filters run in the
+// order they were added, each one getting the text as the previous one left
+// it. Long texts are only split after the whole pipeline ran, so filters
+// always see the complete text.
+type outboundFilters struct {
+	filters []OutboundFilter
+	mutex   sync.RWMutex
+}
+
+func (self *outboundFilters) add(filter OutboundFilter) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.filters = append(self.filters, filter)
+}
+
+func (self *outboundFilters) apply(channel string, text string) (string, bool) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	for _, filter := range self.filters {
+		var keep bool
+
+		text, keep = filter(channel, text)
+		if !keep {
+			return "", false
+		}
+	}
+
+	return text, true
+}
+
+// An InboundFilter normalizes the text of incoming messages, e.g. to undo
+// tricks that are used to evade moderation. Commands are parsed from the
+// normalized text; the original is kept as well (see TextMessage.Raw).
+type InboundFilter func(channel string, text string) string
+
+// inboundFilters is the pipeline every received message runs through, in the
+// order the filters were added.
+type inboundFilters struct {
+	filters []InboundFilter
+	mutex   sync.RWMutex
+}
+
+func newInboundFilters() *inboundFilters {
+	return &inboundFilters{filters: []InboundFilter{stripInvisible}}
+}
+
+func (self *inboundFilters) add(filter InboundFilter) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.filters = append(self.filters, filter)
+}
+
+func (self *inboundFilters) apply(channel string, text string) string {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	for _, filter := range self.filters {
+		text = filter(channel, text)
+	}
+
+	return text
+}
+
+// stripInvisible removes zero-width characters and Unicode tags (which chat
+// clients append to get around Twitch's duplicate message check). It always
+// runs first.
+func stripInvisible(channel string, text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0x200B && r <= 0x200F, r >= 0x2060 && r <= 0x2064, r == 0xFEFF, r == 0x180E, r == 0x034F:
+			return -1
+		case r >= 0xE0000 && r <= 0xE007F:
+			return -1
+		}
+
+		return r
+	}, text)
+}
@@ -0,0 +1,57 @@
+package bot
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// A HealthReport is a snapshot of how the bot is doing, to diagnose a
+// misbehaving instance without having to look at its logs.
+type HealthReport struct {
+	DatabaseError   error // nil if the database could be pinged
+	Channels        int
+	QueuedMessages  int // chat messages waiting for the rate limiter
+	QueuedWhispers  int
+	RateLimitTokens int // how many chat messages could be sent right now
+	RateLimitBurst  int // how many chat messages can be sent at once
+	Uptime          time.Duration
+	Goroutines      int
+}
+
+// how long Health waits for the database before calling it unreachable
+const healthPingTimeout = 5 * time.Second
+
+// Health gathers the report; pinging the database can take a moment.
+func (bot *Kabukibot) Health() HealthReport {
+	tokens, burst := bot.limiter.headroom()
+
+	return HealthReport{
+		DatabaseError:   bot.pingDatabase(healthPingTimeout),
+		Channels:        len(bot.Channels()),
+		QueuedMessages:  int(atomic.LoadInt64(&bot.limiter.pending)),
+		QueuedWhispers:  int(atomic.LoadInt64(&bot.whispers.pending)),
+		RateLimitTokens: tokens,
+		RateLimitBurst:  burst,
+		Uptime:          time.Since(bot.connectedAt),
+		Goroutines:      runtime.NumGoroutine(),
+	}
+}
+
+// pingDatabase pings in a goroutine of its own, because a database that
+// accepts connections but never answers would block the caller indefinitely.
+func (bot *Kabukibot) pingDatabase(timeout time.Duration) error {
+	result := make(chan error, 1)
+
+	go func() {
+		result <- bot.database.Ping()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("no answer within %s", timeout)
+	}
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// newHealthTestBot creates just enough of a bot to report its health
+func newHealthTestBot(t *testing.T, address string) *Kabukibot {
+	db, err := sqlx.Open("mysql", "kabukibot:secret@tcp("+address+")/kabukibot?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Configuration{}
+
+	return &Kabukibot{
+		database:    db,
+		workers:     make(map[string]*channelWorker),
+		limiter:     newRateLimiter(nil, config, nil),
+		whispers:    newWhisperLimiter(nil, config, nil),
+		connectedAt: time.Now(),
+	}
+}
+
+func TestHealthReportsUnreachableDatabase(t *testing.T) {
+	// nothing is listening anymore, so connecting is refused right away
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	listener.Close()
+
+	bot := newHealthTestBot(t, address)
+	defer bot.database.Close()
+
+	report := bot.Health()
+
+	if report.DatabaseError == nil {
+		t.Fatal("expected the database to be reported as unreachable.")
+	}
+
+	if report.Channels != 0 || report.QueuedMessages != 0 || report.QueuedWhispers != 0 {
+		t.Errorf("expected the rest of the report to be unaffected, but got %+v.", report)
+	}
+}
+
+func TestHealthDoesNotWaitForSilentDatabase(t *testing.T) {
+	// connections are accepted, but the handshake never comes
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			defer conn.Close()
+		}
+	}()
+
+	bot := newHealthTestBot(t, listener.Addr().String())
+	defer bot.database.Close()
+
+	started := time.Now()
+	err = bot.pingDatabase(100 * time.Millisecond)
+
+	if err == nil {
+		t.Fatal("expected the ping to fail without an answer from the database.")
+	}
+
+	if waited := time.Since(started); waited > 500*time.Millisecond {
+		t.Errorf("expected the ping to give up after 100ms, but it took %s.", waited)
+	}
+}
@@ -1,9 +1,13 @@
 package bot
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"strings"
 	"sync"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
@@ -11,19 +15,59 @@ import (
 	"github.com/sgt-kabukiman/kabukibot/twitch"
 )
 
+const (
+	defaultReconnectDelay    = 2 * time.Second
+	defaultReconnectMaxDelay = 5 * time.Minute
+
+	// how long shutting down waits for queued messages to be sent
+	shutdownFlushTimeout = 5 * time.Second
+)
+
 type Kabukibot struct {
-	twitch        twitch.Client
-	workers       map[string]*channelWorker
-	channelMutex  sync.Mutex
-	plugins       []Plugin
-	logger        Logger
-	dictionary    *Dictionary
-	database      *sqlx.DB
-	configuration *Configuration
-	alive         chan struct{}
+	twitch          twitch.Client
+	limiter         *rateLimiter
+	joins           *joinLimiter
+	whispers        *whisperLimiter
+	outbound        *outboundFilters
+	inbound         *inboundFilters
+	dryRun          *dryRun
+	workers         map[string]*channelWorker
+	channelMutex    sync.Mutex
+	plugins         []Plugin
+	logger          Logger
+	dictionary      *Dictionary
+	commands        *CommandRegistry
+	aliases         *AliasRegistry
+	storage         *Storage
+	settings        *Settings
+	database        *sqlx.DB
+	configuration   *Configuration
+	configMutex     sync.RWMutex
+	alive           chan struct{}
+	reconnecting    chan struct{}
+	stopping        chan struct{} // closed as soon as shutting down begins
+	reconnectLock   sync.Mutex
+	shutdownOnce    sync.Once
+	background      sync.WaitGroup // long-lived goroutines besides Work()
+	ctx             context.Context
+	metrics         *metrics
+	metricsListener net.Listener
+	api             *twitch.APIClient
+	connectedAt     time.Time
+	now             func() time.Time // for channels; see SetClock
 }
 
 func NewKabukibot(client twitch.Client, log Logger, db *sqlx.DB, config *Configuration) (*Kabukibot, error) {
+	// bring the database up to date
+	applied, err := Migrate(db)
+	if err != nil {
+		return nil, fmt.Errorf("Could not migrate the database: %s", err.Error())
+	}
+
+	if applied > 0 {
+		log.Info("Applied %d database migration(s).", applied)
+	}
+
 	// create the bot
 	bot := Kabukibot{}
 	bot.database = db
@@ -31,41 +75,266 @@ func NewKabukibot(client twitch.Client, log Logger, db *sqlx.DB, config *Configu
 	bot.workers = make(map[string]*channelWorker)
 	bot.channelMutex = sync.Mutex{}
 	bot.logger = log
+	bot.now = time.Now
+	bot.commands = NewCommandRegistry(log)
+	bot.storage = NewStorage(db, log)
+	bot.settings = NewSettings(bot.storage)
+	bot.settings.Register("core", "page_size", IntSetting(defaultPageSize, 1, 100))
+	bot.aliases = NewAliasRegistry(bot.storage)
+	bot.aliases.configure(config.Aliases)
+	bot.aliases.load()
 	bot.twitch = client
+	bot.metrics = newMetrics()
+	bot.limiter = newRateLimiter(client, config, bot.metrics)
+	bot.joins = newJoinLimiter(client)
+	bot.whispers = newWhisperLimiter(client, config, bot.metrics)
+	bot.outbound = &outboundFilters{}
+	bot.inbound = newInboundFilters()
+	bot.dryRun = newDryRun(log, config.DryRun)
 	bot.alive = make(chan struct{})
+	bot.reconnecting = make(chan struct{})
+	bot.stopping = make(chan struct{})
+	bot.ctx = context.Background()
 
 	return &bot, nil
 }
 
 func (bot *Kabukibot) Connect() error {
+	return bot.ConnectContext(context.Background())
+}
+
+// ConnectContext connects to Twitch; cancelling the context later on shuts the
+// bot down, including the connection and all goroutines that belong to it.
+// If it is cancelled while still connecting, the bot cannot be used anymore.
+func (bot *Kabukibot) ConnectContext(ctx context.Context) error {
+	bot.ctx = ctx
+	bot.connectedAt = time.Now()
+
 	// load dictionary elements
 	bot.logger.Debug("Loading dictionary...")
 	bot.dictionary = NewDictionary(bot.database, bot.logger)
 	bot.dictionary.load()
 
+	// make sure all plugins have what they need
+	err := bot.checkDependencies()
+	if err != nil {
+		return err
+	}
+
+	// all plugins share one API client and hence its cache
+	api := bot.Configuration().TwitchAPI
+
+	ttl := time.Duration(api.CacheTTL) * time.Second
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	bot.api = twitch.NewAPIClient(nil, api.BaseURL, api.ClientID, api.Token, ttl)
+	bot.api.SetClock(bot.now)
+
 	// setup plugins
 	bot.logger.Debug("Setting up plugins...")
 	for _, plugin := range bot.plugins {
 		plugin.Setup(bot)
 	}
 
+	// start sending queued messages
+	bot.background.Add(1)
+
+	go func() {
+		defer bot.background.Done()
+		bot.limiter.Work()
+	}()
+
+	bot.background.Add(1)
+
+	go func() {
+		defer bot.background.Done()
+		bot.joins.Work()
+	}()
+
+	bot.background.Add(1)
+
+	go func() {
+		defer bot.background.Done()
+		bot.whispers.Work()
+	}()
+
+	err = bot.serveMetrics()
+	if err != nil {
+		return err
+	}
+
 	// connect to Twitch
 	client := bot.twitch
 
-	bot.logger.Info("Connecting to Twitch chat @ %s:%d...", bot.configuration.IRC.Host, bot.configuration.IRC.Port)
-	err := client.Connect()
+	bot.logger.Info("Connecting to Twitch chat @ %s:%d...", bot.Configuration().IRC.Host, bot.Configuration().IRC.Port)
+	err = client.Connect()
 	if err != nil {
 		return err
 	}
 
 	// wait for the ready signal
-	<-client.Ready()
+	select {
+	case <-client.Ready():
+	case <-ctx.Done():
+		bot.abortConnect()
+		return ctx.Err()
+	}
+
 	bot.logger.Info("Connection established.")
 
+	bot.background.Add(1)
+
+	go func() {
+		defer bot.background.Done()
+		bot.watchConnection()
+	}()
+
+	// this cannot be part of the background goroutines, as it waits for them
+	go func() {
+		select {
+		case <-ctx.Done():
+			bot.Shutdown()
+		case <-bot.alive:
+		}
+	}()
+
 	return nil
 }
 
+// abortConnect stops everything that connecting has started. Work() never
+// ran, so there is nobody to close alive but us.
+func (bot *Kabukibot) abortConnect() {
+	bot.shutdownOnce.Do(func() {
+		bot.logger.Warning("Connecting has been cancelled.")
+
+		bot.limiter.Stop()
+		bot.joins.Stop()
+		bot.whispers.Stop()
+
+		if bot.metricsListener != nil {
+			bot.metricsListener.Close()
+		}
+
+		bot.twitch.Disconnect()
+		bot.background.Wait()
+
+		close(bot.alive)
+	})
+}
+
+// Reconnecting returns a signal that is sent (closed) the next time the
+// connection to Twitch dies and the bot starts to reconnect.
+func (bot *Kabukibot) Reconnecting() <-chan struct{} {
+	bot.reconnectLock.Lock()
+	defer bot.reconnectLock.Unlock()
+
+	return bot.reconnecting
+}
+
+func (bot *Kabukibot) watchConnection() {
+	for {
+		select {
+		case <-bot.twitch.ConnectionLost():
+			bot.reconnect()
+
+		case <-bot.ctx.Done():
+			return
+
+		case <-bot.alive:
+			return
+		}
+	}
+}
+
+func (bot *Kabukibot) reconnect() {
+	bot.logger.Warning("Lost connection to Twitch, reconnecting...")
+	bot.metrics.reconnected()
+
+	bot.reconnectLock.Lock()
+	close(bot.reconnecting)
+	bot.reconnecting = make(chan struct{})
+	bot.reconnectLock.Unlock()
+
+	delay := time.Duration(bot.Configuration().Reconnect.Delay) * time.Second
+	if delay <= 0 {
+		delay = defaultReconnectDelay
+	}
+
+	maxDelay := time.Duration(bot.Configuration().Reconnect.MaxDelay) * time.Second
+	if maxDelay <= 0 {
+		maxDelay = defaultReconnectMaxDelay
+	}
+
+	for {
+		// once shutting down has begun, the client must not be connected
+		// again, as it is about to be disconnected for good
+		select {
+		case <-time.After(delay):
+		case <-bot.ctx.Done():
+			return
+		case <-bot.stopping:
+			return
+		case <-bot.alive:
+			return
+		}
+
+		err := bot.twitch.Connect()
+		if err == nil {
+			break
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+
+		bot.logger.Error("Reconnect failed (%s), trying again in %s.", err.Error(), delay)
+	}
+
+	select {
+	case <-bot.twitch.Ready():
+	case <-bot.ctx.Done():
+		return
+	case <-bot.stopping:
+		return
+	case <-bot.alive:
+		return
+	}
+
+	bot.logger.Info("Connection re-established.")
+
+	// the channel workers are still around, so their plugins are in the same
+	// state as before; we only need to tell Twitch where we are
+	for _, channel := range bot.Channels() {
+		bot.logger.Info("Rejoining %s...", channel)
+		bot.joins.Send(twitch.JoinMessage{channel})
+	}
+}
+
+// Run works until the context is cancelled, then shuts the bot down. It also
+// returns when the bot has been shut down otherwise.
+func (bot *Kabukibot) Run(ctx context.Context) {
+	go bot.Work()
+
+	select {
+	case <-ctx.Done():
+		bot.Shutdown()
+	case <-bot.alive:
+	}
+}
+
+// Shutdown stops all channel workers (and hence their plugins), leaves the
+// channels, sends what is still queued and then disconnects. It is safe to
+// call it more than once.
 func (bot *Kabukibot) Shutdown() {
+	bot.shutdownOnce.Do(bot.shutdown)
+}
+
+func (bot *Kabukibot) shutdown() {
+	close(bot.stopping)
+
 	// shutdown all channel workers
 	bot.channelMutex.Lock()
 
@@ -74,7 +343,10 @@ func (bot *Kabukibot) Shutdown() {
 	wg := sync.WaitGroup{}
 	wg.Add(len(bot.workers))
 
-	for _, worker := range bot.workers {
+	channels := make([]string, 0, len(bot.workers))
+
+	for channel, worker := range bot.workers {
+		channels = append(channels, channel)
 		signal := worker.Shutdown()
 
 		go func() {
@@ -84,10 +356,29 @@ func (bot *Kabukibot) Shutdown() {
 	}
 
 	wg.Wait()
+
+	// the workers are gone, so nobody must try to hand them messages anymore
+	bot.workers = make(map[string]*channelWorker)
 	bot.channelMutex.Unlock()
 
 	bot.logger.Info("All channel workers have shut down.")
 
+	for _, channel := range channels {
+		bot.limiter.Send(twitch.PartMessage{channel}, false)
+	}
+
+	if !bot.limiter.Flush(shutdownFlushTimeout) || !bot.whispers.Flush(shutdownFlushTimeout) {
+		bot.logger.Warning("Not all queued messages could be sent before shutting down.")
+	}
+
+	bot.limiter.Stop()
+	bot.joins.Stop()
+	bot.whispers.Stop()
+
+	if bot.metricsListener != nil {
+		bot.metricsListener.Close()
+	}
+
 	// disconnect from IRC;
 	// This will close the twitch client's incoming channel and hence stop .Work(),
 	// which will close self.alive eventually.
@@ -95,16 +386,27 @@ func (bot *Kabukibot) Shutdown() {
 	bot.twitch.Disconnect()
 
 	<-bot.alive
+	bot.background.Wait()
 	bot.logger.Info("It's dead, Jim.")
 }
 
 func (bot *Kabukibot) Work() {
 	go bot.joinInitialChannels()
 
-	prefix := bot.configuration.CommandPrefix
-	operator := bot.OpUsername()
+	prefix := bot.Configuration().CommandPrefix
 
 	for msg := range bot.twitch.Incoming() {
+		// raw lines are only a different view on the parsed messages
+		if _, raw := msg.(twitch.RawLineMessage); !raw {
+			bot.metrics.messageReceived()
+		}
+
+		whisper, okay := msg.(twitch.WhisperMessage)
+		if okay {
+			bot.handleWhisper(whisper, prefix)
+			continue
+		}
+
 		// find the appropriate worker
 		channel := msg.ChannelName()
 
@@ -115,7 +417,7 @@ func (bot *Kabukibot) Work() {
 		if exists {
 			asserted, okay := msg.(twitch.TextMessage)
 			if okay {
-				worker.Input() <- TextMessage{asserted, prefix, operator, false}
+				worker.Input() <- TextMessage{TextMessage: asserted, prefix: prefix, trigger: DefaultTrigger, operator: bot.OpUsername()}
 			} else {
 				worker.Input() <- msg
 			}
@@ -131,9 +433,71 @@ func (bot *Kabukibot) Alive() <-chan struct{} {
 }
 
 func (bot *Kabukibot) Configuration() *Configuration {
+	bot.configMutex.RLock()
+	defer bot.configMutex.RUnlock()
+
 	return bot.configuration
 }
 
+// Reload re-reads the configuration file the bot was started with.
+func (bot *Kabukibot) Reload() error {
+	return bot.ReloadFrom(bot.Configuration().filename)
+}
+
+// ReloadFrom applies the settings from the given configuration file that are
+// safe to change while running: the operator, rate limits, log level, command
+// aliases and the plugin settings. Everything else (account, server, database, command prefix)
+// requires a restart.
+func (bot *Kabukibot) ReloadFrom(filename string) error {
+	loaded, err := LoadConfiguration(filename)
+	if err != nil {
+		return err
+	}
+
+	bot.configMutex.Lock()
+	config := *bot.configuration
+	config.Operator = loaded.Operator
+	config.RateLimit = loaded.RateLimit
+	config.WhisperLimit = loaded.WhisperLimit
+	config.DeduplicateMessages = loaded.DeduplicateMessages
+	config.LogLevel = loaded.LogLevel
+	config.Plugins = loaded.Plugins
+	config.Aliases = loaded.Aliases
+	bot.configuration = &config
+	bot.configMutex.Unlock()
+
+	bot.logger.Info("Reloaded configuration from %s.", filename)
+
+	if level, okay := config.Level(); okay {
+		bot.logger.SetLevel(level)
+	}
+
+	bot.limiter.configure(&config)
+	bot.whispers.configure(&config)
+	bot.aliases.configure(config.Aliases)
+
+	// channel workers update their ACL themselves to not race with it
+	bot.channelMutex.Lock()
+	workers := make([]*channelWorker, 0, len(bot.workers))
+	for _, worker := range bot.workers {
+		workers = append(workers, worker)
+	}
+	bot.channelMutex.Unlock()
+
+	for _, worker := range workers {
+		worker.Input() <- reconfiguration{worker.Name(), &config}
+	}
+
+	for _, plugin := range bot.plugins {
+		asserted, okay := plugin.(reconfigurablePlugin)
+		if okay {
+			asserted.Reconfigure(&config)
+		}
+	}
+
+	return nil
+}
+
 func (bot *Kabukibot) Database() *sqlx.DB {
 	return bot.database
 }
@@ -146,6 +510,22 @@ func (bot *Kabukibot) Dictionary() *Dictionary {
 	return bot.dictionary
 }
 
+func (bot *Kabukibot) Commands() *CommandRegistry {
+	return bot.commands
+}
+
+func (bot *Kabukibot) Aliases() *AliasRegistry {
+	return bot.aliases
+}
+
+func (bot *Kabukibot) Storage() *Storage {
+	return bot.storage
+}
+
+func (bot *Kabukibot) Settings() *Settings {
+	return bot.settings
+}
+
 func (bot *Kabukibot) Channel(name string) (Channel, error) {
 	bot.channelMutex.Lock()
 	defer bot.channelMutex.Unlock()
@@ -177,10 +557,90 @@ func (bot *Kabukibot) AddPlugin(plugin Plugin) {
 	bot.plugins = append(bot.plugins, plugin)
 }
 
+// AddOutboundFilter appends a filter to the pipeline every text runs through
+// before it is sent; plugins should do this during their setup.
+func (bot *Kabukibot) AddOutboundFilter(filter OutboundFilter) {
+	bot.outbound.add(filter)
+}
+
+// AddInboundFilter appends a filter to the pipeline that normalizes received
+// messages; plugins should do this during their setup.
+func (bot *Kabukibot) AddInboundFilter(filter InboundFilter) {
+	bot.inbound.add(filter)
+}
+
+// SetDryRun toggles the dry-run mode for a single channel or, if the channel is
+// empty, for all of them. In dry-run mode, nothing is sent but only logged.
+func (bot *Kabukibot) SetDryRun(channel string, enabled bool) {
+	bot.dryRun.set(channel, enabled)
+}
+
+// DryRun tells whether the channel is in dry-run mode, either on its own or
+// because all channels are.
+func (bot *Kabukibot) DryRun(channel string) bool {
+	return bot.dryRun.enabled(channel)
+}
+
+func (bot *Kabukibot) handleWhisper(whisper twitch.WhisperMessage, prefix string) {
+	ownChannel := "#" + strings.ToLower(bot.BotUsername())
+
+	msg := TextMessage{
+		TextMessage: twitch.TextMessage{
+			User: twitch.User{Name: whisper.User},
+			Text: whisper.Text,
+			Tags: whisper.Tags,
+		},
+		normalized: bot.aliases.rewrite(DefaultTrigger, bot.inbound.apply(ownChannel, whisper.Text)),
+		prefix:     prefix,
+		trigger:    DefaultTrigger,
+		operator:   bot.OpUsername(),
+	}
+
+	sender := &whisperResponder{newChannelSender(bot.limiter, bot.whispers, bot.outbound, bot.dryRun, ownChannel, true), whisper.User}
+
+	for _, plugin := range bot.plugins {
+		asserted, okay := plugin.(whisperPlugin)
+		if okay {
+			asserted.HandleWhisper(&msg, sender)
+
+			if msg.IsPropagationStopped() {
+				break
+			}
+		}
+	}
+}
+
+func (bot *Kabukibot) TwitchAPI() *twitch.APIClient {
+	return bot.api
+}
+
 func (bot *Kabukibot) Plugins() []Plugin {
 	return bot.plugins
 }
 
+func (bot *Kabukibot) checkDependencies() error {
+	available := make(map[string]bool)
+
+	for _, plugin := range bot.plugins {
+		available[pluginName(plugin)] = true
+	}
+
+	for _, plugin := range bot.plugins {
+		asserted, okay := plugin.(dependentPlugin)
+		if !okay {
+			continue
+		}
+
+		for _, dependency := range asserted.Dependencies() {
+			if !available[dependency] {
+				return fmt.Errorf("The %s plugin requires the %s plugin, but it has not been added.", pluginName(plugin), dependency)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (bot *Kabukibot) Join(channel string) <-chan bool {
 	channel = strings.ToLower(channel)
 
@@ -207,8 +667,8 @@ func (bot *Kabukibot) Join(channel string) <-chan bool {
 	bot.workers[channel] = worker
 	bot.channelMutex.Unlock()
 
-	// remember that we joined
-	bot.Database().Exec("INSERT INTO channel (name) VALUES (?)", channel)
+	// remember that we joined, so we can rejoin after a restart
+	bot.rememberChannel(channel)
 
 	// go have fun
 	go worker.Work()
@@ -217,21 +677,41 @@ func (bot *Kabukibot) Join(channel string) <-chan bool {
 	go func() {
 		<-worker.Alive()
 
-		// cleanup
+		// cleanup; the channel might have been joined again in the meantime
 		bot.channelMutex.Lock()
-		delete(bot.workers, channel)
+		if bot.workers[channel] == worker {
+			delete(bot.workers, channel)
+		}
 		bot.channelMutex.Unlock()
+
+		bot.limiter.forget(channel)
 	}()
 
-	// now that we are prepared to handle the channel messages, actually join
-	return bot.twitch.Send(twitch.JoinMessage{channel})
+	// now that we are prepared to handle the channel messages, actually join;
+	// when joining many channels, this takes a while
+	return bot.joins.Send(twitch.JoinMessage{channel})
+}
+
+// SetClock replaces the clock that channels joined afterwards use to expire
+// temporary grants and to throttle commands. The Twitch API client picks it up
+// when connecting, for its cache and for durations like the stream's uptime.
+func (bot *Kabukibot) SetClock(now func() time.Time) {
+	bot.now = now
+}
+
+// SetPacingClock replaces the clock that is used to pace messages, JOINs and
+// whispers, so that tests do not have to wait for real seconds to pass.
+func (bot *Kabukibot) SetPacingClock(now func() time.Time, after func(time.Duration) <-chan time.Time) {
+	bot.limiter.setClock(now, after)
+	bot.joins.setClock(now, after)
+	bot.whispers.setClock(now, after)
 }
 
 func (bot *Kabukibot) Part(channel string) <-chan bool {
 	channel = strings.ToLower(channel)
 
-	// never leave our home channel
-	if channel == "#"+strings.ToLower(bot.BotUsername()) {
+	// never leave our home channel, and parting a channel we are not in is a no-op
+	if channel == "#"+strings.ToLower(bot.BotUsername()) || !bot.Joined(channel) {
 		dummy := make(chan bool, 1)
 		dummy <- false
 		close(dummy)
@@ -258,11 +738,11 @@ func (bot *Kabukibot) Joined(channel string) bool {
 }
 
 func (bot *Kabukibot) BotUsername() string {
-	return bot.configuration.Account.Username
+	return bot.Configuration().Account.Username
 }
 
 func (bot *Kabukibot) OpUsername() string {
-	return bot.configuration.Operator
+	return bot.Configuration().Operator
 }
 
 func (bot *Kabukibot) IsBot(username string) bool {
@@ -285,6 +765,20 @@ func (bot *Kabukibot) MessagesReceived() uint64 {
 	return bot.twitch.MessagesReceived()
 }
 
+func (bot *Kabukibot) rememberChannel(channel string) {
+	// the home channel is always joined anyway
+	if channel == "#"+strings.ToLower(bot.BotUsername()) {
+		return
+	}
+
+	count := 0
+	bot.Database().Get(&count, "SELECT COUNT(*) FROM channel WHERE name = ?", channel)
+
+	if count == 0 {
+		bot.Database().Exec("INSERT INTO channel (name) VALUES (?)", channel)
+	}
+}
+
 type initialChannel struct {
 	Name string `db:"name"`
 }
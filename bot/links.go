@@ -0,0 +1,11 @@
+package bot
+
+import "github.com/mvdan/xurls"
+
+// ContainsURL tells whether the text contains anything that looks like a link,
+// either with a scheme ("http://...") or as a bare domain ("example.com"). Bare
+// domains are only detected if they end in a known TLD, so things like "3.14" or
+// "sentence.Another" are not considered links.
+func ContainsURL(text string) bool {
+	return xurls.Relaxed.MatchString(text)
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+)
+
+// Every kind of message that plugin workers can handle is an event. A worker
+// listens for an event if it implements the matching interface and its plugin
+// is enabled in the channel. Events can be scoped to a channel by appending
+// its name, like "text#mychannel".
+var listenerTests = map[string]func(PluginWorker) bool{
+	"text": func(w PluginWorker) bool {
+		_, okay := w.(textMessageWorker)
+		return okay
+	},
+	"roomstate": func(w PluginWorker) bool {
+		_, okay := w.(roomStateMessageWorker)
+		return okay
+	},
+	"clearchat": func(w PluginWorker) bool {
+		_, okay := w.(clearChatMessageWorker)
+		return okay
+	},
+	"clearmsg": func(w PluginWorker) bool {
+		_, okay := w.(clearMsgMessageWorker)
+		return okay
+	},
+	"subscription": func(w PluginWorker) bool {
+		_, okay := w.(subNotificationMessageWorker)
+		return okay
+	},
+	"raid": func(w PluginWorker) bool {
+		_, okay := w.(raidMessageWorker)
+		return okay
+	},
+	"mode": func(w PluginWorker) bool {
+		_, okay := w.(modeMessageWorker)
+		return okay
+	},
+	"rawline": func(w PluginWorker) bool {
+		_, okay := w.(rawLineWorker)
+		return okay
+	},
+}
+
+// listenerCounts returns the number of listeners per (unscoped) event.
+func (self *channelWorker) listenerCounts() map[string]int {
+	self.workersMutex.RLock()
+	defer self.workersMutex.RUnlock()
+
+	counts := make(map[string]int)
+
+	for _, worker := range self.workers {
+		if !worker.Enabled {
+			continue
+		}
+
+		for event, listens := range listenerTests {
+			if listens(worker.Worker) {
+				counts[event]++
+			}
+		}
+	}
+
+	return counts
+}
+
+// allListenerCounts returns the number of listeners per event, both across
+// all channels and scoped to each channel.
+func (bot *Kabukibot) allListenerCounts() map[string]int {
+	bot.channelMutex.Lock()
+	defer bot.channelMutex.Unlock()
+
+	counts := make(map[string]int)
+
+	for channel, worker := range bot.workers {
+		for event, count := range worker.listenerCounts() {
+			counts[event] += count
+			counts[event+channel] = count
+		}
+	}
+
+	return counts
+}
+
+// Events returns all events that currently have listeners, sorted. This is
+// mostly useful for finding out what plugins are doing.
+func (bot *Kabukibot) Events() []string {
+	events := make([]string, 0)
+
+	for event := range bot.allListenerCounts() {
+		events = append(events, event)
+	}
+
+	sort.Strings(events)
+
+	return events
+}
+
+// ListenerCount returns how many plugin workers listen for the event, which can
+// be scoped to a channel ("text#mychannel").
+func (bot *Kabukibot) ListenerCount(event string) int {
+	return bot.allListenerCounts()[strings.ToLower(event)]
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// metrics are kept per bot and exposed in the Prometheus text format. Only
+// processed commands are counted, so that random "!words" in chat do not
+// create new series.
+type metrics struct {
+	mutex      sync.Mutex
+	received   uint64
+	sent       uint64
+	reconnects uint64
+	commands   map[string]map[string]uint64 // channel => command => count
+}
+
+func newMetrics() *metrics {
+	return &metrics{commands: make(map[string]map[string]uint64)}
+}
+
+func (self *metrics) messageReceived() {
+	self.mutex.Lock()
+	self.received++
+	self.mutex.Unlock()
+}
+
+func (self *metrics) messageSent() {
+	self.mutex.Lock()
+	self.sent++
+	self.mutex.Unlock()
+}
+
+func (self *metrics) reconnected() {
+	self.mutex.Lock()
+	self.reconnects++
+	self.mutex.Unlock()
+}
+
+func (self *metrics) commandUsed(channel string, command string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	counts, exists := self.commands[channel]
+	if !exists {
+		counts = make(map[string]uint64)
+		self.commands[channel] = counts
+	}
+
+	counts[command]++
+}
+
+// gauges are not stored, but determined when scraping
+type metricsGauges struct {
+	channels      int
+	channelQueues int
+	sendQueue     int
+	listeners     map[string]int // unscoped event => listeners
+}
+
+func (self *metrics) write(w io.Writer, gauges metricsGauges) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	writeMetric(w, "kabukibot_messages_received_total", "counter", "Messages received from Twitch.", self.received)
+	writeMetric(w, "kabukibot_messages_sent_total", "counter", "Messages sent to Twitch.", self.sent)
+	writeMetric(w, "kabukibot_reconnects_total", "counter", "Reconnects after losing the connection to Twitch.", self.reconnects)
+	writeMetric(w, "kabukibot_channels", "gauge", "Channels the bot is currently in.", gauges.channels)
+	writeMetric(w, "kabukibot_channel_queue_length", "gauge", "Incoming messages waiting to be handled by channel workers.", gauges.channelQueues)
+	writeMetric(w, "kabukibot_send_queue_length", "gauge", "Outgoing messages held back by the rate limiter.", gauges.sendQueue)
+
+	fmt.Fprintln(w, "# HELP kabukibot_listeners Plugin workers listening for each kind of message.")
+	fmt.Fprintln(w, "# TYPE kabukibot_listeners gauge")
+
+	events := make([]string, 0, len(gauges.listeners))
+	for event := range gauges.listeners {
+		events = append(events, event)
+	}
+
+	sort.Strings(events)
+
+	for _, event := range events {
+		fmt.Fprintf(w, "kabukibot_listeners{event=%q} %d\n", event, gauges.listeners[event])
+	}
+
+	fmt.Fprintln(w, "# HELP kabukibot_command_invocations_total Commands handled by plugins.")
+	fmt.Fprintln(w, "# TYPE kabukibot_command_invocations_total counter")
+
+	lines := make([]string, 0)
+
+	for channel, counts := range self.commands {
+		for command, count := range counts {
+			lines = append(lines, fmt.Sprintf("kabukibot_command_invocations_total{channel=%q,command=%q} %d", channel, command, count))
+		}
+	}
+
+	sort.Strings(lines)
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
+
+func writeMetric(w io.Writer, name string, kind string, help string, value interface{}) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
+
+// serveMetrics starts the HTTP server if an address has been configured.
+func (bot *Kabukibot) serveMetrics() error {
+	address := bot.Configuration().Metrics.Address
+	if len(address) == 0 {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		bot.metrics.write(w, bot.metricsGauges())
+	})
+
+	bot.metricsListener = listener
+	bot.logger.Info("Serving metrics @ http://%s/metrics", listener.Addr())
+
+	bot.background.Add(1)
+
+	go func() {
+		defer bot.background.Done()
+		http.Serve(listener, mux)
+	}()
+
+	return nil
+}
+
+func (bot *Kabukibot) metricsGauges() metricsGauges {
+	listeners := make(map[string]int)
+
+	for event, count := range bot.allListenerCounts() {
+		if !strings.Contains(event, "#") {
+			listeners[event] = count
+		}
+	}
+
+	bot.channelMutex.Lock()
+	defer bot.channelMutex.Unlock()
+
+	gauges := metricsGauges{
+		channels:  len(bot.workers),
+		sendQueue: len(bot.limiter.queue),
+		listeners: listeners,
+	}
+
+	for _, worker := range bot.workers {
+		gauges.channelQueues += len(worker.inputChannel)
+	}
+
+	return gauges
+}
+
+// MetricsAddress returns the address the metrics server is listening on, or
+// an empty string if it is disabled.
+func (bot *Kabukibot) MetricsAddress() string {
+	if bot.metricsListener == nil {
+		return ""
+	}
+
+	return bot.metricsListener.Addr().String()
+}
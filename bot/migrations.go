@@ -0,0 +1,355 @@
+package bot
+
+import (
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// A migration brings the schema from one version to the next. Migrations are
+// applied in order and must never be changed once released; add a new one
+// instead. Stick to SQL that both MySQL and SQLite understand.
+type migration struct {
+	version    int
+	statements []string
+}
+
+var migrations = []migration{
+	// the tables that existed before migrations were introduced, as they were
+	// before any plugin changed them; existing databases already have them
+	{1, []string{
+		`CREATE TABLE IF NOT EXISTS channel (
+			name VARCHAR(64) NOT NULL,
+			PRIMARY KEY (name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS plugin (
+			channel VARCHAR(64) NOT NULL,
+			plugin  VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, plugin)
+		)`,
+		`CREATE TABLE IF NOT EXISTS acl (
+			channel    VARCHAR(64) NOT NULL,
+			permission VARCHAR(128) NOT NULL,
+			user_ident VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, permission, user_ident)
+		)`,
+		`CREATE TABLE IF NOT EXISTS dictionary (
+			keyname VARCHAR(191) NOT NULL,
+			value   TEXT NOT NULL,
+			PRIMARY KEY (keyname)
+		)`,
+		`CREATE TABLE IF NOT EXISTS blacklist (
+			username VARCHAR(64) NOT NULL,
+			PRIMARY KEY (username)
+		)`,
+		`CREATE TABLE IF NOT EXISTS custom_commands (
+			channel VARCHAR(64) NOT NULL,
+			command VARCHAR(64) NOT NULL,
+			message TEXT NOT NULL,
+			PRIMARY KEY (channel, command)
+		)`,
+		`CREATE TABLE IF NOT EXISTS domain_ban (
+			channel VARCHAR(64) NOT NULL,
+			domain  VARCHAR(191) NOT NULL,
+			bantype VARCHAR(32) NOT NULL,
+			counter INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (channel, domain)
+		)`,
+		`CREATE TABLE IF NOT EXISTS emote_counter (
+			channel VARCHAR(64) NOT NULL,
+			emote   VARCHAR(64) NOT NULL,
+			counter INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (channel, emote)
+		)`,
+	}},
+
+	// custom command cooldowns in seconds, 0 for none
+	{2, []string{
+		`ALTER TABLE custom_commands ADD COLUMN cooldown INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE custom_commands ADD COLUMN user_cooldown INTEGER NOT NULL DEFAULT 0`,
+	}},
+
+	// the $(count) token of custom commands
+	{3, []string{
+		`CREATE TABLE IF NOT EXISTS custom_command_counters (
+			channel VARCHAR(64) NOT NULL,
+			command VARCHAR(64) NOT NULL,
+			value   INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (channel, command)
+		)`,
+	}},
+
+	// custom command aliases
+	{4, []string{
+		`CREATE TABLE IF NOT EXISTS custom_command_aliases (
+			channel VARCHAR(64) NOT NULL,
+			alias   VARCHAR(64) NOT NULL,
+			command VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, alias)
+		)`,
+	}},
+
+	// custom commands with multiple responses to pick from
+	{5, []string{
+		`CREATE TABLE IF NOT EXISTS custom_command_responses (
+			channel  VARCHAR(64) NOT NULL,
+			command  VARCHAR(64) NOT NULL,
+			position INTEGER NOT NULL,
+			message  TEXT NOT NULL,
+			PRIMARY KEY (channel, command, position)
+		)`,
+	}},
+
+	// the timers plugin
+	{6, []string{
+		`CREATE TABLE IF NOT EXISTS timers (
+			channel VARCHAR(64) NOT NULL,
+			name    VARCHAR(64) NOT NULL,
+			message TEXT NOT NULL,
+			seconds INTEGER NOT NULL,
+			PRIMARY KEY (channel, name)
+		)`,
+	}},
+
+	// timers staying quiet unless there has been enough chat since their last post
+	{7, []string{
+		`ALTER TABLE timers ADD COLUMN min_lines INTEGER NOT NULL DEFAULT 0`,
+	}},
+
+	// the quotes plugin
+	{8, []string{
+		`CREATE TABLE IF NOT EXISTS quotes (
+			channel  VARCHAR(64) NOT NULL,
+			id       INTEGER NOT NULL,
+			text     TEXT NOT NULL,
+			added_by VARCHAR(64) NOT NULL,
+			added_at DATETIME NOT NULL,
+			PRIMARY KEY (channel, id)
+		)`,
+	}},
+
+	// ACL groups defined by the channels
+	{9, []string{
+		`CREATE TABLE IF NOT EXISTS acl_groups (
+			channel VARCHAR(64) NOT NULL,
+			name    VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, name)
+		)`,
+		`CREATE TABLE IF NOT EXISTS acl_group_members (
+			channel    VARCHAR(64) NOT NULL,
+			group_name VARCHAR(64) NOT NULL,
+			username   VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, group_name, username)
+		)`,
+	}},
+
+	// temporary permissions; expires is a Unix timestamp, 0 for permanent ones
+	{10, []string{
+		`ALTER TABLE acl ADD COLUMN expires BIGINT NOT NULL DEFAULT 0`,
+	}},
+
+	// the banphrase plugin
+	{11, []string{
+		`CREATE TABLE IF NOT EXISTS banphrases (
+			channel VARCHAR(64) NOT NULL,
+			pattern VARCHAR(191) NOT NULL,
+			seconds INTEGER NOT NULL,
+			PRIMARY KEY (channel, pattern)
+		)`,
+	}},
+
+	// the command_stats plugin
+	{12, []string{
+		`CREATE TABLE IF NOT EXISTS command_stats (
+			channel VARCHAR(64) NOT NULL,
+			command VARCHAR(64) NOT NULL,
+			counter INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (channel, command)
+		)`,
+	}},
+
+	// per-channel settings like the command prefix
+	{13, []string{
+		`CREATE TABLE IF NOT EXISTS channel_settings (
+			channel VARCHAR(64) NOT NULL,
+			name    VARCHAR(64) NOT NULL,
+			value   VARCHAR(255) NOT NULL,
+			PRIMARY KEY (channel, name)
+		)`,
+	}},
+
+	// the key/value store for plugins
+	{14, []string{
+		`CREATE TABLE IF NOT EXISTS plugin_kv (
+			plugin  VARCHAR(64) NOT NULL,
+			channel VARCHAR(64) NOT NULL,
+			keyname VARCHAR(128) NOT NULL,
+			value   TEXT NOT NULL,
+			PRIMARY KEY (plugin, channel, keyname)
+		)`,
+	}},
+
+	// the points plugin
+	{15, []string{
+		`CREATE TABLE IF NOT EXISTS points (
+			channel  VARCHAR(64) NOT NULL,
+			username VARCHAR(64) NOT NULL,
+			balance  INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (channel, username)
+		)`,
+	}},
+
+	// the seen plugin; timestamps are Unix timestamps
+	{16, []string{
+		`CREATE TABLE IF NOT EXISTS last_seen (
+			channel  VARCHAR(64) NOT NULL,
+			username VARCHAR(64) NOT NULL,
+			seen_at  BIGINT NOT NULL,
+			PRIMARY KEY (channel, username)
+		)`,
+	}},
+
+	// the markers plugin; stream_offset is in seconds and -1 if the stream was
+	// not live, created_at is a Unix timestamp
+	{17, []string{
+		`CREATE TABLE IF NOT EXISTS stream_markers (
+			channel       VARCHAR(64) NOT NULL,
+			id            INTEGER NOT NULL,
+			label         TEXT NOT NULL,
+			added_by      VARCHAR(64) NOT NULL,
+			created_at    BIGINT NOT NULL,
+			stream_offset INTEGER NOT NULL,
+			PRIMARY KEY (channel, id)
+		)`,
+	}},
+
+	// custom commands sharing their cooldown
+	{18, []string{
+		`CREATE TABLE IF NOT EXISTS custom_command_groups (
+			channel    VARCHAR(64) NOT NULL,
+			command    VARCHAR(64) NOT NULL,
+			group_name VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, command)
+		)`,
+	}},
+
+	// the mod_log plugin; duration is in seconds (0 for anything but timeouts),
+	// moderator is empty if Twitch did not tell who did it
+	{19, []string{
+		`CREATE TABLE IF NOT EXISTS mod_log (
+			channel    VARCHAR(64) NOT NULL,
+			id         INTEGER NOT NULL,
+			username   VARCHAR(64) NOT NULL,
+			action     VARCHAR(16) NOT NULL,
+			duration   INTEGER NOT NULL,
+			moderator  VARCHAR(64) NOT NULL,
+			message    TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			PRIMARY KEY (channel, id)
+		)`,
+	}},
+
+	// fuzzy banphrases; fuzziness is the number of typos to tolerate, 0 for
+	// phrases that are regular expressions
+	{20, []string{
+		`ALTER TABLE banphrases ADD COLUMN fuzziness INTEGER NOT NULL DEFAULT 0`,
+	}},
+
+	// custom commands telling users about their cooldown (once per cooldown)
+	{21, []string{
+		`ALTER TABLE custom_commands ADD COLUMN warn_cooldown INTEGER NOT NULL DEFAULT 0`,
+	}},
+
+	// commands hidden in single channels, without the trigger
+	{22, []string{
+		`CREATE TABLE IF NOT EXISTS disabled_commands (
+			channel VARCHAR(64) NOT NULL,
+			command VARCHAR(64) NOT NULL,
+			PRIMARY KEY (channel, command)
+		)`,
+	}},
+}
+
+// Migrate applies all migrations that have not yet been applied and returns
+// how many it applied. It is safe to call Migrate from multiple bots at once:
+// on MySQL, an advisory lock keeps them from stepping on each other, SQLite
+// does not allow concurrent writes anyway.
+func Migrate(db *sqlx.DB) (int, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	// MySQL commits implicitly on every DDL statement, so the transaction
+	// alone is no protection; the lock is held by the transaction's connection.
+	if db.DriverName() == "mysql" {
+		locked := 0
+
+		err = tx.Get(&locked, "SELECT GET_LOCK('kabukibot_migrations', 60)")
+		if err == nil && locked != 1 {
+			err = errors.New("Could not acquire the lock for running database migrations.")
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	applied, err := migrate(tx)
+
+	// the lock belongs to the connection, which outlives the transaction
+	if db.DriverName() == "mysql" {
+		tx.Exec("SELECT RELEASE_LOCK('kabukibot_migrations')")
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return applied, tx.Commit()
+}
+
+func migrate(tx *sqlx.Tx) (int, error) {
+	_, err := tx.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL, PRIMARY KEY (version))")
+	if err != nil {
+		return 0, err
+	}
+
+	versions := make([]int, 0)
+
+	err = tx.Select(&versions, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0, err
+	}
+
+	done := make(map[int]bool)
+	for _, version := range versions {
+		done[version] = true
+	}
+
+	applied := 0
+
+	for _, m := range migrations {
+		if done[m.version] {
+			continue
+		}
+
+		for _, statement := range m.statements {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				return applied, err
+			}
+		}
+
+		_, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", m.version)
+		if err != nil {
+			return applied, err
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
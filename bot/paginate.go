@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageSizeSetting decides how many items list commands show at once.
+const PageSizeSetting = "core.page_size"
+
+const defaultPageSize = 20
+
+// A Page is one window into a list that is too long for a single response.
+// Numbers start at 1.
+type Page struct {
+	Items  []string
+	Number int
+	Total  int
+}
+
+// Paginate cuts the items into pages of the given size and returns the
+// requested one. Pages before the first or after the last one are clamped, so
+// users always get something to look at.
+func Paginate(items []string, page int, size int) Page {
+	if size < 1 {
+		size = defaultPageSize
+	}
+
+	total := (len(items) + size - 1) / size
+	if total < 1 {
+		total = 1
+	}
+
+	if page < 1 {
+		page = 1
+	} else if page > total {
+		page = total
+	}
+
+	start := (page - 1) * size
+	end := start + size
+
+	if end > len(items) {
+		end = len(items)
+	}
+
+	return Page{items[start:end], page, total}
+}
+
+// Footer is "page X of Y", or empty if everything fits on one page.
+func (self Page) Footer() string {
+	if self.Total < 2 {
+		return ""
+	}
+
+	return fmt.Sprintf("page %d of %d", self.Number, self.Total)
+}
+
+// Join lists the page's items like HumanJoin does and appends the footer.
+func (self Page) Join(glue string) string {
+	result := HumanJoin(self.Items, glue)
+
+	if footer := self.Footer(); len(footer) > 0 {
+		result += " (" + footer + ")"
+	}
+
+	return result
+}
+
+// PageNumber reads a page number as given by users, like "2" or "#2"; anything
+// else means the first page.
+func PageNumber(arg string) int {
+	page, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return 1
+	}
+
+	return page
+}
@@ -1,6 +1,11 @@
 package bot
 
-import "github.com/sgt-kabukiman/kabukibot/twitch"
+import (
+	"path"
+	"reflect"
+
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
 
 type Plugin interface {
 	Name() string
@@ -21,12 +26,126 @@ type Plugin interface {
 // 	Unload(*twitch.Channel, *Kabukibot, Dispatcher)
 // }
 
+// Plugins can declare which other plugins they need, by the names they return
+// from Name(). Core plugins like the ACL do not have a name, so they are
+// referred to by their package name ("acl") instead.
+type dependentPlugin interface {
+	Dependencies() []string
+}
+
+// pluginName returns the plugin's name, or its package name for core plugins
+func pluginName(plugin Plugin) string {
+	if name := plugin.Name(); len(name) > 0 {
+		return name
+	}
+
+	t := reflect.TypeOf(plugin)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return path.Base(t.PkgPath())
+}
+
+// Plugins that copied settings from the configuration in Setup can implement
+// this to be notified when the configuration has been reloaded.
+type reconfigurablePlugin interface {
+	Reconfigure(*Configuration)
+}
+
+// Plugins are asked to handle messages in the order they have been added to the
+// bot, unless they define a priority (the default is 0). Higher priorities come
+// first, e.g. to ignore blacklisted users before any command is handled.
+type prioritizedPlugin interface {
+	Priority() int
+}
+
+// ModerationPriority is for plugins that time out users; they see messages
+// before any command can respond to them, but after blacklisted users have
+// been filtered out.
+const ModerationPriority = 50
+
+func pluginPriority(plugin Plugin) int {
+	asserted, okay := plugin.(prioritizedPlugin)
+	if okay {
+		return asserted.Priority()
+	}
+
+	return 0
+}
+
+// Plugins (not their workers, as whispers are not tied to a channel) can
+// implement this to receive whispers. The whisper is wrapped in a TextMessage
+// without a channel, so the usual command helpers work; responses are whispered
+// back to the user.
+type whisperPlugin interface {
+	HandleWhisper(*TextMessage, Sender)
+}
+
+// PluginWorker lifecycle: Enable is called when the channel worker starts or the
+// plugin gets enabled, Disable when it gets disabled. When leaving a channel or
+// shutting down, enabled workers are disabled first, then Part or Shutdown is
+// called on every worker. Reload is only called on enabled workers and should
+// re-read whatever Enable loaded, so that changes made to the database by hand
+// take effect.
 type PluginWorker interface {
 	Enable()
 	Disable()
+	Reload()
 	Part()
 	Shutdown()
 	Permissions() []string
+	Commands() []string // the non-prefixed commands the worker handles, used for !commands
+}
+
+// Workers can tell which permission one of their commands requires, so that
+// !commands only lists what a user can actually run. Commands without a
+// permission can be used by everyone.
+type guardedWorker interface {
+	CommandPermission(string) string
+}
+
+func CommandPermission(worker PluginWorker, command string) string {
+	asserted, okay := worker.(guardedWorker)
+	if okay {
+		return asserted.CommandPermission(command)
+	}
+
+	return ""
+}
+
+// CommandInfo describes a command for !help. The usage only lists the
+// arguments, e.g. "<command> <text>".
+type CommandInfo struct {
+	Name        string
+	Description string
+	Usage       string
+	Permission  string
+}
+
+// Workers can describe their commands; commands they know nothing about
+// should return false.
+type describedWorker interface {
+	DescribeCommand(string) (CommandInfo, bool)
+}
+
+// DescribeCommand returns what the worker tells about the command. Workers that
+// do not describe their commands still have the command's permission filled in.
+func DescribeCommand(worker PluginWorker, command string) CommandInfo {
+	info := CommandInfo{}
+
+	asserted, okay := worker.(describedWorker)
+	if okay {
+		info, _ = asserted.DescribeCommand(command)
+	}
+
+	info.Name = command
+
+	if len(info.Permission) == 0 {
+		info.Permission = CommandPermission(worker, command)
+	}
+
+	return info
 }
 
 type pluginWorkerStruct struct {
@@ -49,6 +168,24 @@ type clearChatMessageWorker interface {
 	HandleClearChatMessage(*twitch.ClearChatMessage, Sender)
 }
 
+type clearMsgMessageWorker interface {
+	HandleClearMsgMessage(*twitch.ClearMsgMessage, Sender)
+}
+
 type subNotificationMessageWorker interface {
 	HandleSubscriberNotificationMessage(*twitch.SubscriberNotificationMessage, Sender)
 }
+
+type raidMessageWorker interface {
+	HandleRaidMessage(*twitch.RaidMessage, Sender)
+}
+
+type modeMessageWorker interface {
+	HandleModeMessage(*twitch.ModeMessage, Sender)
+}
+
+// rawLineWorkers see every IRC line for their channel before it is parsed,
+// which is mostly useful for debugging and for things the bot does not model.
+type rawLineWorker interface {
+	HandleRawLine(*twitch.RawLineMessage, Sender)
+}
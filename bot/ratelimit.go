@@ -0,0 +1,641 @@
+package bot
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+// Twitch allows 20 messages per 30 seconds, or 100 if the bot is a moderator in the
+// channel it sends to. Both limits apply to the account as a whole, so the higher one
+// also counts the messages sent under the lower one. Going over this gets the account
+// globally banned for a while.
+const (
+	defaultRateLimitMessages  = 20
+	defaultRateLimitModerator = 100
+	defaultRateLimitInterval  = 30
+)
+
+// JOINs are limited separately, to 20 per 10 seconds.
+const (
+	joinRateLimitJoins    = 20
+	joinRateLimitInterval = 10 * time.Second
+)
+
+// Whispers are limited separately as well: to 100 per minute, and to 40 distinct
+// recipients per day.
+const (
+	defaultWhisperLimitMessages          = 100
+	defaultWhisperLimitInterval          = 60
+	defaultWhisperLimitRecipients        = 40
+	defaultWhisperLimitRecipientInterval = 24 * 60 * 60
+)
+
+// Appended to a message that is identical to the previous one in the same channel.
+// It is an invisible tag character, so chatters do not notice it.
+const duplicateSuffix = " \U000E0000"
+
+type tokenBucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newTokenBucket(messages int, interval time.Duration, now time.Time) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(messages),
+		tokens:   float64(messages),
+		rate:     float64(messages) / interval.Seconds(),
+		last:     now,
+	}
+}
+
+// refill adds the tokens that have accumulated since the bucket was last used.
+func (self *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(self.last).Seconds()
+	self.tokens = math.Min(self.capacity, self.tokens+elapsed*self.rate)
+	self.last = now
+}
+
+// delay returns how long to wait until a token is available; the bucket must
+// have been refilled before.
+func (self *tokenBucket) delay() time.Duration {
+	if self.tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - self.tokens) / self.rate * float64(time.Second))
+}
+
+// take consumes a token if one is available and returns 0. Otherwise it returns how
+// long to wait until the next token becomes available.
+func (self *tokenBucket) take(now time.Time) time.Duration {
+	return takeAll(now, self)
+}
+
+// resize changes the limits, but keeps the tokens that are left (as far as they
+// fit), so that changing the configuration does not allow a new burst.
+func (self *tokenBucket) resize(messages int, interval time.Duration, now time.Time) {
+	self.refill(now)
+
+	self.capacity = float64(messages)
+	self.rate = float64(messages) / interval.Seconds()
+	self.tokens = math.Min(self.capacity, self.tokens)
+}
+
+// reset fills the bucket up.
+func (self *tokenBucket) reset(now time.Time) {
+	self.tokens = self.capacity
+	self.last = now
+}
+
+// takeAll consumes a token from every bucket if all of them have one and returns
+// 0. Otherwise nothing is consumed and it returns how long to wait until all of
+// them have a token.
+func takeAll(now time.Time, buckets ...*tokenBucket) time.Duration {
+	delay := time.Duration(0)
+
+	for _, bucket := range buckets {
+		bucket.refill(now)
+
+		if d := bucket.delay(); d > delay {
+			delay = d
+		}
+	}
+
+	if delay > 0 {
+		return delay
+	}
+
+	for _, bucket := range buckets {
+		bucket.tokens--
+	}
+
+	return 0
+}
+
+// available returns how many tokens there are right now, without taking one.
+func (self *tokenBucket) available(now time.Time) float64 {
+	return math.Min(self.capacity, self.tokens+now.Sub(self.last).Seconds()*self.rate)
+}
+
+type rateLimitedItem struct {
+	message   twitch.OutgoingMessage
+	moderator bool
+	signal    chan bool
+}
+
+// The rateLimiter sits between all channel senders and the Twitch client. Messages
+// that would exceed the limit are queued and sent as soon as tokens refill instead
+// of being dropped.
+type rateLimiter struct {
+	client    twitch.Client
+	metrics   *metrics
+	normal    *tokenBucket // messages to channels where the bot is no moderator
+	moderator *tokenBucket // all messages
+	queue     chan rateLimitedItem
+	stop      chan struct{}
+	buckets   sync.Mutex        // configure and setClock can be called while working
+	dedupe    bool              // guarded by buckets as well
+	lastTexts map[string]string // guarded by buckets as well
+	pending   int64             // queued messages that have not been sent yet
+	now       func() time.Time
+	after     func(time.Duration) <-chan time.Time
+}
+
+func newRateLimiter(client twitch.Client, config *Configuration, metrics *metrics) *rateLimiter {
+	limiter := &rateLimiter{
+		client:    client,
+		metrics:   metrics,
+		queue:     make(chan rateLimitedItem, 100),
+		stop:      make(chan struct{}),
+		lastTexts: make(map[string]string),
+		now:       time.Now,
+		after:     time.After,
+	}
+
+	limiter.configure(config)
+
+	return limiter
+}
+
+func (self *rateLimiter) configure(config *Configuration) {
+	messages := config.RateLimit.Messages
+	if messages <= 0 {
+		messages = defaultRateLimitMessages
+	}
+
+	moderator := config.RateLimit.Moderator
+	if moderator <= 0 {
+		moderator = defaultRateLimitModerator
+	}
+
+	interval := config.RateLimit.Interval
+	if interval <= 0 {
+		interval = defaultRateLimitInterval
+	}
+
+	seconds := time.Duration(interval) * time.Second
+
+	self.buckets.Lock()
+	defer self.buckets.Unlock()
+
+	now := self.now()
+
+	if self.normal == nil {
+		self.normal = newTokenBucket(messages, seconds, now)
+		self.moderator = newTokenBucket(moderator, seconds, now)
+	} else {
+		self.normal.resize(messages, seconds, now)
+		self.moderator.resize(moderator, seconds, now)
+	}
+
+	self.dedupe = config.DeduplicateMessages
+}
+
+// setClock replaces the clock and starts over with full buckets.
+func (self *rateLimiter) setClock(now func() time.Time, after func(time.Duration) <-chan time.Time) {
+	self.buckets.Lock()
+	defer self.buckets.Unlock()
+
+	self.now = now
+	self.after = after
+	self.normal.reset(now())
+	self.moderator.reset(now())
+}
+
+func (self *rateLimiter) Send(msg twitch.OutgoingMessage, moderator bool) <-chan bool {
+	signal := make(chan bool, 1)
+
+	atomic.AddInt64(&self.pending, 1)
+
+	select {
+	case self.queue <- rateLimitedItem{msg, moderator, signal}:
+	case <-self.stop:
+		atomic.AddInt64(&self.pending, -1)
+		signal <- false
+		close(signal)
+	}
+
+	return signal
+}
+
+func (self *rateLimiter) Work() {
+	for {
+		select {
+		case item := <-self.queue:
+			if !self.wait(item.moderator) {
+				atomic.AddInt64(&self.pending, -1)
+				item.signal <- false
+				close(item.signal)
+				return
+			}
+
+			sent := self.client.Send(self.deduplicate(item.message))
+
+			go func(signal chan bool) {
+				okay := <-sent
+				if okay {
+					self.metrics.messageSent()
+				}
+
+				atomic.AddInt64(&self.pending, -1)
+				signal <- okay
+				close(signal)
+			}(item.signal)
+
+		case <-self.stop:
+			return
+		}
+	}
+}
+
+// deduplicate alters a chat message if it is the same as the last one sent to
+// its channel. Chat commands like .ban are never touched.
+func (self *rateLimiter) deduplicate(msg twitch.OutgoingMessage) twitch.OutgoingMessage {
+	text, okay := msg.(twitch.TextMessage)
+	if !okay || strings.HasPrefix(text.Text, ".") || strings.HasPrefix(text.Text, "/") {
+		return msg
+	}
+
+	self.buckets.Lock()
+	defer self.buckets.Unlock()
+
+	if self.dedupe && self.lastTexts[text.Channel] == text.Text && utf8.RuneCountInString(text.Text+duplicateSuffix) <= MaxMessageLength {
+		text.Text += duplicateSuffix
+	}
+
+	self.lastTexts[text.Channel] = text.Text
+
+	return text
+}
+
+// Flush waits until all queued messages have been sent, but at most for the
+// given time. It returns false if messages were still pending.
+func (self *rateLimiter) Flush(timeout time.Duration) bool {
+	deadline := time.After(timeout)
+
+	for atomic.LoadInt64(&self.pending) > 0 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			return false
+		}
+	}
+
+	return true
+}
+
+// headroom returns how many messages could be sent right now in channels
+// where the bot is no moderator, and how many at most.
+func (self *rateLimiter) headroom() (int, int) {
+	self.buckets.Lock()
+	defer self.buckets.Unlock()
+
+	now := self.now()
+
+	return int(math.Min(self.normal.available(now), self.moderator.available(now))), int(self.normal.capacity)
+}
+
+// forget drops what is remembered about a channel after leaving it
+func (self *rateLimiter) forget(channel string) {
+	self.buckets.Lock()
+	delete(self.lastTexts, channel)
+	self.buckets.Unlock()
+}
+
+func (self *rateLimiter) Stop() {
+	close(self.stop)
+}
+
+// wait blocks until a token is available; returns false if the limiter was stopped
+func (self *rateLimiter) wait(moderator bool) bool {
+	for {
+		self.buckets.Lock()
+		delay := time.Duration(0)
+
+		if moderator {
+			delay = takeAll(self.now(), self.moderator)
+		} else {
+			delay = takeAll(self.now(), self.normal, self.moderator)
+		}
+
+		after := self.after
+		self.buckets.Unlock()
+
+		if delay == 0 {
+			return true
+		}
+
+		select {
+		case <-after(delay):
+		case <-self.stop:
+			return false
+		}
+	}
+}
+
+// The joinLimiter paces JOINs, so that (re)joining many channels at once does
+// not exceed Twitch's limit. JOINs are sent in the order they were queued.
+type joinLimiter struct {
+	client twitch.Client
+	bucket *tokenBucket
+	queue  chan rateLimitedItem
+	stop   chan struct{}
+	clock  sync.Mutex // the clock can be replaced while working
+	now    func() time.Time
+	after  func(time.Duration) <-chan time.Time
+}
+
+func newJoinLimiter(client twitch.Client) *joinLimiter {
+	return &joinLimiter{
+		client: client,
+		bucket: newTokenBucket(joinRateLimitJoins, joinRateLimitInterval, time.Now()),
+		queue:  make(chan rateLimitedItem, 100),
+		stop:   make(chan struct{}),
+		now:    time.Now,
+		after:  time.After,
+	}
+}
+
+// setClock replaces the clock and starts over with a full bucket.
+func (self *joinLimiter) setClock(now func() time.Time, after func(time.Duration) <-chan time.Time) {
+	self.clock.Lock()
+	defer self.clock.Unlock()
+
+	self.now = now
+	self.after = after
+	self.bucket = newTokenBucket(joinRateLimitJoins, joinRateLimitInterval, now())
+}
+
+func (self *joinLimiter) Send(msg twitch.JoinMessage) <-chan bool {
+	signal := make(chan bool, 1)
+
+	select {
+	case self.queue <- rateLimitedItem{msg, false, signal}:
+	case <-self.stop:
+		signal <- false
+		close(signal)
+	}
+
+	return signal
+}
+
+func (self *joinLimiter) Work() {
+	for {
+		select {
+		case item := <-self.queue:
+			if !self.wait() {
+				item.signal <- false
+				close(item.signal)
+				return
+			}
+
+			sent := self.client.Send(item.message)
+
+			go func(signal chan bool) {
+				signal <- <-sent
+				close(signal)
+			}(item.signal)
+
+		case <-self.stop:
+			return
+		}
+	}
+}
+
+func (self *joinLimiter) Stop() {
+	close(self.stop)
+}
+
+// wait blocks until a JOIN may be sent; returns false if the limiter was stopped
+func (self *joinLimiter) wait() bool {
+	for {
+		self.clock.Lock()
+		delay := self.bucket.take(self.now())
+		after := self.after
+		self.clock.Unlock()
+
+		if delay == 0 {
+			return true
+		}
+
+		select {
+		case <-after(delay):
+		case <-self.stop:
+			return false
+		}
+	}
+}
+
+// The whisperLimiter paces whispers independently from chat messages. Besides
+// the number of whispers, Twitch limits how many different users the bot may
+// whisper to; whispers to users the bot has recently whispered to do not count
+// against that. Whispers are sent in the order they were queued, so one that
+// has to wait for a recipient slot holds up the ones behind it.
+type whisperLimiter struct {
+	client        twitch.Client
+	metrics       *metrics
+	bucket        *tokenBucket
+	messages      int
+	interval      time.Duration
+	recipients    map[string]time.Time // when the window for each recent recipient started
+	maxRecipients int
+	window        time.Duration
+	queue         chan rateLimitedItem
+	stop          chan struct{}
+	clock         sync.Mutex // guards the limits and the clock, which can change while working
+	pending       int64      // queued whispers that have not been sent yet
+	now           func() time.Time
+	after         func(time.Duration) <-chan time.Time
+}
+
+func newWhisperLimiter(client twitch.Client, config *Configuration, metrics *metrics) *whisperLimiter {
+	limiter := &whisperLimiter{
+		client:     client,
+		metrics:    metrics,
+		recipients: make(map[string]time.Time),
+		queue:      make(chan rateLimitedItem, 100),
+		stop:       make(chan struct{}),
+		now:        time.Now,
+		after:      time.After,
+	}
+
+	limiter.configure(config)
+
+	return limiter
+}
+
+func (self *whisperLimiter) configure(config *Configuration) {
+	messages := config.WhisperLimit.Messages
+	if messages <= 0 {
+		messages = defaultWhisperLimitMessages
+	}
+
+	interval := config.WhisperLimit.Interval
+	if interval <= 0 {
+		interval = defaultWhisperLimitInterval
+	}
+
+	recipients := config.WhisperLimit.Recipients
+	if recipients <= 0 {
+		recipients = defaultWhisperLimitRecipients
+	}
+
+	window := config.WhisperLimit.RecipientInterval
+	if window <= 0 {
+		window = defaultWhisperLimitRecipientInterval
+	}
+
+	self.clock.Lock()
+	defer self.clock.Unlock()
+
+	self.messages = messages
+	self.interval = time.Duration(interval) * time.Second
+	self.maxRecipients = recipients
+	self.window = time.Duration(window) * time.Second
+
+	// a reload must not hand out a fresh set of whispers
+	if self.bucket == nil {
+		self.bucket = newTokenBucket(self.messages, self.interval, self.now())
+	} else {
+		self.bucket.resize(self.messages, self.interval, self.now())
+	}
+}
+
+// setClock replaces the clock and starts over with a full bucket and nobody
+// having been whispered to.
+func (self *whisperLimiter) setClock(now func() time.Time, after func(time.Duration) <-chan time.Time) {
+	self.clock.Lock()
+	defer self.clock.Unlock()
+
+	self.now = now
+	self.after = after
+	self.bucket = newTokenBucket(self.messages, self.interval, now())
+	self.recipients = make(map[string]time.Time)
+}
+
+func (self *whisperLimiter) Send(msg twitch.WhisperMessage) <-chan bool {
+	signal := make(chan bool, 1)
+
+	atomic.AddInt64(&self.pending, 1)
+
+	select {
+	case self.queue <- rateLimitedItem{msg, false, signal}:
+	case <-self.stop:
+		atomic.AddInt64(&self.pending, -1)
+		signal <- false
+		close(signal)
+	}
+
+	return signal
+}
+
+func (self *whisperLimiter) Work() {
+	for {
+		select {
+		case item := <-self.queue:
+			if !self.wait(item.message.(twitch.WhisperMessage).User) {
+				atomic.AddInt64(&self.pending, -1)
+				item.signal <- false
+				close(item.signal)
+				return
+			}
+
+			sent := self.client.Send(item.message)
+
+			go func(signal chan bool) {
+				okay := <-sent
+				if okay {
+					self.metrics.messageSent()
+				}
+
+				atomic.AddInt64(&self.pending, -1)
+				signal <- okay
+				close(signal)
+			}(item.signal)
+
+		case <-self.stop:
+			return
+		}
+	}
+}
+
+// Flush waits until all queued whispers have been sent, but at most for the
+// given time. It returns false if whispers were still pending.
+func (self *whisperLimiter) Flush(timeout time.Duration) bool {
+	deadline := time.After(timeout)
+
+	for atomic.LoadInt64(&self.pending) > 0 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *whisperLimiter) Stop() {
+	close(self.stop)
+}
+
+// wait blocks until the user may be whispered to; returns false if the limiter
+// was stopped
+func (self *whisperLimiter) wait(user string) bool {
+	user = strings.ToLower(user)
+
+	for {
+		self.clock.Lock()
+		delay := self.recipientDelay(user, self.now())
+		if delay == 0 {
+			delay = self.bucket.take(self.now())
+
+			if delay == 0 {
+				if _, known := self.recipients[user]; !known {
+					self.recipients[user] = self.now()
+				}
+			}
+		}
+		after := self.after
+		self.clock.Unlock()
+
+		if delay == 0 {
+			return true
+		}
+
+		select {
+		case <-after(delay):
+		case <-self.stop:
+			return false
+		}
+	}
+}
+
+// recipientDelay forgets recipients whose window has passed and returns how
+// long to wait until the user can be whispered to. Must be called with the
+// clock locked.
+func (self *whisperLimiter) recipientDelay(user string, now time.Time) time.Duration {
+	oldest := time.Time{}
+
+	for recipient, since := range self.recipients {
+		if !now.Before(since.Add(self.window)) {
+			delete(self.recipients, recipient)
+		} else if oldest.IsZero() || since.Before(oldest) {
+			oldest = since
+		}
+	}
+
+	if _, known := self.recipients[user]; known || len(self.recipients) < self.maxRecipients {
+		return 0
+	}
+
+	return oldest.Add(self.window).Sub(now)
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+// Roles are what Twitch told us about a user the last time they chatted.
+type Roles struct {
+	Moderator  bool
+	Subscriber bool
+	VIP        bool
+}
+
+// The Roster keeps track of the moderators, subscribers and VIPs of a channel,
+// as far as we have seen them chatting. It is emptied when leaving the channel.
+// Reading from a nil Roster is fine, it just knows nobody.
+type Roster struct {
+	roles map[string]Roles
+	mutex sync.RWMutex
+}
+
+func NewRoster() *Roster {
+	return &Roster{roles: make(map[string]Roles)}
+}
+
+// Update records the user's roles according to their latest message.
+func (self *Roster) Update(user twitch.User, tags twitch.Tags) {
+	roles := Roles{
+		Moderator:  user.Type == twitch.Moderator || user.Type == twitch.GlobalModerator || tags.IsMod(),
+		Subscriber: user.Subscriber || tags.IsSubscriber(),
+		VIP:        tags.IsVIP(),
+	}
+
+	self.mutex.Lock()
+	self.roles[strings.ToLower(user.Name)] = roles
+	self.mutex.Unlock()
+}
+
+// SetModerator is used when (un)modding users, as that happens without them chatting.
+func (self *Roster) SetModerator(user string, moderator bool) {
+	user = strings.ToLower(user)
+
+	self.mutex.Lock()
+	roles := self.roles[user]
+	roles.Moderator = moderator
+	self.roles[user] = roles
+	self.mutex.Unlock()
+}
+
+func (self *Roster) Roles(user string) (Roles, bool) {
+	if self == nil {
+		return Roles{}, false
+	}
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	roles, exists := self.roles[strings.ToLower(user)]
+
+	return roles, exists
+}
+
+func (self *Roster) Moderators() []string {
+	return self.filter(func(r Roles) bool { return r.Moderator })
+}
+
+func (self *Roster) Subscribers() []string {
+	return self.filter(func(r Roles) bool { return r.Subscriber })
+}
+
+func (self *Roster) VIPs() []string {
+	return self.filter(func(r Roles) bool { return r.VIP })
+}
+
+// Clear forgets everyone.
+func (self *Roster) Clear() {
+	self.mutex.Lock()
+	self.roles = make(map[string]Roles)
+	self.mutex.Unlock()
+}
+
+func (self *Roster) filter(matches func(Roles) bool) []string {
+	result := make([]string, 0)
+
+	if self == nil {
+		return result
+	}
+
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	for user, roles := range self.roles {
+		if matches(roles) {
+			result = append(result, user)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
@@ -2,56 +2,282 @@ package bot
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/sorcix/irc"
 
 	"github.com/sgt-kabukiman/kabukibot/twitch"
 )
 
+// Twitch drops messages (and whispers) that are longer than this, so longer
+// texts are sent as multiple messages.
+const MaxMessageLength = 500
+
 type Sender interface {
 	Send(twitch.OutgoingMessage) <-chan bool
 	SendText(string) <-chan bool
+	SendAction(string) <-chan bool
 	Respond(string) <-chan bool
+	SendWhisper(string, string) <-chan bool
 	Ban(string) <-chan bool
-	Timeout(string, int) <-chan bool
+	Timeout(string, int, string) <-chan bool
+	SendCommand(string) <-chan bool
+	SendRaw(string) <-chan bool
+}
+
+// DatabaseError logs what went wrong and tells the user something generic, so
+// one failed query does not take the whole bot down.
+func DatabaseError(log Logger, sender Sender, format string, err error) {
+	log.Error(format, err.Error())
+	sender.Respond("something went wrong, please try again later.")
 }
 
 // If ever neccessary, this can be tied to a channelWorker
 // (e.g. if we were to have multiple IRC connections)
 type channelSender struct {
-	twitch  twitch.Client
-	channel string
+	limiter   *rateLimiter
+	whispers  *whisperLimiter
+	filters   *outboundFilters
+	dryRun    *dryRun
+	channel   string
+	moderator bool // decides which rate limit applies
+	mutex     sync.RWMutex
 }
 
-func newChannelSender(client twitch.Client, channel string) *channelSender {
-	return &channelSender{client, channel}
+func newChannelSender(limiter *rateLimiter, whispers *whisperLimiter, filters *outboundFilters, dryRun *dryRun, channel string, moderator bool) *channelSender {
+	return &channelSender{limiter: limiter, whispers: whispers, filters: filters, dryRun: dryRun, channel: channel, moderator: moderator}
+}
+
+func (self *channelSender) isModerator() bool {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	return self.moderator
+}
+
+// setModerator returns whether the status actually changed
+func (self *channelSender) setModerator(moderator bool) bool {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	changed := self.moderator != moderator
+	self.moderator = moderator
+
+	return changed
 }
 
 func (self *channelSender) newResponder(msg *TextMessage) *responder {
 	return &responder{self, msg}
 }
 
+// Send is what all other methods end up calling, so in dry-run mode, texts,
+// whispers and moderation commands alike are only logged; they count as sent.
 func (self *channelSender) Send(msg twitch.OutgoingMessage) <-chan bool {
-	return self.twitch.Send(msg)
+	if self.dryRun.intercept(self.channel, msg) {
+		signal := make(chan bool, 1)
+		signal <- true
+		close(signal)
+
+		return signal
+	}
+
+	// whispers have their own limits
+	if whisper, okay := msg.(twitch.WhisperMessage); okay {
+		return self.whispers.Send(whisper)
+	}
+
+	return self.limiter.Send(msg, self.isModerator())
 }
 
 func (self *channelSender) SendText(text string) <-chan bool {
+	return self.sendSplit(text, func(chunk string) twitch.OutgoingMessage {
+		return twitch.TextMessage{
+			Channel: self.channel,
+			Text:    chunk,
+		}
+	})
+}
+
+// SendAction sends the text as a "/me" action.
+func (self *channelSender) SendAction(text string) <-chan bool {
+	return self.sendSplit(text, func(chunk string) twitch.OutgoingMessage {
+		return twitch.TextMessage{
+			Channel: self.channel,
+			Text:    chunk,
+			Action:  "/me",
+		}
+	})
+}
+
+// SendCommand sends a chat command like ".emoteonly"; commands must never be
+// split, so they bypass SendText.
+func (self *channelSender) SendCommand(command string) <-chan bool {
 	return self.Send(twitch.TextMessage{
 		Channel: self.channel,
-		Text:    text,
+		Text:    command,
 	})
 }
 
+// sendSplit runs the text through the outbound filters and sends what is left
+// in as many messages as needed; the returned signal is only true if all of
+// them have been sent.
+func (self *channelSender) sendSplit(text string, build func(string) twitch.OutgoingMessage) <-chan bool {
+	text, keep := self.filters.apply(self.channel, text)
+	if !keep {
+		signal := make(chan bool, 1)
+		signal <- false
+		close(signal)
+
+		return signal
+	}
+
+	chunks := SplitMessage(text, MaxMessageLength)
+
+	if len(chunks) == 1 {
+		return self.Send(build(chunks[0]))
+	}
+
+	// the rate limiter keeps the order, so chunks are queued right away
+	signals := make([]<-chan bool, len(chunks))
+
+	for idx, chunk := range chunks {
+		signals[idx] = self.Send(build(chunk))
+	}
+
+	result := make(chan bool, 1)
+
+	go func() {
+		okay := true
+
+		for _, signal := range signals {
+			if !<-signal {
+				okay = false
+			}
+		}
+
+		result <- okay
+		close(result)
+	}()
+
+	return result
+}
+
 func (self *channelSender) Respond(text string) <-chan bool {
 	return self.SendText(text)
 }
 
+func (self *channelSender) SendWhisper(user string, text string) <-chan bool {
+	return self.sendSplit(text, func(chunk string) twitch.OutgoingMessage {
+		return twitch.WhisperMessage{
+			User: user,
+			Text: chunk,
+		}
+	})
+}
+
 func (self *channelSender) Ban(user string) <-chan bool {
-	return self.SendText(".ban " + user)
+	return self.SendCommand(".ban " + user)
+}
+
+// the reason is shown to the user and the moderators; it can be left empty
+func (self *channelSender) Timeout(user string, seconds int, reason string) <-chan bool {
+	command := fmt.Sprintf(".timeout %s %d", user, seconds)
+
+	if len(reason) > 0 {
+		command += " " + reason
+	}
+
+	return self.SendCommand(command)
+}
+
+// SendRaw sends an IRC line as it is, for commands the bot does not model. The
+// line is still subject to the rate limit. Unparsable lines are not sent.
+func (self *channelSender) SendRaw(line string) <-chan bool {
+	msg := irc.ParseMessage(line)
+	if msg == nil {
+		signal := make(chan bool, 1)
+		signal <- false
+		close(signal)
+
+		return signal
+	}
+
+	return self.Send(twitch.RawMessage{Message: *msg})
+}
+
+// SplitMessage cuts the text into chunks of at most limit characters. Texts are
+// split between words; only words that are longer than the limit are cut.
+// Twitch runs messages starting with "/" or "." as chat commands, so chunks
+// that would start like that get a zero-width space in front; otherwise,
+// users could make the bot run commands by padding the text in e.g. a custom
+// command's $(args) until the command lands right at a split point.
+func SplitMessage(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit && !isChatCommand(text) {
+		return []string{text}
+	}
+
+	if utf8.RuneCountInString(text) < limit && isChatCommand(text) {
+		return []string{commandBreaker + text}
+	}
+
+	chunks := make([]string, 0)
+	current := make([]string, 0)
+	length := 0
+
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, " "))
+			current = current[:0]
+			length = 0
+		}
+	}
+
+	for _, word := range strings.Fields(text) {
+		runes := []rune(word)
+
+		for len(runes) > 0 {
+			// +1 for the space in front of the word
+			if length > 0 && length+1+len(runes) > limit {
+				flush()
+			}
+
+			if length == 0 && isChatCommand(string(runes)) {
+				runes = append([]rune(commandBreaker), runes...)
+			}
+
+			// words that are too long on their own get chunks of their own
+			if len(runes) > limit {
+				chunks = append(chunks, string(runes[:limit]))
+				runes = runes[limit:]
+				continue
+			}
+
+			if length > 0 {
+				length++
+			}
+
+			current = append(current, string(runes))
+			length += len(runes)
+			runes = nil
+		}
+	}
+
+	flush()
+
+	return chunks
 }
 
-func (self *channelSender) Timeout(user string, seconds int) <-chan bool {
-	return self.SendText(fmt.Sprintf(".timeout %s %d", user, seconds))
+const commandBreaker = "\u200B"
+
+// isChatCommand tells whether Twitch would run the text as a chat command;
+// leading whitespace does not help, as Twitch trims it.
+func isChatCommand(text string) bool {
+	text = strings.TrimLeft(text, " ")
+
+	return strings.HasPrefix(text, "/") || strings.HasPrefix(text, ".")
 }
 
 // a sender that is tied to a received message and can be used to transparently address the
@@ -69,14 +295,73 @@ func (self *responder) SendText(text string) <-chan bool {
 	return self.cn.SendText(text)
 }
 
+func (self *responder) SendAction(text string) <-chan bool {
+	return self.cn.SendAction(text)
+}
+
 func (self *responder) Respond(text string) <-chan bool {
 	return self.SendText(fmt.Sprintf("%s, %s", self.msg.User.Name, text))
 }
 
+func (self *responder) SendWhisper(user string, text string) <-chan bool {
+	return self.cn.SendWhisper(user, text)
+}
+
 func (self *responder) Ban(user string) <-chan bool {
-	return self.SendText(".ban " + user)
+	return self.cn.Ban(user)
+}
+
+// a sender for answering whispers; texts are whispered back to the user
+type whisperResponder struct {
+	cn   *channelSender
+	user string
+}
+
+func (self *whisperResponder) Send(msg twitch.OutgoingMessage) <-chan bool {
+	return self.cn.Send(msg)
+}
+
+func (self *whisperResponder) SendText(text string) <-chan bool {
+	return self.cn.SendWhisper(self.user, text)
+}
+
+// whispers cannot be actions, so the text is whispered as it is
+func (self *whisperResponder) SendAction(text string) <-chan bool {
+	return self.SendText(text)
+}
+
+func (self *whisperResponder) Respond(text string) <-chan bool {
+	return self.SendText(text)
+}
+
+func (self *whisperResponder) SendWhisper(user string, text string) <-chan bool {
+	return self.cn.SendWhisper(user, text)
+}
+
+func (self *whisperResponder) Ban(user string) <-chan bool {
+	return self.cn.Ban(user)
+}
+
+func (self *whisperResponder) Timeout(user string, seconds int, reason string) <-chan bool {
+	return self.cn.Timeout(user, seconds, reason)
+}
+
+func (self *whisperResponder) SendCommand(command string) <-chan bool {
+	return self.cn.SendCommand(command)
+}
+
+func (self *whisperResponder) SendRaw(line string) <-chan bool {
+	return self.cn.SendRaw(line)
+}
+
+func (self *responder) Timeout(user string, seconds int, reason string) <-chan bool {
+	return self.cn.Timeout(user, seconds, reason)
+}
+
+func (self *responder) SendCommand(command string) <-chan bool {
+	return self.cn.SendCommand(command)
 }
 
-func (self *responder) Timeout(user string, seconds int) <-chan bool {
-	return self.SendText(fmt.Sprintf(".timeout %s %d", user, seconds))
+func (self *responder) SendRaw(line string) <-chan bool {
+	return self.cn.SendRaw(line)
 }
@@ -0,0 +1,35 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		text     string
+		limit    int
+		expected []string
+	}{
+		{"short text", 20, []string{"short text"}},
+		{"abcd abcd abcd abcd", 10, []string{"abcd abcd", "abcd abcd"}},
+		{"ab abcdefghijklmn", 5, []string{"ab", "abcde", "fghij", "klmn"}},
+		{"abcd /mod", 9, []string{"abcd /mod"}},
+
+		// nothing may be run as a chat command, no matter where the text is split
+		{"/ban someone", 20, []string{"\u200B/ban someone"}},
+		{" .timeout someone", 20, []string{"\u200B .timeout someone"}},
+		{"abcd abcd /ban someone", 10, []string{"abcd abcd", "\u200B/ban", "someone"}},
+		{"abcd abcd abcd /ban", 14, []string{"abcd abcd abcd", "\u200B/ban"}},
+		{"abcdefghi/ban", 9, []string{"abcdefghi", "\u200B/ban"}},
+		{"/abcdefghi", 5, []string{"\u200B/abc", "defgh", "i"}},
+	}
+
+	for _, test := range tests {
+		chunks := SplitMessage(test.text, test.limit)
+
+		if !reflect.DeepEqual(chunks, test.expected) {
+			t.Errorf("expected %q to be split into %q, but got %q.", test.text, test.expected, chunks)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Setting is a per-channel value that moderators can change without the
+// plugin having to bring its own command for it. Values are stored as strings
+// in their normalized form, so "yes" is stored as "on" and "90s" as "1m30s".
+type Setting struct {
+	Default   string
+	normalize func(string) (string, error)
+}
+
+// Normalize validates the given value; the error describes what would have
+// been expected.
+func (self Setting) Normalize(value string) (string, error) {
+	return self.normalize(strings.TrimSpace(value))
+}
+
+// BoolSetting accepts on/off, yes/no, true/false and 1/0.
+func BoolSetting(def bool) Setting {
+	return Setting{formatBool(def), func(value string) (string, error) {
+		switch strings.ToLower(value) {
+		case "on", "yes", "true", "1":
+			return "on", nil
+		case "off", "no", "false", "0":
+			return "off", nil
+		}
+
+		return "", errors.New("expected on or off")
+	}}
+}
+
+// IntSetting accepts whole numbers from min to max (inclusive).
+func IntSetting(def int, min int, max int) Setting {
+	return Setting{strconv.Itoa(def), func(value string) (string, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < min || n > max {
+			return "", fmt.Errorf("expected a number between %d and %d", min, max)
+		}
+
+		return strconv.Itoa(n), nil
+	}}
+}
+
+// DurationSetting accepts durations like 30s or 2h from min to max (inclusive).
+func DurationSetting(def time.Duration, min time.Duration, max time.Duration) Setting {
+	return Setting{FormatDuration(def, false), func(value string) (string, error) {
+		d := ParseDuration(value, nil, nil)
+		if d == nil || *d < min || *d > max {
+			return "", fmt.Errorf("expected a duration between %s and %s", FormatDuration(min, false), FormatDuration(max, false))
+		}
+
+		return FormatDuration(*d, false), nil
+	}}
+}
+
+// StringSetting accepts any text up to maxLength characters.
+func StringSetting(def string, maxLength int) Setting {
+	return Setting{def, func(value string) (string, error) {
+		if len(value) == 0 || len([]rune(value)) > maxLength {
+			return "", fmt.Errorf("expected a text of up to %d characters", maxLength)
+		}
+
+		return value, nil
+	}}
+}
+
+func formatBool(b bool) string {
+	if b {
+		return "on"
+	}
+
+	return "off"
+}
+
+// Settings keeps track of which settings the plugins offer and caches their
+// values, which are kept in the plugin's namespace of the key/value store.
+type Settings struct {
+	storage  *Storage
+	settings map[string]Setting // by "plugin.key"
+	values   map[string]string  // by "#channel plugin.key"
+	mutex    sync.RWMutex
+}
+
+func NewSettings(storage *Storage) *Settings {
+	return &Settings{
+		storage:  storage,
+		settings: make(map[string]Setting),
+		values:   make(map[string]string),
+	}
+}
+
+// Register makes the setting available as plugin.key; plugins should do this
+// during their setup.
+func (self *Settings) Register(plugin string, key string, setting Setting) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.settings[plugin+"."+key] = setting
+}
+
+// Lookup finds a setting by its full name, like "troll.pyramid_height".
+func (self *Settings) Lookup(name string) (Setting, bool) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	setting, exists := self.settings[strings.ToLower(name)]
+
+	return setting, exists
+}
+
+// Names returns the full names of all registered settings, sorted.
+func (self *Settings) Names() []string {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	names := make([]string, 0, len(self.settings))
+	for name := range self.settings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Get returns the value of a setting in the channel, or its default.
+func (self *Settings) Get(channel string, name string) string {
+	name = strings.ToLower(name)
+	cacheKey := channel + " " + name
+
+	self.mutex.RLock()
+	value, cached := self.values[cacheKey]
+	setting := self.settings[name]
+	self.mutex.RUnlock()
+
+	if cached {
+		return value
+	}
+
+	plugin, key := splitSettingName(name)
+
+	value, exists := self.storage.Get(plugin, channel, key)
+	if !exists {
+		value = setting.Default
+	}
+
+	self.mutex.Lock()
+	self.values[cacheKey] = value
+	self.mutex.Unlock()
+
+	return value
+}
+
+// Set stores an already normalized value.
+func (self *Settings) Set(channel string, name string, value string) error {
+	name = strings.ToLower(name)
+	plugin, key := splitSettingName(name)
+
+	err := self.storage.Set(plugin, channel, key, value)
+	if err != nil {
+		return err
+	}
+
+	self.mutex.Lock()
+	self.values[channel+" "+name] = value
+	self.mutex.Unlock()
+
+	return nil
+}
+
+func (self *Settings) Bool(channel string, name string) bool {
+	return self.Get(channel, name) == "on"
+}
+
+func (self *Settings) Int(channel string, name string) int {
+	n, _ := strconv.Atoi(self.Get(channel, name))
+
+	return n
+}
+
+func (self *Settings) Duration(channel string, name string) time.Duration {
+	d := ParseDuration(self.Get(channel, name), nil, nil)
+	if d == nil {
+		return 0
+	}
+
+	return *d
+}
+
+func splitSettingName(name string) (string, string) {
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) < 2 {
+		return parts[0], ""
+	}
+
+	return parts[0], parts[1]
+}
@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrNoValue is returned by GetJSON if there is no value for the key.
+var ErrNoValue = errors.New("The value does not exist.")
+
+// The Storage is a simple key/value store for plugins that do not need their
+// own tables. Values are namespaced by plugin and channel, so two plugins can
+// use the same keys without stepping on each other's toes.
+type Storage struct {
+	db  *sqlx.DB
+	log Logger
+}
+
+func NewStorage(db *sqlx.DB, log Logger) *Storage {
+	return &Storage{db, log}
+}
+
+// Get returns the value and whether it exists.
+func (self *Storage) Get(plugin string, channel string, key string) (string, bool) {
+	values := make([]string, 0)
+
+	err := self.db.Select(&values, "SELECT value FROM plugin_kv WHERE plugin = ? AND channel = ? AND keyname = ?", plugin, channel, key)
+	if err != nil {
+		self.log.Error("Could not read %s's value '%s' in %s: %s", plugin, key, channel, err.Error())
+	}
+
+	if len(values) == 0 {
+		return "", false
+	}
+
+	return values[0], true
+}
+
+// Set creates or overwrites the value.
+func (self *Storage) Set(plugin string, channel string, key string, value string) error {
+	tx, err := self.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM plugin_kv WHERE plugin = ? AND channel = ? AND keyname = ?", plugin, channel, key)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO plugin_kv (plugin, channel, keyname, value) VALUES (?, ?, ?, ?)", plugin, channel, key, value)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes the value; deleting a value that does not exist is not an error.
+func (self *Storage) Delete(plugin string, channel string, key string) error {
+	_, err := self.db.Exec("DELETE FROM plugin_kv WHERE plugin = ? AND channel = ? AND keyname = ?", plugin, channel, key)
+
+	return err
+}
+
+// SetJSON stores the value as JSON.
+func (self *Storage) SetJSON(plugin string, channel string, key string, v interface{}) error {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return self.Set(plugin, channel, key, string(encoded))
+}
+
+// GetJSON decodes the stored JSON into out. If there is no value, ErrNoValue is
+// returned and out is left alone.
+func (self *Storage) GetJSON(plugin string, channel string, key string, out interface{}) error {
+	value, exists := self.Get(plugin, channel, key)
+	if !exists {
+		return ErrNoValue
+	}
+
+	return json.Unmarshal([]byte(value), out)
+}
+
+type kvRow struct {
+	Keyname string
+	Value   string
+}
+
+// All returns all values the plugin stored for the channel.
+func (self *Storage) All(plugin string, channel string) map[string]string {
+	list := make([]kvRow, 0)
+
+	err := self.db.Select(&list, "SELECT keyname, value FROM plugin_kv WHERE plugin = ? AND channel = ?", plugin, channel)
+	if err != nil {
+		self.log.Error("Could not read %s's values in %s: %s", plugin, channel, err.Error())
+	}
+
+	values := make(map[string]string, len(list))
+	for _, row := range list {
+		values[row.Keyname] = row.Value
+	}
+
+	return values
+}
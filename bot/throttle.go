@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"strings"
+	"time"
+)
+
+// commandThrottle limits how many commands a single user can send in a
+// channel within an interval, no matter which plugins handle them. A limit of
+// zero disables the throttle.
+type commandThrottle struct {
+	limit    int
+	interval time.Duration
+	now      func() time.Time
+	history  map[string][]time.Time
+}
+
+func newCommandThrottle(now func() time.Time) *commandThrottle {
+	return &commandThrottle{
+		now:     now,
+		history: make(map[string][]time.Time),
+	}
+}
+
+func (self *commandThrottle) enabled() bool {
+	return self.limit > 0 && self.interval > 0
+}
+
+func (self *commandThrottle) configure(limit int, interval time.Duration) {
+	self.limit = limit
+	self.interval = interval
+	self.history = make(map[string][]time.Time)
+}
+
+// allow records a command by the user and tells whether it may be handled.
+// Dropped commands are not recorded, so users are only held back for as long
+// as the interval lasts.
+func (self *commandThrottle) allow(user string) bool {
+	if !self.enabled() {
+		return true
+	}
+
+	now := self.now()
+	user = strings.ToLower(user)
+	cutoff := now.Add(-self.interval)
+	recent := make([]time.Time, 0, self.limit)
+
+	for _, t := range self.history[user] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	allowed := len(recent) < self.limit
+
+	if allowed {
+		recent = append(recent, now)
+	}
+
+	self.history[user] = recent
+	self.prune(cutoff)
+
+	return allowed
+}
+
+// forget users that have not sent a command in a while, so the map does not
+// grow forever in busy channels
+func (self *commandThrottle) prune(cutoff time.Time) {
+	for user, times := range self.history {
+		if len(times) == 0 || !times[len(times)-1].After(cutoff) {
+			delete(self.history, user)
+		}
+	}
+}
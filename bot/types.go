@@ -3,20 +3,52 @@ package bot
 import (
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/sgt-kabukiman/kabukibot/twitch"
 )
 
+// Commands start with this, unless the channel configured something else.
+const DefaultTrigger = "!"
+
 type TextMessage struct {
 	twitch.TextMessage
 
-	prefix    string
-	operator  string
-	processed bool
+	normalized string // the text after the inbound filters
+	prefix     string
+	trigger    string // what commands start with in the message's channel
+	operator   string
+	hidden     bool // the command has been disabled in the channel or throttled
+	processed  bool
+	stopped    bool
+}
+
+// Raw returns the text as it was received.
+func (self *TextMessage) Raw() string {
+	return self.Text
+}
+
+// Normalized returns the text after the inbound filters ran, which is what
+// commands are parsed from. Moderation should look at this rather than at the
+// raw text, as it is harder to trick.
+func (self *TextMessage) Normalized() string {
+	return self.normalized
+}
+
+// Trigger returns what commands start with, so responses can mention commands
+// the way users have to type them.
+func (self *TextMessage) Trigger() string {
+	if len(self.trigger) == 0 {
+		return DefaultTrigger
+	}
+
+	return self.trigger
 }
 
+// IsCommand is always false for commands disabled in the message's channel, so
+// that plugins treat them like any other text.
 func (self *TextMessage) IsCommand(cmd string) bool {
-	return strings.HasPrefix(self.Text, "!"+cmd)
+	return !self.hidden && strings.HasPrefix(self.normalized, self.Trigger()+cmd)
 }
 
 func (self *TextMessage) IsGlobalCommand(cmd string) bool {
@@ -35,6 +67,12 @@ func (self *TextMessage) IsFromOperator() bool {
 	return self.IsFrom(self.operator)
 }
 
+// IsAction tells whether the message was sent with "/me"; Text holds the text
+// without the ACTION wrapper in any case.
+func (self *TextMessage) IsAction() bool {
+	return self.Action == "/me"
+}
+
 func (self *TextMessage) IsFromBot() bool {
 	return self.User.Myself
 }
@@ -47,11 +85,33 @@ func (self *TextMessage) SetProcessed() {
 	self.processed = true
 }
 
-var commandRegex = regexp.MustCompile(`^!([a-zA-Z0-9_-]+)(?:\s+(.*))?$`)
+// StopPropagation keeps all following plugins from seeing the message at all,
+// e.g. because it got the user timed out. It also marks it as processed.
+func (self *TextMessage) StopPropagation() {
+	self.processed = true
+	self.stopped = true
+}
+
+func (self *TextMessage) IsPropagationStopped() bool {
+	return self.stopped
+}
+
+var commandRegex = regexp.MustCompile(`^([a-zA-Z0-9_-]+)(?:\s+(.*))?$`)
 var argSplitter = regexp.MustCompile(`\s+`)
 
+// parseCommand returns the command and its arguments, without the trigger
+func (self *TextMessage) parseCommand() []string {
+	trigger := self.Trigger()
+
+	if self.hidden || !strings.HasPrefix(self.normalized, trigger) {
+		return nil
+	}
+
+	return commandRegex.FindStringSubmatch(strings.TrimPrefix(self.normalized, trigger))
+}
+
 func (self *TextMessage) Command() string {
-	match := commandRegex.FindStringSubmatch(self.Text)
+	match := self.parseCommand()
 	if len(match) == 0 {
 		return ""
 	}
@@ -62,7 +122,7 @@ func (self *TextMessage) Command() string {
 func (self *TextMessage) Arguments() []string {
 	args := make([]string, 0)
 
-	match := commandRegex.FindStringSubmatch(self.Text)
+	match := self.parseCommand()
 	if len(match) == 0 {
 		return args
 	}
@@ -76,6 +136,53 @@ func (self *TextMessage) Arguments() []string {
 	return args
 }
 
+// QuotedArguments splits the arguments like Arguments, but keeps "quoted
+// strings" together (see TokenizeArguments). Only commands that document
+// quoting should use it, as the quotes themselves are lost.
+func (self *TextMessage) QuotedArguments() []string {
+	match := self.parseCommand()
+	if len(match) == 0 {
+		return make([]string, 0)
+	}
+
+	return TokenizeArguments(match[2])
+}
+
+// ArgumentText returns the arguments after the first skip ones as they were
+// typed, for commands that take free text like a message or a pattern.
+func (self *TextMessage) ArgumentText(skip int) string {
+	match := self.parseCommand()
+	if len(match) == 0 {
+		return ""
+	}
+
+	text := strings.TrimSpace(match[2])
+
+	for i := 0; i < skip && len(text) > 0; i++ {
+		end := strings.IndexFunc(text, unicode.IsSpace)
+		if end < 0 {
+			return ""
+		}
+
+		text = strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	}
+
+	return text
+}
+
+// RequireArgs checks that the command has been given at least min arguments. If
+// not, the user is told how to use the command and false is returned. The usage
+// describes the arguments only, like "<name> <text>".
+func (self *TextMessage) RequireArgs(min int, usage string, sender Sender) bool {
+	if len(self.Arguments()) >= min {
+		return true
+	}
+
+	sender.Respond("usage: " + self.Trigger() + self.Command() + " " + usage)
+
+	return false
+}
+
 // type Command interface {
 // 	twitch.Message
 
@@ -129,3 +236,13 @@ func (self *TextMessage) Arguments() []string {
 // 	Load(*twitch.Channel, *Kabukibot, Dispatcher)
 // 	Unload(*twitch.Channel, *Kabukibot, Dispatcher)
 // }
+
+// reconfiguration is handed to channel workers when the configuration has been reloaded
+type reconfiguration struct {
+	channel string
+	config  *Configuration
+}
+
+func (self reconfiguration) ChannelName() string {
+	return self.channel
+}
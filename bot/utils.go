@@ -3,6 +3,7 @@ package bot
 import (
 	"fmt"
 	"regexp"
+	"unicode"
 )
 
 import "strconv"
@@ -190,19 +191,134 @@ func secondsToTime(seconds int, compact bool) string {
 	return HumanJoin(list, ", ")
 }
 
+// FormatAge tells how long ago since was in calendar units, like "2 years, 3
+// months". Only the two largest units are given, as nobody cares about the
+// days when talking about years.
+func FormatAge(since time.Time, now time.Time) string {
+	since = since.UTC()
+	now = now.UTC()
+
+	years := now.Year() - since.Year()
+	months := int(now.Month()) - int(since.Month())
+	days := now.Day() - since.Day()
+
+	// the last day is not complete yet
+	if clock(now) < clock(since) {
+		days--
+	}
+
+	if days < 0 {
+		months--
+		days += time.Date(now.Year(), now.Month(), 0, 0, 0, 0, 0, time.UTC).Day()
+	}
+
+	if months < 0 {
+		years--
+		months += 12
+	}
+
+	list := make([]string, 0, 2)
+
+	if years > 0 {
+		list = append(list, plural(years, "year"))
+	}
+	if months > 0 {
+		list = append(list, plural(months, "month"))
+	}
+	if days > 0 && len(list) < 2 {
+		list = append(list, plural(days, "day"))
+	}
+
+	if len(list) == 0 {
+		return "less than a day"
+	}
+
+	return strings.Join(list, ", ")
+}
+
+// clock returns how much of the day has passed
+func clock(t time.Time) time.Duration {
+	return t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()))
+}
+
+// HumanJoin joins the list like "a, b and c".
 func HumanJoin(list []string, glue string) string {
-	if glue == "" {
-		glue = ", "
+	return HumanJoinAnd(list, glue, " and ")
+}
+
+// HumanJoinAnd joins all items with sep, except for the last two, which are
+// joined by lastSep. Two items are hence only joined by lastSep ("a and b").
+func HumanJoinAnd(items []string, sep string, lastSep string) string {
+	if sep == "" {
+		sep = ", "
 	}
 
-	l := len(list)
+	l := len(items)
 
 	switch l {
 	case 0:
 		return ""
 	case 1:
-		return list[0]
+		return items[0]
 	default:
-		return strings.Join(list[:(l-1)], glue) + " and " + list[l-1]
+		return strings.Join(items[:(l-1)], sep) + lastSep + items[l-1]
+	}
+}
+
+// TokenizeArguments splits the string at whitespace, but keeps "quoted strings"
+// together (without the quotes), so `greet "hello there"` yields two arguments.
+// Quotes can be escaped as \"; a quote without a closing one is kept as-is.
+func TokenizeArguments(s string) []string {
+	tokens := make([]string, 0)
+	runes := []rune(s)
+	current := make([]rune, 0)
+	inToken := false // "" is an (empty) token, too
+	quoted := false
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\' && i+1 < len(runes) && runes[i+1] == '"':
+			current = append(current, '"')
+			inToken = true
+			i++
+
+		case r == '"' && quoted:
+			quoted = false
+
+		case r == '"' && hasClosingQuote(runes[i+1:]):
+			quoted = true
+			inToken = true
+
+		case unicode.IsSpace(r) && !quoted:
+			if inToken {
+				tokens = append(tokens, string(current))
+				current = current[:0]
+				inToken = false
+			}
+
+		default:
+			current = append(current, r)
+			inToken = true
+		}
+	}
+
+	if inToken {
+		tokens = append(tokens, string(current))
 	}
+
+	return tokens
+}
+
+func hasClosingQuote(runes []rune) bool {
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+			i++
+		} else if runes[i] == '"' {
+			return true
+		}
+	}
+
+	return false
 }
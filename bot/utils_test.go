@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizeArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"   ", []string{}},
+		{"a b  c", []string{"a", "b", "c"}},
+		{`"a   b" c`, []string{"a   b", "c"}},
+		{`say \"hi\"`, []string{"say", `"hi"`}},
+		{`"a \"quoted\" word"`, []string{`a "quoted" word`}},
+		{`"a   b`, []string{`"a`, "b"}},
+		{`"" x`, []string{"", "x"}},
+	}
+
+	for _, test := range tests {
+		args := TokenizeArguments(test.input)
+
+		if len(args) == 0 && len(test.expected) == 0 {
+			continue
+		}
+
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Errorf("expected %q to be tokenized as %q, but got %q.", test.input, test.expected, args)
+		}
+	}
+}
+
+func TestHumanJoin(t *testing.T) {
+	tests := []struct {
+		items    []string
+		glue     string
+		expected string
+	}{
+		{[]string{}, ", ", ""},
+		{[]string{"a"}, ", ", "a"},
+		{[]string{"a", "b"}, ", ", "a and b"},
+		{[]string{"a", "b", "c"}, ", ", "a, b and c"},
+		{[]string{"a", "b", "c", "d"}, "; ", "a; b; c and d"},
+
+		// an empty glue means the default
+		{[]string{"a", "b", "c"}, "", "a, b and c"},
+	}
+
+	for _, test := range tests {
+		if joined := HumanJoin(test.items, test.glue); joined != test.expected {
+			t.Errorf("expected %q to be joined as %q, but got %q.", test.items, test.expected, joined)
+		}
+	}
+}
+
+func TestHumanJoinAnd(t *testing.T) {
+	tests := []struct {
+		items    []string
+		expected string
+	}{
+		{[]string{}, ""},
+		{[]string{"a"}, "a"},
+		{[]string{"a", "b"}, "a or b"},
+		{[]string{"a", "b", "c"}, "a, b or c"},
+	}
+
+	for _, test := range tests {
+		if joined := HumanJoinAnd(test.items, ", ", " or "); joined != test.expected {
+			t.Errorf("expected %q to be joined as %q, but got %q.", test.items, test.expected, joined)
+		}
+	}
+}
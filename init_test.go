@@ -3,22 +3,48 @@ package main
 import (
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin/acl"
+	"github.com/sgt-kabukiman/kabukibot/plugin/alias"
 	"github.com/sgt-kabukiman/kabukibot/plugin/banhammer_bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin/banphrase"
 	"github.com/sgt-kabukiman/kabukibot/plugin/blacklist"
+	"github.com/sgt-kabukiman/kabukibot/plugin/caps_filter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/channel_info"
+	"github.com/sgt-kabukiman/kabukibot/plugin/command_stats"
 	"github.com/sgt-kabukiman/kabukibot/plugin/content"
 	"github.com/sgt-kabukiman/kabukibot/plugin/custom_commands"
 	"github.com/sgt-kabukiman/kabukibot/plugin/dictionary"
 	"github.com/sgt-kabukiman/kabukibot/plugin/domain_ban"
+	"github.com/sgt-kabukiman/kabukibot/plugin/dry_run"
 	"github.com/sgt-kabukiman/kabukibot/plugin/echo"
 	"github.com/sgt-kabukiman/kabukibot/plugin/emote_counter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/greeter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/help"
 	"github.com/sgt-kabukiman/kabukibot/plugin/join"
+	"github.com/sgt-kabukiman/kabukibot/plugin/link_protection"
 	"github.com/sgt-kabukiman/kabukibot/plugin/log"
+	"github.com/sgt-kabukiman/kabukibot/plugin/markers"
+	"github.com/sgt-kabukiman/kabukibot/plugin/mod_log"
 	"github.com/sgt-kabukiman/kabukibot/plugin/monitor"
+	"github.com/sgt-kabukiman/kabukibot/plugin/nuke"
 	"github.com/sgt-kabukiman/kabukibot/plugin/ping"
 	"github.com/sgt-kabukiman/kabukibot/plugin/plugin_control"
+	"github.com/sgt-kabukiman/kabukibot/plugin/points"
+	"github.com/sgt-kabukiman/kabukibot/plugin/poll"
+	"github.com/sgt-kabukiman/kabukibot/plugin/prefix"
+	"github.com/sgt-kabukiman/kabukibot/plugin/quotes"
+	"github.com/sgt-kabukiman/kabukibot/plugin/raw"
+	"github.com/sgt-kabukiman/kabukibot/plugin/repeat_filter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/room_modes"
+	"github.com/sgt-kabukiman/kabukibot/plugin/seen"
+	"github.com/sgt-kabukiman/kabukibot/plugin/settings"
+	"github.com/sgt-kabukiman/kabukibot/plugin/shoutout"
 	"github.com/sgt-kabukiman/kabukibot/plugin/speedruncom"
+	"github.com/sgt-kabukiman/kabukibot/plugin/stream_info"
 	"github.com/sgt-kabukiman/kabukibot/plugin/subhype"
 	"github.com/sgt-kabukiman/kabukibot/plugin/sysinfo"
+	"github.com/sgt-kabukiman/kabukibot/plugin/third_party_emotes"
+	"github.com/sgt-kabukiman/kabukibot/plugin/throttle"
+	"github.com/sgt-kabukiman/kabukibot/plugin/timers"
 	"github.com/sgt-kabukiman/kabukibot/plugin/troll"
 	"github.com/sgt-kabukiman/kabukibot/test"
 )
@@ -68,6 +94,18 @@ func initTester(t *test.Tester) {
 		return domain_ban.NewPlugin()
 	})
 
+	t.AddPlugin("banphrase", func() bot.Plugin {
+		return banphrase.NewPlugin()
+	})
+
+	t.AddPlugin("link_protection", func() bot.Plugin {
+		return link_protection.NewPlugin()
+	})
+
+	t.AddPlugin("caps_filter", func() bot.Plugin {
+		return caps_filter.NewPlugin()
+	})
+
 	t.AddPlugin("banhammer_bot", func() bot.Plugin {
 		return banhammer_bot.NewPlugin()
 	})
@@ -89,10 +127,114 @@ func initTester(t *test.Tester) {
 	})
 
 	t.AddPlugin("custom_commands", func() bot.Plugin {
-		return custom_commands.NewPlugin()
+		return custom_commands.NewPluginWith(t.Now, t.Intn)
+	})
+
+	t.AddPlugin("timers", func() bot.Plugin {
+		return timers.NewPluginWithClock(t.Now, t.After)
+	})
+
+	t.AddPlugin("quotes", func() bot.Plugin {
+		return quotes.NewPluginWith(t.Now, t.Intn)
+	})
+
+	t.AddPlugin("stream_info", func() bot.Plugin {
+		return stream_info.NewPluginWithClock(t.Now)
+	})
+
+	t.AddPlugin("shoutout", func() bot.Plugin {
+		return shoutout.NewPluginWithClock(t.Now)
 	})
 
 	t.AddPlugin("gta", func() bot.Plugin {
 		return content.NewGTAPlugin()
 	})
+
+	t.AddPlugin("command_stats", func() bot.Plugin {
+		return command_stats.NewPlugin()
+	})
+
+	t.AddPlugin("help", func() bot.Plugin {
+		return help.NewPlugin()
+	})
+
+	t.AddPlugin("prefix", func() bot.Plugin {
+		return prefix.NewPlugin()
+	})
+
+	t.AddPlugin("throttle", func() bot.Plugin {
+		return throttle.NewPlugin()
+	})
+
+	t.AddPlugin("points", func() bot.Plugin {
+		return points.NewPluginWith(t.Now, t.Intn)
+	})
+
+	t.AddPlugin("poll", func() bot.Plugin {
+		return poll.NewPlugin()
+	})
+
+	t.AddPlugin("seen", func() bot.Plugin {
+		return seen.NewPluginWithClock(t.Now)
+	})
+
+	t.AddPlugin("greeter", func() bot.Plugin {
+		return greeter.NewPlugin()
+	})
+
+	t.AddPlugin("raw", func() bot.Plugin {
+		return raw.NewPlugin()
+	})
+
+	t.AddPlugin("settings", func() bot.Plugin {
+		return settings.NewPlugin()
+	})
+
+	t.AddPlugin("faulty", func() bot.Plugin {
+		return test.NewFaultyPlugin()
+	})
+
+	t.AddPlugin("spy", func() bot.Plugin {
+		return t.NewSpyPlugin()
+	})
+
+	t.AddPlugin("filters", func() bot.Plugin {
+		return test.NewFilterPlugin()
+	})
+
+	t.AddPlugin("markers", func() bot.Plugin {
+		return markers.NewPluginWithClock(t.Now)
+	})
+
+	t.AddPlugin("nuke", func() bot.Plugin {
+		return nuke.NewPluginWithClock(t.Now)
+	})
+
+	t.AddPlugin("room_modes", func() bot.Plugin {
+		return room_modes.NewPlugin()
+	})
+
+	t.AddPlugin("mod_log", func() bot.Plugin {
+		return mod_log.NewPluginWithClock(t.Now)
+	})
+
+	t.AddPlugin("channel_info", func() bot.Plugin {
+		return channel_info.NewPlugin()
+	})
+
+	t.AddPlugin("dry_run", func() bot.Plugin {
+		return dry_run.NewPlugin()
+	})
+
+	t.AddPlugin("alias", func() bot.Plugin {
+		return alias.NewPlugin()
+	})
+
+	t.AddPlugin("third_party_emotes", func() bot.Plugin {
+		return third_party_emotes.NewPluginWithClient(t.HTTPClient(), t.Now, t.After)
+	})
+
+	t.AddPlugin("repeat_filter", func() bot.Plugin {
+		return repeat_filter.NewPluginWithClock(t.Now)
+	})
 }
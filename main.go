@@ -1,32 +1,62 @@
 package main
 
 import (
+	"context"
 	"io/ioutil"
 	"net"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/jmoiron/sqlx"
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin/acl"
+	"github.com/sgt-kabukiman/kabukibot/plugin/alias"
 	"github.com/sgt-kabukiman/kabukibot/plugin/banhammer_bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin/banphrase"
 	"github.com/sgt-kabukiman/kabukibot/plugin/blacklist"
+	"github.com/sgt-kabukiman/kabukibot/plugin/caps_filter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/channel_info"
+	"github.com/sgt-kabukiman/kabukibot/plugin/command_stats"
 	"github.com/sgt-kabukiman/kabukibot/plugin/content"
 	"github.com/sgt-kabukiman/kabukibot/plugin/custom_commands"
 	"github.com/sgt-kabukiman/kabukibot/plugin/dictionary"
 	"github.com/sgt-kabukiman/kabukibot/plugin/domain_ban"
+	"github.com/sgt-kabukiman/kabukibot/plugin/dry_run"
 	"github.com/sgt-kabukiman/kabukibot/plugin/echo"
 	"github.com/sgt-kabukiman/kabukibot/plugin/emote_counter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/greeter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/help"
 	"github.com/sgt-kabukiman/kabukibot/plugin/join"
+	"github.com/sgt-kabukiman/kabukibot/plugin/link_protection"
 	"github.com/sgt-kabukiman/kabukibot/plugin/log"
+	"github.com/sgt-kabukiman/kabukibot/plugin/markers"
+	"github.com/sgt-kabukiman/kabukibot/plugin/mod_log"
 	"github.com/sgt-kabukiman/kabukibot/plugin/monitor"
+	"github.com/sgt-kabukiman/kabukibot/plugin/nuke"
 	"github.com/sgt-kabukiman/kabukibot/plugin/ping"
 	"github.com/sgt-kabukiman/kabukibot/plugin/plugin_control"
+	"github.com/sgt-kabukiman/kabukibot/plugin/points"
+	"github.com/sgt-kabukiman/kabukibot/plugin/poll"
+	"github.com/sgt-kabukiman/kabukibot/plugin/prefix"
+	"github.com/sgt-kabukiman/kabukibot/plugin/quotes"
+	"github.com/sgt-kabukiman/kabukibot/plugin/raw"
+	"github.com/sgt-kabukiman/kabukibot/plugin/repeat_filter"
+	"github.com/sgt-kabukiman/kabukibot/plugin/room_modes"
+	"github.com/sgt-kabukiman/kabukibot/plugin/seen"
+	"github.com/sgt-kabukiman/kabukibot/plugin/settings"
+	"github.com/sgt-kabukiman/kabukibot/plugin/shoutout"
 	"github.com/sgt-kabukiman/kabukibot/plugin/speedruncom"
+	"github.com/sgt-kabukiman/kabukibot/plugin/stream_info"
 	"github.com/sgt-kabukiman/kabukibot/plugin/subhype"
 	"github.com/sgt-kabukiman/kabukibot/plugin/sysinfo"
+	"github.com/sgt-kabukiman/kabukibot/plugin/third_party_emotes"
+	"github.com/sgt-kabukiman/kabukibot/plugin/throttle"
+	"github.com/sgt-kabukiman/kabukibot/plugin/timers"
 	"github.com/sgt-kabukiman/kabukibot/plugin/troll"
 	"github.com/sgt-kabukiman/kabukibot/twitch"
 )
@@ -55,6 +85,11 @@ func main() {
 		logger.Fatal(err.Error())
 	}
 
+	// --debug always wins over the configured level
+	if configured, okay := config.Level(); okay && !*debug {
+		logger.SetLevel(configured)
+	}
+
 	var channels []string
 
 	if *channelsFile != "" {
@@ -69,14 +104,15 @@ func main() {
 
 	// connect to database
 	logger.Info("Connecting to database...")
-	db, err := sqlx.Connect("mysql", config.Database.DSN)
+	db, err := sqlx.Connect(config.DatabaseDriver(), config.Database.DSN)
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
 
 	// setup our TwitchClient
 	server := net.JoinHostPort(config.IRC.Host, strconv.Itoa(config.IRC.Port))
-	twitch := twitch.NewTwitchClient(server, config.Account.Username, config.Account.Password, 2*time.Second, logger)
+	twitch := twitch.NewTwitchClient(server, config.Account.Username, config.Account.Password, 2*time.Second, config.Capabilities(), logger)
+	twitch.SetKeepalive(time.Duration(config.IRC.IdleTimeout)*time.Second, 0)
 
 	// build the bot
 	kabukibot, err := bot.NewKabukibot(twitch, logger, db, config)
@@ -85,43 +121,96 @@ func main() {
 	}
 
 	// add plugins
-	kabukibot.AddPlugin(blacklist.NewPlugin()) // load this as early as possible, because users will only be blacklisted for all following plugins
+	kabukibot.AddPlugin(blacklist.NewPlugin())
 	kabukibot.AddPlugin(log.NewPlugin())
 	kabukibot.AddPlugin(ping.NewPlugin())
+	kabukibot.AddPlugin(raw.NewPlugin())
+	kabukibot.AddPlugin(dry_run.NewPlugin())
+	kabukibot.AddPlugin(alias.NewPlugin())
+	kabukibot.AddPlugin(settings.NewPlugin())
 	kabukibot.AddPlugin(join.NewPlugin())
 	kabukibot.AddPlugin(acl.NewPlugin())
 	kabukibot.AddPlugin(plugin_control.NewPlugin())
+	kabukibot.AddPlugin(help.NewPlugin())
+	kabukibot.AddPlugin(prefix.NewPlugin())
+	kabukibot.AddPlugin(throttle.NewPlugin())
 	kabukibot.AddPlugin(speedruncom.NewPlugin())
 	kabukibot.AddPlugin(echo.NewPlugin())
 	kabukibot.AddPlugin(sysinfo.NewPlugin())
 	kabukibot.AddPlugin(dictionary.NewPlugin())
 	kabukibot.AddPlugin(domain_ban.NewPlugin())
+	kabukibot.AddPlugin(banphrase.NewPlugin())
+	kabukibot.AddPlugin(link_protection.NewPlugin())
+	kabukibot.AddPlugin(caps_filter.NewPlugin())
 	kabukibot.AddPlugin(banhammer_bot.NewPlugin())
 	kabukibot.AddPlugin(emote_counter.NewPlugin())
 	kabukibot.AddPlugin(subhype.NewPlugin())
 	kabukibot.AddPlugin(troll.NewPlugin())
 	kabukibot.AddPlugin(monitor.NewPlugin())
 	kabukibot.AddPlugin(custom_commands.NewPlugin())
+	kabukibot.AddPlugin(timers.NewPlugin())
+	kabukibot.AddPlugin(quotes.NewPlugin())
+	kabukibot.AddPlugin(stream_info.NewPlugin())
+	kabukibot.AddPlugin(shoutout.NewPlugin())
 	kabukibot.AddPlugin(content.NewGTAPlugin())
 	kabukibot.AddPlugin(content.NewCrashPlugin())
 	kabukibot.AddPlugin(content.NewChattyPlugin())
 	kabukibot.AddPlugin(content.NewSDAPlugin())
 	kabukibot.AddPlugin(content.NewESAPlugin())
+	kabukibot.AddPlugin(command_stats.NewPlugin())
+	kabukibot.AddPlugin(points.NewPlugin())
+	kabukibot.AddPlugin(poll.NewPlugin())
+	kabukibot.AddPlugin(seen.NewPlugin())
+	kabukibot.AddPlugin(greeter.NewPlugin())
+	kabukibot.AddPlugin(markers.NewPlugin())
+	kabukibot.AddPlugin(nuke.NewPlugin())
+	kabukibot.AddPlugin(room_modes.NewPlugin())
+	kabukibot.AddPlugin(mod_log.NewPlugin())
+	kabukibot.AddPlugin(channel_info.NewPlugin())
+	kabukibot.AddPlugin(third_party_emotes.NewPlugin())
+	kabukibot.AddPlugin(repeat_filter.NewPlugin())
+
+	// shut down cleanly on Ctrl-C or when being told to stop
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+		sig := <-stop
+		logger.Info("Received %s, shutting down...", sig)
+		cancel()
+	}()
 
 	// here we go
-	err = kabukibot.Connect()
+	err = kabukibot.ConnectContext(ctx)
 	if err != nil {
 		logger.Fatal(err.Error())
 	}
 
+	// join the channels once the bot is working
+	go func() {
+		for _, cn := range channels {
+			<-kabukibot.Join(cn)
+		}
+	}()
+
+	// reload the configuration on SIGHUP
+	go func() {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+
+		for range reload {
+			err := kabukibot.Reload()
+			if err != nil {
+				logger.Error("Could not reload configuration: %s", err)
+			}
+		}
+	}()
+
 	// do your thing, kabukibot
 	logger.Info("Letting the magic happen...")
-	go kabukibot.Work()
-
-	for _, cn := range channels {
-		<-kabukibot.Join(cn)
-	}
+	kabukibot.Run(ctx)
 
-	// wait for disconnect
-	<-kabukibot.Alive()
+	db.Close()
 }
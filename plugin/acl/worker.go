@@ -3,6 +3,7 @@ package acl
 import (
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin"
@@ -15,7 +16,7 @@ type Worker struct {
 	channel bot.Channel
 }
 
-var permRegex = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+var permRegex = regexp.MustCompile(`[^a-zA-Z0-9_*-]`)
 
 func (self *Worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsProcessed() {
@@ -23,7 +24,7 @@ func (self *Worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	}
 
 	// skip unwanted commands
-	if !msg.IsGlobalCommand("allow") && !msg.IsGlobalCommand("deny") && !msg.IsGlobalCommand("permissions") && !msg.IsGlobalCommand("allowed") {
+	if !msg.IsGlobalCommand("allow") && !msg.IsGlobalCommand("deny") && !msg.IsGlobalCommand("undeny") && !msg.IsGlobalCommand("permissions") && !msg.IsGlobalCommand("allowed") && !msg.IsGlobalCommand("acl_group") {
 		return
 	}
 
@@ -32,6 +33,11 @@ func (self *Worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 		return
 	}
 
+	if msg.IsGlobalCommand("acl_group") {
+		self.handleGroup(msg, sender)
+		return
+	}
+
 	// send the list of available permissions
 	if msg.IsGlobalCommand("permissions") {
 		permissions := self.collectPermissions()
@@ -61,10 +67,12 @@ func (self *Worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 		return
 	}
 
+	// patterns like "use_*_cmd" must match at least one existing permission
 	found := false
+	isPattern := bot.IsPermissionPattern(permission)
 
 	for _, p := range permissions {
-		if p == permission {
+		if p == permission || (isPattern && bot.MatchesPermission(permission, p)) {
 			found = true
 			break
 		}
@@ -96,14 +104,33 @@ func (self *Worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 		return
 	}
 
+	if msg.IsGlobalCommand("undeny") {
+		self.handleUndeny(permission, args[1:], sender)
+		return
+	}
+
 	self.HandleAllowDeny(msg.IsGlobalCommand("allow"), permission, args[1:], sender, permission)
 }
 
 var userIdentRegex = regexp.MustCompile(`[^a-zA-Z0-9_$,]`)
 var userNameRegex = regexp.MustCompile(`[^a-z0-9_]`)
+var durationRegex = regexp.MustCompile(`^[0-9]+[dhms]([0-9]+[dhms])*$`)
 
 // HandleAllowDeny is exported because the custom commands plugin re-uses it. #cheating
 func (self *Worker) HandleAllowDeny(allow bool, permission string, args []string, sender bot.Sender, permisionName string) {
+	// "!allow perm user 1h" grants the permission only temporarily
+	var duration *time.Duration
+
+	if allow && len(args) > 1 && durationRegex.MatchString(args[len(args)-1]) {
+		duration = bot.ParseDuration(args[len(args)-1], nil, nil)
+		if duration == nil || *duration <= 0 {
+			sender.Respond("invalid duration given. Expected a value like 30m or 2h.")
+			return
+		}
+
+		args = args[:len(args)-1]
+	}
+
 	// normalize the arguments into a single array of (possibly bogus) idents
 	args = strings.Split(strings.ToLower(userIdentRegex.ReplaceAllString(strings.Join(args, ","), "")), ",")
 
@@ -113,6 +140,7 @@ func (self *Worker) HandleAllowDeny(allow bool, permission string, args []string
 	}
 
 	processed := make([]string, 0)
+	forbidden := make([]string, 0)
 	acl := self.channel.ACL()
 
 	for i, ident := range args {
@@ -123,21 +151,60 @@ func (self *Worker) HandleAllowDeny(allow bool, permission string, args []string
 			if ident != args[i] {
 				continue
 			}
+		} else if allow && !acl.IsGroup(ident) {
+			// do not grant anything to groups that do not exist (yet)
+			continue
 		}
 
-		if allow {
-			if acl.Allow(ident, permission) {
-				processed = append(processed, ident)
-			}
+		var changed bool
+		var err error
+
+		if allow && duration != nil {
+			changed, err = acl.AllowFor(ident, permission, *duration)
+		} else if allow {
+			changed, err = acl.Allow(ident, permission)
 		} else {
-			if acl.Deny(ident, permission) {
-				processed = append(processed, ident)
-			}
+			changed, err = acl.Deny(ident, permission)
+		}
+
+		if err != nil {
+			bot.DatabaseError(self.bot.Logger(), sender, "Could not change ACL entry: %s", err)
+			return
+		}
+
+		if changed {
+			processed = append(processed, ident)
+			continue
+		}
+
+		if allow || (!acl.IsUsername(ident) && !acl.IsGroup(ident)) {
+			continue
+		}
+
+		// nothing to revoke, so make sure no other grant applies either
+		changed, err = acl.Forbid(ident, permission)
+		if err != nil {
+			bot.DatabaseError(self.bot.Logger(), sender, "Could not add ACL denial: %s", err)
+			return
+		}
+
+		if changed {
+			forbidden = append(forbidden, ident)
 		}
 	}
 
-	if len(processed) == 0 {
+	if len(forbidden) > 0 {
+		response := "denied " + permisionName + " to " + bot.HumanJoin(forbidden, ", ") + ", regardless of other grants"
+
+		if len(processed) > 0 {
+			response = "revoked permission for " + permisionName + " from " + bot.HumanJoin(processed, ", ") + " and " + response
+		}
+
+		sender.Respond(response + ".")
+	} else if len(processed) == 0 {
 		sender.Respond("no changes needed.")
+	} else if allow && duration != nil {
+		sender.Respond("granted permission for " + permisionName + " to " + bot.HumanJoin(processed, ", ") + " for " + bot.FormatDuration(*duration, true) + ".")
 	} else if allow {
 		sender.Respond("granted permission for " + permisionName + " to " + bot.HumanJoin(processed, ", ") + ".")
 	} else {
@@ -145,6 +212,151 @@ func (self *Worker) HandleAllowDeny(allow bool, permission string, args []string
 	}
 }
 
+// handleUndeny lifts explicit denials without granting anything, so the
+// regular grants (like one for $all) apply again.
+func (self *Worker) handleUndeny(permission string, args []string, sender bot.Sender) {
+	idents := strings.Split(strings.ToLower(userIdentRegex.ReplaceAllString(strings.Join(args, ","), "")), ",")
+	lifted := make([]string, 0)
+	acl := self.channel.ACL()
+
+	for _, ident := range idents {
+		changed, err := acl.Lift(ident, permission)
+		if err != nil {
+			bot.DatabaseError(self.bot.Logger(), sender, "Could not lift ACL denial: %s", err)
+			return
+		}
+
+		if changed {
+			lifted = append(lifted, ident)
+		}
+	}
+
+	if len(lifted) == 0 {
+		sender.Respond("no changes needed.")
+	} else {
+		sender.Respond("lifted the denial of " + permission + " for " + bot.HumanJoin(lifted, ", ") + ".")
+	}
+}
+
+var groupNameRegex = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func (self *Worker) handleGroup(msg *bot.TextMessage, sender bot.Sender) {
+	acl := self.channel.ACL()
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		sender.Respond("usage: !" + msg.Command() + " (create|delete|add|remove|list) <group> [username]")
+		return
+	}
+
+	action := strings.ToLower(args[0])
+
+	if action == "list" && len(args) == 1 {
+		groups := acl.Groups()
+
+		if len(groups) == 0 {
+			sender.Respond("there are no groups in this channel yet.")
+		} else {
+			for i, group := range groups {
+				groups[i] = "$" + group
+			}
+
+			sender.Respond("the following groups exist: " + bot.HumanJoin(groups, ", ") + ".")
+		}
+
+		return
+	}
+
+	if len(args) < 2 {
+		sender.Respond("no group name given.")
+		return
+	}
+
+	group := strings.TrimPrefix(strings.ToLower(args[1]), "$")
+	if !groupNameRegex.MatchString(group) {
+		sender.Respond("group names can only consist of letters, numbers and underscores.")
+		return
+	}
+
+	switch action {
+	case "create":
+		created, err := acl.CreateGroup(group)
+
+		if err != nil {
+			bot.DatabaseError(self.bot.Logger(), sender, "Could not create ACL group: %s", err)
+		} else if created {
+			sender.Respond("created group $" + group + ".")
+		} else {
+			sender.Respond("the group $" + group + " already exists.")
+		}
+
+	case "delete":
+		deleted, err := acl.DeleteGroup(group)
+
+		if err != nil {
+			bot.DatabaseError(self.bot.Logger(), sender, "Could not delete ACL group: %s", err)
+		} else if deleted {
+			sender.Respond("deleted group $" + group + " and all permissions granted to it.")
+		} else {
+			sender.Respond("there is no group $" + group + ".")
+		}
+
+	case "list":
+		members, exists := acl.Members(group)
+
+		if !exists {
+			sender.Respond("there is no group $" + group + ".")
+		} else if len(members) == 0 {
+			sender.Respond("$" + group + " has no members.")
+		} else {
+			sender.Respond("$" + group + " consists of " + bot.HumanJoin(members, ", ") + ".")
+		}
+
+	case "add", "remove":
+		if _, exists := acl.GroupMembers(group); !exists {
+			sender.Respond("there is no group $" + group + ".")
+			return
+		}
+
+		if len(args) < 3 {
+			sender.Respond("no username given.")
+			return
+		}
+
+		// groups cannot contain other groups
+		username := strings.ToLower(args[2])
+		if username != userNameRegex.ReplaceAllString(username, "") {
+			sender.Respond("only users can be members of groups.")
+			return
+		}
+
+		if action == "add" {
+			added, err := acl.AddGroupMember(group, username)
+
+			if err != nil {
+				bot.DatabaseError(self.bot.Logger(), sender, "Could not add ACL group member: %s", err)
+			} else if added {
+				sender.Respond("added " + username + " to $" + group + ".")
+			} else {
+				sender.Respond(username + " already is a member of $" + group + ".")
+			}
+		} else {
+			removed, err := acl.RemoveGroupMember(group, username)
+
+			if err != nil {
+				bot.DatabaseError(self.bot.Logger(), sender, "Could not remove ACL group member: %s", err)
+			} else if removed {
+				sender.Respond("removed " + username + " from $" + group + ".")
+			} else {
+				sender.Respond(username + " is not a member of $" + group + ".")
+			}
+		}
+
+	default:
+		sender.Respond("unknown action. Use create, delete, add, remove or list.")
+	}
+}
+
 func (self *Worker) collectPermissions() []string {
 	result := make([]string, 0)
 
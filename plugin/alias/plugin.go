@@ -0,0 +1,35 @@
+package alias
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// The alias plugin lets the operator give commands additional names, which
+// then work in every channel. Aliases from the configuration are listed as
+// well, but can only be changed there.
+type pluginStruct struct {
+	plugin.BasePlugin
+
+	aliases  *bot.AliasRegistry
+	commands *bot.CommandRegistry
+	log      bot.Logger
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.aliases = bot.Aliases()
+	self.commands = bot.Commands()
+	self.log = bot.Logger()
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		aliases:  self.aliases,
+		commands: self.commands,
+		log:      self.log,
+	}
+}
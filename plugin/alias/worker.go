@@ -0,0 +1,103 @@
+package alias
+
+import (
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	aliases  *bot.AliasRegistry
+	commands *bot.CommandRegistry
+	log      bot.Logger
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || !msg.IsFromOperator() || !msg.IsGlobalCommand("alias") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	args := msg.Arguments()
+	usage := "usage: " + msg.Trigger() + msg.Command() + " [list|add <alias> <command>|remove <alias>]"
+
+	if len(args) == 0 || strings.ToLower(args[0]) == "list" {
+		self.respondList(sender)
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if len(args) < 3 {
+			sender.Respond(usage)
+			return
+		}
+
+		self.handleAdd(strings.ToLower(strings.TrimPrefix(args[1], "!")), strings.ToLower(strings.TrimPrefix(args[2], "!")), sender)
+
+	case "remove":
+		if len(args) < 2 {
+			sender.Respond(usage)
+			return
+		}
+
+		self.handleRemove(strings.ToLower(strings.TrimPrefix(args[1], "!")), sender)
+
+	default:
+		sender.Respond(usage)
+	}
+}
+
+func (self *worker) respondList(sender bot.Sender) {
+	aliases := self.aliases.Aliases()
+
+	if len(aliases) == 0 {
+		sender.Respond("there are no aliases defined.")
+		return
+	}
+
+	list := make([]string, len(aliases))
+
+	for idx, alias := range aliases {
+		command, _ := self.aliases.Resolve(alias)
+		list[idx] = "!" + alias + " = !" + command
+	}
+
+	sender.Respond("aliases are: " + bot.HumanJoin(list, ", ") + ".")
+}
+
+func (self *worker) handleAdd(alias string, command string, sender bot.Sender) {
+	// an alias would hide the real command from everyone
+	if owner, exists := self.commands.Owner(alias); exists {
+		sender.Respond("!" + alias + " is already a command of the " + owner + " plugin.")
+		return
+	}
+
+	err := self.aliases.Define(alias, command)
+	if err != nil {
+		sender.Respond("the alias could not be added: " + err.Error())
+		return
+	}
+
+	sender.Respond("!" + alias + " is now an alias for !" + command + ".")
+}
+
+func (self *worker) handleRemove(alias string, sender bot.Sender) {
+	removed, err := self.aliases.Remove(alias)
+	if err != nil {
+		self.log.Error("Could not remove the alias !%s: %s", alias, err.Error())
+		sender.Respond("the alias could not be removed.")
+		return
+	}
+
+	if !removed {
+		sender.Respond("there is no alias !" + alias + " that could be removed.")
+		return
+	}
+
+	sender.Respond("the alias !" + alias + " has been removed.")
+}
@@ -1,6 +1,8 @@
 package banhammer_bot
 
 import (
+	"fmt"
+
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin"
 	"github.com/sgt-kabukiman/kabukibot/twitch"
@@ -24,9 +26,15 @@ func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
 }
 
 func (self *pluginStruct) HandleClearChatMessage(msg *twitch.ClearChatMessage, sender bot.Sender) {
-	if msg.User != "" {
-		sender.Respond("Notification: " + msg.User)
+	if msg.IsTimeout() {
+		sender.Respond(fmt.Sprintf("Notification: %s has been timed out for %ds", msg.User, msg.Duration))
+	} else if msg.IsBan() {
+		sender.Respond("Notification: " + msg.User + " has been banned")
 	} else {
 		sender.Respond("Notification: chat has been cleared")
 	}
 }
+
+func (self *pluginStruct) HandleClearMsgMessage(msg *twitch.ClearMsgMessage, sender bot.Sender) {
+	sender.Respond("Notification: a message by " + msg.User + " has been deleted")
+}
@@ -0,0 +1,53 @@
+package banphrase
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+// more typos than this would make even short phrases match almost anything
+const maxTypos = 5
+
+// normalizePhrase lowercases the text and splits it into words, ignoring
+// punctuation; both fuzzy phrases and the messages they are compared to are
+// treated this way.
+func normalizePhrase(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// fuzzyMatch tells whether any part of the message is at most typos edits away
+// from the phrase. Only runs of words about as long as the phrase are compared
+// (one word more or less, so that "follow bot" still matches "followbot"), which
+// keeps long messages cheap to check.
+func fuzzyMatch(phrase []string, typos int, message string) bool {
+	words := normalizePhrase(message)
+	needle := strings.Join(phrase, " ")
+	length := utf8.RuneCountInString(needle)
+
+	for size := len(phrase) - 1; size <= len(phrase)+1; size++ {
+		if size < 1 {
+			continue
+		}
+
+		for start := 0; start+size <= len(words); start++ {
+			candidate := strings.Join(words[start:start+size], " ")
+
+			// the distance is at least the difference in length
+			diff := utf8.RuneCountInString(candidate) - length
+			if diff > typos || -diff > typos {
+				continue
+			}
+
+			if bot.Levenshtein(candidate, needle, typos) <= typos {
+				return true
+			}
+		}
+	}
+
+	return false
+}
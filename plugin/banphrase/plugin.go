@@ -0,0 +1,39 @@
+package banphrase
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	db  *sqlx.DB
+	log bot.Logger
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "banphrase"
+}
+
+// banned phrases must not get a response from any command
+func (self *pluginStruct) Priority() int {
+	return bot.ModerationPriority
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	self.log = bot.Logger()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		db:      self.db,
+		log:     self.log,
+	}
+}
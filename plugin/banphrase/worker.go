@@ -0,0 +1,334 @@
+package banphrase
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"banphrase"}
+
+var defaultTimeout = 10 * time.Minute
+var minTimeout = 1 * time.Second
+var maxTimeout = 14 * 24 * time.Hour
+
+// Fuzzy phrases are plain text instead of regular expressions and match
+// messages containing something that is at most Fuzziness typos away.
+type phrase struct {
+	Pattern   string
+	Timeout   time.Duration
+	Fuzziness int
+	regex     *regexp.Regexp
+	words     []string
+}
+
+func newPhrase(pattern string, timeout time.Duration, fuzziness int) (phrase, error) {
+	p := phrase{Pattern: pattern, Timeout: timeout, Fuzziness: fuzziness}
+
+	if fuzziness > 0 {
+		p.words = normalizePhrase(pattern)
+		return p, nil
+	}
+
+	regex, err := compile(pattern)
+	p.regex = regex
+
+	return p, err
+}
+
+func (self phrase) matches(text string) bool {
+	if self.Fuzziness > 0 {
+		return fuzzyMatch(self.words, self.Fuzziness, text)
+	}
+
+	return self.regex.MatchString(text)
+}
+
+func (self phrase) String() string {
+	if self.Fuzziness > 0 {
+		return fmt.Sprintf("/%s/ (%s t/o, up to %s)", self.Pattern, bot.FormatDuration(self.Timeout, false), typos(self.Fuzziness))
+	}
+
+	return fmt.Sprintf("/%s/ (%s t/o)", self.Pattern, bot.FormatDuration(self.Timeout, false))
+}
+
+func typos(n int) string {
+	if n == 1 {
+		return "1 typo"
+	}
+
+	return strconv.Itoa(n) + " typos"
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	db      *sqlx.DB
+	log     bot.Logger
+	phrases []phrase
+}
+
+type phraseDbStruct struct {
+	Pattern   string
+	Seconds   int
+	Fuzziness int
+}
+
+func (self *worker) Enable() {
+	list := make([]phraseDbStruct, 0)
+	self.db.Select(&list, "SELECT pattern, seconds, fuzziness FROM banphrases WHERE channel = ? ORDER BY pattern", self.channel)
+
+	self.phrases = make([]phrase, 0, len(list))
+
+	for _, item := range list {
+		p, err := newPhrase(item.Pattern, time.Duration(item.Seconds)*time.Second, item.Fuzziness)
+		if err != nil {
+			continue
+		}
+
+		self.phrases = append(self.phrases, p)
+	}
+}
+
+func (self *worker) Reload() {
+	self.Enable()
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"configure_banphrases"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "configure_banphrases"
+}
+
+// phrases are always matched case-insensitively
+func compile(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	if msg.Command() != "banphrase" {
+		self.checkMessage(msg, sender)
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "configure_banphrases") {
+		return
+	}
+
+	args := msg.Arguments()
+	if len(args) == 0 {
+		sender.Respond("usage: " + msg.Trigger() + "banphrase (add|del) /regex/ [timeout], " + msg.Trigger() + "banphrase fuzzy /phrase/ <typos> [timeout] or " + msg.Trigger() + "banphrase list")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		self.addPhrase(msg.ArgumentText(1), sender)
+	case "fuzzy":
+		self.addFuzzyPhrase(msg.ArgumentText(1), sender)
+	case "del":
+		self.deletePhrase(msg.ArgumentText(1), sender)
+	case "list":
+		self.listPhrases(sender)
+	default:
+		sender.Respond("unknown action. Use add, fuzzy, del or list.")
+	}
+}
+
+// splits "/some regex/ 5m" into the pattern and the remaining text
+func parsePattern(text string) (string, string, bool) {
+	text = strings.TrimSpace(text)
+	end := strings.LastIndex(text, "/")
+
+	if !strings.HasPrefix(text, "/") || end < 2 {
+		return "", "", false
+	}
+
+	return text[1:end], strings.TrimSpace(text[end+1:]), true
+}
+
+func (self *worker) addPhrase(text string, sender bot.Sender) {
+	pattern, rest, okay := parsePattern(text)
+	if !okay {
+		sender.Respond("the phrase must be given as a regular expression enclosed in slashes, like /buy followers/.")
+		return
+	}
+
+	p, err := newPhrase(pattern, defaultTimeout, 0)
+	if err != nil {
+		sender.Respond("this is not a valid regular expression: " + err.Error())
+		return
+	}
+
+	if !parseTimeout(rest, &p, sender) {
+		return
+	}
+
+	self.storePhrase(p, sender)
+}
+
+// addFuzzyPhrase bans a plain text phrase, including misspelled variants
+func (self *worker) addFuzzyPhrase(text string, sender bot.Sender) {
+	pattern, rest, okay := parsePattern(text)
+	if !okay {
+		sender.Respond("the phrase must be enclosed in slashes, like /buy followers/.")
+		return
+	}
+
+	parts := strings.SplitN(rest, " ", 2)
+
+	fuzziness, err := strconv.Atoi(parts[0])
+	if err != nil || fuzziness < 1 || fuzziness > maxTypos {
+		sender.Respond(fmt.Sprintf("invalid number of typos given. Expected a number between 1 and %d.", maxTypos))
+		return
+	}
+
+	p, _ := newPhrase(pattern, defaultTimeout, fuzziness)
+
+	// otherwise, almost every word of that length would match
+	if 2*fuzziness >= len([]rune(strings.Join(p.words, " "))) {
+		sender.Respond("the phrase is too short to allow " + typos(fuzziness) + ".")
+		return
+	}
+
+	rest = ""
+	if len(parts) > 1 {
+		rest = strings.TrimSpace(parts[1])
+	}
+
+	if !parseTimeout(rest, &p, sender) {
+		return
+	}
+
+	self.storePhrase(p, sender)
+}
+
+func parseTimeout(text string, p *phrase, sender bot.Sender) bool {
+	if len(text) == 0 {
+		return true
+	}
+
+	parsed := bot.ParseDuration(strings.Replace(text, " ", "", -1), nil, nil)
+	if parsed == nil || *parsed < minTimeout || *parsed > maxTimeout {
+		sender.Respond("invalid timeout given. Expected a value like 50s or 1h.")
+		return false
+	}
+
+	p.Timeout = time.Duration(parsed.Seconds()) * time.Second
+
+	return true
+}
+
+// storePhrase adds the phrase or, if the pattern is already banned, replaces it
+func (self *worker) storePhrase(p phrase, sender bot.Sender) {
+	timeout := bot.FormatDuration(p.Timeout, true)
+
+	for i, existing := range self.phrases {
+		if existing.Pattern == p.Pattern {
+			_, err := self.db.Exec("UPDATE banphrases SET seconds = ?, fuzziness = ? WHERE channel = ? AND pattern = ?", int(p.Timeout.Seconds()), p.Fuzziness, self.channel, p.Pattern)
+			if err != nil {
+				bot.DatabaseError(self.log, sender, "Could not update banned phrase: %s", err)
+				return
+			}
+
+			self.phrases[i] = p
+			sender.Respond(fmt.Sprintf("/%s/ will now result in a timeout of %s.", p.Pattern, timeout))
+			return
+		}
+	}
+
+	// this fails for example for patterns longer than the column
+	_, err := self.db.Exec("INSERT INTO banphrases (channel, pattern, seconds, fuzziness) VALUES (?, ?, ?, ?)", self.channel, p.Pattern, int(p.Timeout.Seconds()), p.Fuzziness)
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not insert banned phrase: %s", err)
+		return
+	}
+
+	self.phrases = append(self.phrases, p)
+
+	if p.Fuzziness > 0 {
+		sender.Respond(fmt.Sprintf("messages containing /%s/ with up to %s will be timed out for %s.", p.Pattern, typos(p.Fuzziness), timeout))
+	} else {
+		sender.Respond(fmt.Sprintf("messages matching /%s/ will be timed out for %s.", p.Pattern, timeout))
+	}
+}
+
+func (self *worker) deletePhrase(text string, sender bot.Sender) {
+	pattern, _, okay := parsePattern(text)
+	if !okay {
+		sender.Respond("the phrase must be given as a regular expression enclosed in slashes, like /buy followers/.")
+		return
+	}
+
+	for i, p := range self.phrases {
+		if p.Pattern == pattern {
+			_, err := self.db.Exec("DELETE FROM banphrases WHERE channel = ? AND pattern = ?", self.channel, pattern)
+			if err != nil {
+				bot.DatabaseError(self.log, sender, "Could not delete banned phrase: %s", err)
+				return
+			}
+
+			self.phrases = append(self.phrases[:i], self.phrases[i+1:]...)
+			sender.Respond(fmt.Sprintf("/%s/ is no longer banned.", pattern))
+			return
+		}
+	}
+
+	sender.Respond(fmt.Sprintf("/%s/ was not banned in the first place.", pattern))
+}
+
+func (self *worker) listPhrases(sender bot.Sender) {
+	if len(self.phrases) == 0 {
+		sender.Respond("no phrases are banned yet.")
+		return
+	}
+
+	list := make([]string, 0, len(self.phrases))
+
+	for _, p := range self.phrases {
+		list = append(list, p.String())
+	}
+
+	sender.Respond("the following phrases are banned: " + bot.HumanJoin(list, ", "))
+}
+
+func (self *worker) checkMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if len(self.phrases) == 0 || msg.IsFromBroadcaster() || msg.IsFromOperator() {
+		return
+	}
+
+	t := msg.User.Type
+
+	if t == twitch.Moderator || t == twitch.GlobalModerator || t == twitch.TwitchStaff || t == twitch.TwitchAdmin {
+		return
+	}
+
+	for _, p := range self.phrases {
+		if p.matches(msg.Normalized()) {
+			sender.Timeout(strings.ToLower(msg.User.Name), int(p.Timeout.Seconds()), "Your message contained a banned phrase.")
+			msg.StopPropagation()
+			return
+		}
+	}
+}
@@ -24,6 +24,11 @@ func NewPlugin() *pluginStruct {
 	return &pluginStruct{}
 }
 
+// run before everything else, so that blacklisted users are ignored by all plugins
+func (self *pluginStruct) Priority() int {
+	return 100
+}
+
 func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 	self.db = bot.Database()
 	self.log = bot.Logger()
@@ -59,7 +59,13 @@ func (self *pluginStruct) HandleTextMessage(msg *bot.TextMessage, sender bot.Sen
 			return
 		}
 
-		if self.blacklist(username) {
+		added, err := self.blacklist(username)
+		if err != nil {
+			bot.DatabaseError(self.log, sender, "Could not insert blacklist entry into the database: %s", err)
+			return
+		}
+
+		if added {
 			sender.Respond(username + " has been blacklisted.")
 		} else {
 			sender.Respond(username + " is already on the blacklist.")
@@ -70,37 +76,38 @@ func (self *pluginStruct) HandleTextMessage(msg *bot.TextMessage, sender bot.Sen
 
 	// perform unblacklisting
 
-	if self.unblacklist(username) {
+	removed, err := self.unblacklist(username)
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not delete blacklist entry from the database: %s", err)
+		return
+	}
+
+	if removed {
 		sender.Respond(username + " has been un-blacklisted.")
 	} else {
 		sender.Respond(username + " is not blacklisted.")
 	}
 }
 
-func (self *pluginStruct) blacklist(username string) bool {
-	// use a read-lock around the isBlacklisted check
-	self.mutex.RLock()
-
+func (self *pluginStruct) blacklist(username string) (bool, error) {
 	if self.isBlacklisted(username) {
-		self.mutex.RUnlock()
-		return false
+		return false, nil
 	}
 
-	self.mutex.RUnlock()
 	self.mutex.Lock()
+	defer self.mutex.Unlock()
 
 	_, err := self.db.Exec("INSERT INTO blacklist (username) VALUES (?)", username)
 	if err != nil {
-		self.log.Fatal("Could not insert blacklist entry from the database: " + err.Error())
+		return false, err
 	}
 
 	self.users = append(self.users, username)
-	self.mutex.Unlock()
 
-	return true
+	return true, nil
 }
 
-func (self *pluginStruct) unblacklist(username string) bool {
+func (self *pluginStruct) unblacklist(username string) (bool, error) {
 	self.mutex.Lock()
 	defer self.mutex.Unlock()
 
@@ -114,17 +121,17 @@ func (self *pluginStruct) unblacklist(username string) bool {
 	}
 
 	if pos == -1 {
-		return false
+		return false, nil
 	}
 
 	_, err := self.db.Exec("DELETE FROM blacklist WHERE username = ?", username)
 	if err != nil {
-		self.log.Fatal("Could not delete blacklist entry from the database: " + err.Error())
+		return false, err
 	}
 
 	self.users = append(self.users[:pos], self.users[(pos+1):]...)
 
-	return true
+	return true, nil
 }
 
 func (self *pluginStruct) isBlacklisted(username string) bool {
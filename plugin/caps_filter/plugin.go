@@ -0,0 +1,77 @@
+package caps_filter
+
+import (
+	"sync"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type capsFilterConfig struct {
+	MinLength int `yaml:"minLength"` // shorter messages are never checked
+	MaxCaps   int `yaml:"maxCaps"`   // in percent of all letters
+	MaxRepeat int `yaml:"maxRepeat"` // longest allowed run of the same character or word
+	Timeout   int // in seconds
+}
+
+// subscribers may shout in some channels
+var exemptSubscribers = bot.BoolSetting(false)
+
+type pluginStruct struct {
+	config          capsFilterConfig
+	mutex           sync.RWMutex
+	log             bot.Logger
+	channelSettings *bot.Settings
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "caps_filter"
+}
+
+// shouting at a command should not get an answer
+func (self *pluginStruct) Priority() int {
+	return bot.ModerationPriority
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.log = bot.Logger()
+	self.channelSettings = bot.Settings()
+	self.channelSettings.Register(self.Name(), "exempt_subscribers", exemptSubscribers)
+	self.Reconfigure(bot.Configuration())
+}
+
+func (self *pluginStruct) Reconfigure(config *bot.Configuration) {
+	loaded := capsFilterConfig{
+		MinLength: 15,
+		MaxCaps:   70,
+		MaxRepeat: 12,
+		Timeout:   10,
+	}
+
+	err := config.PluginConfig("caps_filter", &loaded)
+	if err != nil {
+		self.log.Warning("Could not load 'caps_filter' plugin configuration: %s", err)
+	}
+
+	self.mutex.Lock()
+	self.config = loaded
+	self.mutex.Unlock()
+}
+
+func (self *pluginStruct) settings() capsFilterConfig {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	return self.config
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		plugin:  self,
+	}
+}
@@ -0,0 +1,131 @@
+package caps_filter
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	plugin  *pluginStruct
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"bypass_caps_filter"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() || msg.IsFromBroadcaster() || msg.IsFromOperator() {
+		return
+	}
+
+	t := msg.User.Type
+
+	if t == twitch.Moderator || t == twitch.GlobalModerator || t == twitch.TwitchStaff || t == twitch.TwitchAdmin {
+		return
+	}
+
+	if msg.User.Subscriber && self.plugin.channelSettings.Bool(self.channel, "caps_filter.exempt_subscribers") {
+		return
+	}
+
+	config := self.plugin.settings()
+
+	if utf8.RuneCountInString(msg.Text) < config.MinLength {
+		return
+	}
+
+	reason := ""
+
+	if CapsRatio(msg.Text)*100 > float64(config.MaxCaps) {
+		reason = "please don't shout."
+	} else if LongestRun(msg.Text) > config.MaxRepeat {
+		reason = "please don't spam."
+	}
+
+	if len(reason) == 0 || self.acl.IsAllowed(msg.User, "bypass_caps_filter") {
+		return
+	}
+
+	sender.Timeout(strings.ToLower(msg.User.Name), config.Timeout, "")
+	sender.Respond(reason)
+
+	msg.StopPropagation()
+}
+
+// CapsRatio returns the share of uppercase letters among all letters (0 to 1).
+// Everything that is not a letter is ignored, so "!!! 123" has a ratio of 0.
+func CapsRatio(text string) float64 {
+	letters := 0
+	upper := 0
+
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+
+	if letters == 0 {
+		return 0
+	}
+
+	return float64(upper) / float64(letters)
+}
+
+// LongestRun returns how often the same character or the same word (e.g. an
+// emote) is repeated in a row at most. Whitespace is not counted.
+func LongestRun(text string) int {
+	longest := 0
+	run := 0
+	var last rune
+
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			run = 0
+			continue
+		}
+
+		if run > 0 && r == last {
+			run++
+		} else {
+			run = 1
+		}
+
+		last = r
+
+		if run > longest {
+			longest = run
+		}
+	}
+
+	run = 0
+	lastWord := ""
+
+	for _, word := range strings.Fields(text) {
+		if word == lastWord {
+			run++
+		} else {
+			run = 1
+		}
+
+		lastWord = word
+
+		if run > longest {
+			longest = run
+		}
+	}
+
+	return longest
+}
@@ -0,0 +1,34 @@
+package channel_info
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+type pluginStruct struct {
+	api *twitch.APIClient
+	log bot.Logger
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "channel_info"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.api = bot.TwitchAPI()
+	self.log = bot.Logger()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		api:     self.api,
+		log:     self.log,
+	}
+}
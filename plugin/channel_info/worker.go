@@ -0,0 +1,136 @@
+package channel_info
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"title", "game"}
+
+// Twitch does not accept longer titles
+const maxTitleLength = 140
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	api     *twitch.APIClient
+	log     bot.Logger
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"edit_channel_info"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	command := msg.Command()
+	if command != "title" && command != "game" {
+		return
+	}
+
+	msg.SetProcessed()
+
+	value := msg.ArgumentText(0)
+
+	// everybody may ask, but only some may change things
+	if value != "" && !self.acl.IsAllowed(msg.User, "edit_channel_info") {
+		return
+	}
+
+	// do not block the channel while waiting for Twitch
+	switch {
+	case value == "":
+		go self.respondCurrent(command, sender)
+
+	case command == "title":
+		if utf8.RuneCountInString(value) > maxTitleLength {
+			sender.Respond("the title can be at most " + strconv.Itoa(maxTitleLength) + " characters long.")
+			return
+		}
+
+		go self.setTitle(value, sender)
+
+	default:
+		go self.setGame(value, sender)
+	}
+}
+
+func (self *worker) respondCurrent(command string, sender bot.Sender) {
+	info, err := self.api.Channel(self.channel)
+	if err != nil {
+		self.apiError(err, sender)
+		return
+	}
+
+	if command == "title" {
+		if info.Title == "" {
+			sender.Respond("there is no title set.")
+		} else {
+			sender.Respond("the current title is: " + info.Title)
+		}
+
+		return
+	}
+
+	if info.Game == "" {
+		sender.Respond("there is no game set.")
+	} else {
+		sender.Respond("the current game is " + info.Game + ".")
+	}
+}
+
+func (self *worker) setTitle(title string, sender bot.Sender) {
+	err := self.api.UpdateChannel(self.channel, title, "")
+	if err != nil {
+		self.apiError(err, sender)
+		return
+	}
+
+	sender.Respond("the title has been changed to: " + title)
+}
+
+func (self *worker) setGame(name string, sender bot.Sender) {
+	game, err := self.api.FindGame(name)
+
+	switch err {
+	case nil:
+	case twitch.ErrUnknownGame:
+		sender.Respond("there is no game or category named " + name + " on Twitch.")
+		return
+	default:
+		self.apiError(err, sender)
+		return
+	}
+
+	err = self.api.UpdateChannel(self.channel, "", game.ID)
+	if err != nil {
+		self.apiError(err, sender)
+		return
+	}
+
+	sender.Respond("the game has been changed to " + game.Name + ".")
+}
+
+func (self *worker) apiError(err error, sender bot.Sender) {
+	if err == twitch.ErrUnauthorized {
+		self.log.Warning("The Twitch API token is not allowed to edit %s: %s", self.channel, err.Error())
+		sender.Respond("the bot is not allowed to edit this channel; the broadcaster has to authorize it first.")
+		return
+	}
+
+	self.log.Error("Could not query the Twitch API: %s", err.Error())
+	sender.Respond("could not reach Twitch, please try again later.")
+}
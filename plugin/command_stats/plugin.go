@@ -0,0 +1,41 @@
+package command_stats
+
+import (
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	db *sqlx.DB
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "command_stats"
+}
+
+// run after everything else, so that we can see which commands have been handled
+func (self *pluginStruct) Priority() int {
+	return -100
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:     channel.Name(),
+		acl:         channel.ACL(),
+		db:          self.db,
+		syncing:     nil,
+		stopSyncing: nil,
+		mutex:       sync.RWMutex{},
+	}
+}
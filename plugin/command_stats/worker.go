@@ -0,0 +1,227 @@
+package command_stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"stats", "topcommands"}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel     string
+	acl         *bot.ACL
+	db          *sqlx.DB
+	stats       map[string]int
+	dirty       map[string]bool // commands that changed since the last sync
+	syncing     chan struct{}
+	stopSyncing chan struct{}
+	mutex       sync.RWMutex
+}
+
+type statDbStruct struct {
+	Command string
+	Counter int
+}
+
+func (self *worker) Enable() {
+	list := make([]statDbStruct, 0)
+	self.db.Select(&list, "SELECT command, counter FROM command_stats WHERE channel = ?", self.channel)
+
+	self.mutex.Lock()
+	self.stats = make(map[string]int)
+	self.dirty = make(map[string]bool)
+
+	for _, item := range list {
+		self.stats[item.Command] = item.Counter
+	}
+
+	self.mutex.Unlock()
+
+	self.syncing = make(chan struct{})
+	self.stopSyncing = make(chan struct{})
+
+	go self.worker()
+}
+
+func (self *worker) Disable() {
+	close(self.stopSyncing)
+	<-self.syncing
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"use_command_stats"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "use_command_stats"
+}
+
+// Database writes happen in the background, so counting is cheap.
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBot() {
+		return
+	}
+
+	if !msg.IsProcessed() {
+		if msg.IsCommand("stats") {
+			msg.SetProcessed()
+			self.handleStatsCommand(msg, sender)
+		} else if msg.IsCommand("topcommands") {
+			msg.SetProcessed()
+			self.handleTopCommandsCommand(msg, sender)
+		}
+	}
+
+	if !msg.IsProcessed() {
+		return
+	}
+
+	command := msg.Command()
+	if len(command) == 0 {
+		return
+	}
+
+	self.mutex.Lock()
+	self.stats[command]++
+	self.dirty[command] = true
+	self.mutex.Unlock()
+}
+
+func (self *worker) handleStatsCommand(msg *bot.TextMessage, sender bot.Sender) {
+	if !self.acl.IsAllowed(msg.User, "use_command_stats") {
+		return
+	}
+
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		sender.Respond("you did not give any command.")
+		return
+	}
+
+	command := strings.ToLower(strings.TrimPrefix(args[0], msg.Trigger()))
+
+	self.mutex.RLock()
+	count := self.stats[command]
+	self.mutex.RUnlock()
+
+	if count == 0 {
+		sender.Respond(msg.Trigger() + command + " has not been used yet.")
+	} else if count == 1 {
+		sender.Respond(msg.Trigger() + command + " has been used once.")
+	} else {
+		sender.Respond(fmt.Sprintf("%s%s has been used %s times.", msg.Trigger(), command, humanize.FormatInteger("#,###.", count)))
+	}
+}
+
+func (self *worker) handleTopCommandsCommand(msg *bot.TextMessage, sender bot.Sender) {
+	if !self.acl.IsAllowed(msg.User, "use_command_stats") {
+		return
+	}
+
+	top := self.topCommands(5)
+
+	if len(top) == 0 {
+		sender.Respond("no commands have been used yet.")
+		return
+	}
+
+	output := make([]string, len(top))
+
+	for idx, stat := range top {
+		output[idx] = fmt.Sprintf("%s%s (%s x)", msg.Trigger(), stat.command, humanize.FormatInteger("#,###.", stat.count))
+	}
+
+	sender.Respond("this channel's most used commands are: " + bot.HumanJoin(output, ", "))
+}
+
+func (self *worker) worker() {
+	defer close(self.syncing)
+
+	for {
+		select {
+		case <-time.After(5 * time.Minute):
+			self.sync()
+
+		case <-self.stopSyncing:
+			self.sync()
+			return
+		}
+	}
+}
+
+// sync writes only the counters that changed since the last time
+func (self *worker) sync() {
+	self.mutex.Lock()
+	changed := make(map[string]int, len(self.dirty))
+
+	for command := range self.dirty {
+		changed[command] = self.stats[command]
+	}
+
+	self.dirty = make(map[string]bool)
+	self.mutex.Unlock()
+
+	for command, counter := range changed {
+		self.db.Exec("DELETE FROM command_stats WHERE channel = ? AND command = ?", self.channel, command)
+		self.db.Exec("INSERT INTO command_stats (channel, command, counter) VALUES (?, ?, ?)", self.channel, command, counter)
+	}
+}
+
+type commandStat struct {
+	command string
+	count   int
+}
+
+// sorts by usage (descending), then by name
+type commandSorter []commandStat
+
+func (a commandSorter) Len() int {
+	return len(a)
+}
+
+func (a commandSorter) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a commandSorter) Less(i, j int) bool {
+	if a[i].count == a[j].count {
+		return a[i].command < a[j].command
+	}
+
+	return a[i].count > a[j].count
+}
+
+func (self *worker) topCommands(max int) []commandStat {
+	self.mutex.RLock()
+
+	result := make([]commandStat, 0, len(self.stats))
+
+	for command, count := range self.stats {
+		result = append(result, commandStat{command, count})
+	}
+
+	self.mutex.RUnlock()
+
+	sort.Sort(commandSorter(result))
+
+	if len(result) > max {
+		return result[:max]
+	}
+
+	return result
+}
@@ -19,6 +19,7 @@ type pluginStruct struct {
 	srcom      bool
 	srPrefix   string
 	dict       *bot.Dictionary
+	registry   *bot.CommandRegistry
 	commands   map[string]command
 	cmdMutex   sync.RWMutex
 }
@@ -67,6 +68,7 @@ func (self *pluginStruct) cmdKeyPrefix() string {
 
 func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 	self.dict = bot.Dictionary()
+	self.registry = bot.Commands()
 
 	self.cmdMutex.Lock()
 	defer self.cmdMutex.Unlock()
@@ -109,6 +111,10 @@ func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 			}
 		}
 	}
+
+	for cmd := range self.commands {
+		self.registry.Register(self.name, cmd)
+	}
 }
 
 func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
@@ -128,6 +134,19 @@ func (self *pluginStruct) resolveCommand(cmd string) (string, bool) {
 	return c.dictKey, okay
 }
 
+func (self *pluginStruct) commandNames() []string {
+	self.cmdMutex.RLock()
+	defer self.cmdMutex.RUnlock()
+
+	names := make([]string, 0, len(self.commands))
+
+	for cmd := range self.commands {
+		names = append(names, cmd)
+	}
+
+	return names
+}
+
 func (self *pluginStruct) defineCommand(cmd string, dictKey string, initialValue string) bool {
 	self.cmdMutex.Lock()
 	defer self.cmdMutex.Unlock()
@@ -142,6 +161,7 @@ func (self *pluginStruct) defineCommand(cmd string, dictKey string, initialValue
 		fixed:   false,
 	}
 
+	self.registry.Register(self.name, cmd)
 	self.dict.Set(self.cmdKeyPrefix()+cmd, dictKey)
 
 	if len(initialValue) > 0 {
@@ -166,6 +186,7 @@ func (self *pluginStruct) undefineCommand(cmd string) bool {
 	}
 
 	delete(self.commands, cmd)
+	self.registry.Unregister(self.name, cmd)
 
 	self.dict.Delete(self.cmdKeyPrefix() + cmd)
 
@@ -1,8 +1,6 @@
 package content
 
 import (
-	"strings"
-
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin"
 )
@@ -19,6 +17,14 @@ func (self *worker) Permissions() []string {
 	return []string{self.plugin.permission}
 }
 
+func (self *worker) Commands() []string {
+	return self.plugin.commandNames()
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return self.plugin.permission
+}
+
 func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsProcessed() {
 		return
@@ -38,13 +44,19 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 
 			cmdName := args[0]
 			dictKey := args[1]
-			initial := strings.Join(args[2:], " ")
+			initial := msg.ArgumentText(2)
+
+			owner, taken := self.plugin.registry.Owner(cmdName)
+			if taken && owner != name {
+				sender.Respond(msg.Trigger() + cmdName + " is already provided by the " + owner + " plugin.")
+				return
+			}
 
 			if self.plugin.defineCommand(cmdName, dictKey, initial) {
 				sender.Respond("new command !" + cmdName + " has been created.")
 			} else {
 				dictKey, _ := self.plugin.resolveCommand(cmdName)
-				sender.Respond("!" + cmdName + " already exists and points to '" + dictKey + "'.")
+				sender.Respond(msg.Trigger() + cmdName + " already exists and points to '" + dictKey + "'.")
 			}
 
 			return
@@ -62,7 +74,7 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 			if self.plugin.undefineCommand(cmdName) {
 				sender.Respond("the command !" + cmdName + " has been removed.")
 			} else {
-				sender.Respond("!" + cmdName + " does not exist or cannot be removed.")
+				sender.Respond(msg.Trigger() + cmdName + " does not exist or cannot be removed.")
 			}
 
 			return
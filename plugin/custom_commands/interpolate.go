@@ -0,0 +1,57 @@
+package custom_commands
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+var tokenRegex = regexp.MustCompile(`\$\(([a-z0-9]+)\)`)
+
+// interpolate replaces tokens like $(user) or $(arg1) in a command response with
+// values from the message that triggered the command. $(count) is replaced with the
+// given counter value. Unknown tokens are left as-is.
+func interpolate(template string, msg *bot.TextMessage, count int) string {
+	args := msg.Arguments()
+
+	return tokenRegex.ReplaceAllStringFunc(template, func(token string) string {
+		name := tokenRegex.FindStringSubmatch(token)[1]
+
+		switch name {
+		case "user":
+			return msg.User.Name
+
+		case "channel":
+			return strings.TrimPrefix(msg.Channel, "#")
+
+		case "args":
+			return strings.Join(args, " ")
+
+		case "touser":
+			if len(args) > 0 {
+				return strings.TrimPrefix(args[0], "@")
+			}
+
+			return msg.User.Name
+
+		case "count":
+			return strconv.Itoa(count)
+		}
+
+		// $(argN) with N starting at 1
+		if strings.HasPrefix(name, "arg") {
+			n, err := strconv.Atoi(strings.TrimPrefix(name, "arg"))
+			if err == nil && n > 0 {
+				if n > len(args) {
+					return ""
+				}
+
+				return args[n-1]
+			}
+		}
+
+		return token
+	})
+}
@@ -1,30 +1,69 @@
 package custom_commands
 
 import (
+	"math/rand"
+	"time"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/sgt-kabukiman/kabukibot/bot"
 )
 
+// mods (and the broadcaster) need to test commands, but some channels want them
+// to wait like everybody else; the operator is never held back
+var exemptMods = bot.BoolSetting(true)
+
 type pluginStruct struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	log      bot.Logger
+	registry *bot.CommandRegistry
+	settings *bot.Settings
+	now      func() time.Time
+	intn     func(int) int
 }
 
 func NewPlugin() *pluginStruct {
-	return &pluginStruct{}
+	return NewPluginWith(time.Now, nil)
+}
+
+// NewPluginWith lets the tests control the time and which response is picked;
+// intn must behave like rand.Intn. Without it, every channel gets its own RNG.
+func NewPluginWith(now func() time.Time, intn func(int) int) *pluginStruct {
+	return &pluginStruct{now: now, intn: intn}
 }
 
 func (self *pluginStruct) Name() string {
 	return "custom_commands"
 }
 
+func (self *pluginStruct) Dependencies() []string {
+	return []string{"acl"}
+}
+
 func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 	self.db = bot.Database()
+	self.log = bot.Logger()
+	self.registry = bot.Commands()
+	self.settings = bot.Settings()
+	self.settings.Register(self.Name(), "exempt_mods", exemptMods)
+
+	// custom commands themselves are per channel and hence not registered
+	self.registry.Register(self.Name(), pluginCommands...)
 }
 
 func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	intn := self.intn
+	if intn == nil {
+		intn = rand.New(rand.NewSource(time.Now().UnixNano())).Intn
+	}
+
 	return &worker{
-		channel: channel,
-		acl:     channel.ACL(),
-		db:      self.db,
+		channel:  channel,
+		acl:      channel.ACL(),
+		db:       self.db,
+		log:      self.log,
+		registry: self.registry,
+		settings: self.settings,
+		now:      self.now,
+		intn:     intn,
 	}
 }
@@ -2,14 +2,17 @@ package custom_commands
 
 import (
 	"fmt"
-	"log"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin"
 	"github.com/sgt-kabukiman/kabukibot/plugin/acl"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
 )
 
 type worker struct {
@@ -19,24 +22,109 @@ type worker struct {
 	acl       *bot.ACL
 	aclWorker *acl.Worker
 	db        *sqlx.DB
-	commands  map[string]string
+	log       bot.Logger
+	registry  *bot.CommandRegistry
+	settings  *bot.Settings
+	commands  map[string]command
+	aliases   map[string]string
+	lastUsed  map[string]time.Time
+	warned    map[string]time.Time // when the cooldown started that users were warned about
+	now       func() time.Time
+	intn      func(int) int
+}
+
+type command struct {
+	Responses    []string
+	Cooldown     time.Duration
+	UserCooldown time.Duration
+	WarnCooldown bool
+	Group        string
 }
 
 type ccDbStruct struct {
+	Command      string
+	Message      string
+	Cooldown     int
+	UserCooldown int `db:"user_cooldown"`
+	WarnCooldown int `db:"warn_cooldown"`
+}
+
+type ccResponseDbStruct struct {
 	Command string
 	Message string
 }
 
+type ccGroupDbStruct struct {
+	Command string
+	Group   string `db:"group_name"`
+}
+
+type ccAliasDbStruct struct {
+	Alias   string
+	Command string
+}
+
 func (self *worker) Enable() {
+	self.lastUsed = make(map[string]time.Time)
+	self.warned = make(map[string]time.Time)
+	self.load()
+}
+
+// running cooldowns are kept
+func (self *worker) Reload() {
+	self.load()
+}
+
+func (self *worker) load() {
 	list := make([]ccDbStruct, 0)
-	self.db.Select(&list, "SELECT command, message FROM custom_commands WHERE channel = ? ORDER BY command", self.channel.Name())
+	self.db.Select(&list, "SELECT command, message, cooldown, user_cooldown, warn_cooldown FROM custom_commands WHERE channel = ? ORDER BY command", self.channel.Name())
+
+	self.commands = make(map[string]command)
 
-	self.commands = make(map[string]string)
+	responses := make([]ccResponseDbStruct, 0)
+	self.db.Select(&responses, "SELECT command, message FROM custom_command_responses WHERE channel = ? ORDER BY command, position", self.channel.Name())
+
+	groups := make([]ccGroupDbStruct, 0)
+	self.db.Select(&groups, "SELECT command, group_name FROM custom_command_groups WHERE channel = ?", self.channel.Name())
 
 	for _, item := range list {
-		self.commands[item.Command] = item.Message
+		cc := command{
+			Responses:    make([]string, 0),
+			Cooldown:     time.Duration(item.Cooldown) * time.Second,
+			UserCooldown: time.Duration(item.UserCooldown) * time.Second,
+			WarnCooldown: item.WarnCooldown != 0,
+		}
+
+		for _, group := range groups {
+			if group.Command == item.Command {
+				cc.Group = group.Group
+			}
+		}
+
+		for _, response := range responses {
+			if response.Command == item.Command {
+				cc.Responses = append(cc.Responses, response.Message)
+			}
+		}
+
+		// commands created before multiple responses existed only have their message
+		if len(cc.Responses) == 0 {
+			cc.Responses = append(cc.Responses, item.Message)
+		}
+
+		self.commands[item.Command] = cc
 	}
 
+	aliases := make([]ccAliasDbStruct, 0)
+	self.db.Select(&aliases, "SELECT alias, command FROM custom_command_aliases WHERE channel = ? ORDER BY alias", self.channel.Name())
+
+	self.aliases = make(map[string]string)
+
+	for _, item := range aliases {
+		self.aliases[item.Alias] = item.Command
+	}
+
+	// the ACL plugin is a dependency, so the bot made sure it exists
 	for _, w := range self.channel.Workers() {
 		asserted, okay := w.(*acl.Worker)
 		if okay {
@@ -44,10 +132,6 @@ func (self *worker) Enable() {
 			break
 		}
 	}
-
-	if self.aclWorker == nil {
-		panic("Cannot run the custom commands plugin without the ACL plugin.")
-	}
 }
 
 func (self *worker) Permissions() []string {
@@ -60,6 +144,58 @@ func (self *worker) Permissions() []string {
 	return permissions
 }
 
+// aliases are listed as well, so users find the command under any of its names
+func (self *worker) Commands() []string {
+	commands := append([]string{}, pluginCommands...)
+
+	for cmd := range self.commands {
+		commands = append(commands, cmd)
+	}
+
+	for alias := range self.aliases {
+		commands = append(commands, alias)
+	}
+
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	canonical, isAlias := self.aliases[command]
+	if isAlias {
+		command = canonical
+	}
+
+	return requiredPermission(command)
+}
+
+// custom commands are described by what they respond with
+func (self *worker) DescribeCommand(command string) (bot.CommandInfo, bool) {
+	canonical, isAlias := self.aliases[command]
+	if isAlias {
+		info, _ := self.DescribeCommand(canonical)
+		info.Description = "an alias for " + self.mention(canonical) + ", which " + info.Description
+
+		return info, true
+	}
+
+	usage, isSysCmd := pluginCommandUsages[command]
+	if isSysCmd {
+		return bot.CommandInfo{Description: usage.description, Usage: usage.args, Permission: requiredPermission(command)}, true
+	}
+
+	cc, isUserCmd := self.commands[command]
+	if !isUserCmd {
+		return bot.CommandInfo{}, false
+	}
+
+	description := "responds with \"" + cc.Responses[0] + "\"."
+	if len(cc.Responses) > 1 {
+		description = fmt.Sprintf("responds with one of %d texts.", len(cc.Responses))
+	}
+
+	return bot.CommandInfo{Description: description, Permission: requiredPermission(command)}, true
+}
+
 func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsProcessed() || msg.IsFromBot() {
 		return
@@ -70,8 +206,14 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 		return
 	}
 
+	// aliases share everything (permission, cooldown, counter) with their canonical command
+	canonical, isAlias := self.aliases[command]
+	if isAlias {
+		command = canonical
+	}
+
 	isSysCmd := isPluginCommand(command)
-	response, isUserCmd := self.commands[command]
+	custom, isUserCmd := self.commands[command]
 
 	if !isSysCmd && !isUserCmd {
 		return
@@ -83,61 +225,111 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 		return
 	}
 
-	switch command {
-	case "cc_list":
-		self.respondList(sender)
+	if !isSysCmd {
+		self.respondCustom(command, custom, msg, sender)
+		return
+	}
+
+	if command == "cc_list" {
+		self.respondList(msg, sender)
+		return
+	}
+
+	// all other cc_* commands take the custom command name as their first argument
+	usage := pluginCommandUsages[command]
+	if !msg.RequireArgs(usage.min, usage.args, sender) {
+		return
+	}
+
+	args := msg.Arguments()
 
+	cc := normalizeCommand(args[0])
+	if len(cc) < 1 {
+		sender.Respond("invalid command name given.")
+		return
+	}
+
+	switch command {
 	case "cc_allow":
-		fallthrough
+		self.respondAllowDeny("allow", cc, args[1:], sender)
 	case "cc_deny":
-		fallthrough
+		self.respondAllowDeny("deny", cc, args[1:], sender)
 	case "cc_get":
-		fallthrough
+		self.respondGet(cc, sender)
 	case "cc_set":
-		fallthrough
+		self.respondSet(cc, msg.ArgumentText(1), sender)
+	case "cc_add":
+		self.respondAdd(cc, msg.ArgumentText(1), sender)
 	case "cc_del":
-		args := msg.Arguments()
-		if len(args) < 1 {
-			sender.Respond("no command name given.")
-			return
-		}
+		self.respondDelete(cc, sender)
+	case "cc_cooldown":
+		self.respondCooldown(cc, args[1:], sender)
+	case "cc_group":
+		self.respondGroup(cc, args[1:], sender)
+	case "cc_setcount":
+		self.respondSetCount(cc, args[1:], sender)
+	case "cc_alias":
+		self.respondAlias(cc, args[1:], sender)
+	case "cc_unalias":
+		self.respondUnalias(cc, sender)
+	case "cc_rename":
+		self.respondRename(cc, args[1:], sender)
+	}
+}
 
-		cc := normalizeCommand(args[0])
-		if len(cc) < 1 {
-			sender.Respond("invalid command name given.")
-			return
+func (self *worker) respondCustom(cmd string, custom command, msg *bot.TextMessage, sender bot.Sender) {
+	left, key := self.onCooldown(cmd, custom, msg.User)
+	if left > 0 {
+		if custom.WarnCooldown {
+			self.warnCooldown(cmd, key, left, sender)
 		}
 
-		switch command {
-		case "cc_allow":
-			self.respondAllowDeny("allow", cc, args[1:], sender)
-		case "cc_deny":
-			self.respondAllowDeny("deny", cc, args[1:], sender)
-		case "cc_get":
-			self.respondGet(cc, sender)
-		case "cc_set":
-			self.respondSet(cc, args[1:], sender)
-		case "cc_del":
-			self.respondDelete(cc, sender)
-		}
+		return
+	}
 
-	default:
-		sender.SendText(response)
+	response := custom.Responses[self.intn(len(custom.Responses))]
+
+	// only touch the counter if the command actually uses it
+	count := 0
+
+	if strings.Contains(response, "$(count)") {
+		var err error
+
+		count, err = self.incrementCounter(cmd)
+		if err != nil {
+			bot.DatabaseError(self.log, sender, "Could not increment custom command counter: %s", err)
+			return
+		}
 	}
+
+	sender.SendText(interpolate(response, msg, count))
 }
 
-func (self *worker) respondList(sender bot.Sender) {
+func (self *worker) respondList(msg *bot.TextMessage, sender bot.Sender) {
 	var commands []string
 
 	for cmd, _ := range self.commands {
-		commands = append(commands, "!"+cmd)
+		commands = append(commands, self.mention(cmd))
 	}
 
+	sort.Strings(commands)
+
 	if len(commands) == 0 {
 		sender.Respond("no custom commands have been defined yet.")
 	} else {
-		sender.Respond(fmt.Sprintf("this channel's custom commands are: %s", bot.HumanJoin(commands, ", ")))
+		page := bot.Paginate(commands, self.pageNumber(msg), self.settings.Int(self.channel.Name(), bot.PageSizeSetting))
+		sender.Respond(fmt.Sprintf("this channel's custom commands are: %s", page.Join(", ")))
+	}
+}
+
+// pageNumber is the optional first argument of list commands
+func (self *worker) pageNumber(msg *bot.TextMessage) int {
+	args := msg.Arguments()
+	if len(args) == 0 {
+		return 1
 	}
+
+	return bot.PageNumber(args[0])
 }
 
 func (self *worker) respondAllowDeny(kind string, cmd string, args []string, sender bot.Sender) {
@@ -149,50 +341,130 @@ func (self *worker) respondAllowDeny(kind string, cmd string, args []string, sen
 
 	permission := permissionForCommand(cmd)
 
-	self.aclWorker.HandleAllowDeny(kind == "allow", permission, args, sender, "!"+cmd)
+	self.aclWorker.HandleAllowDeny(kind == "allow", permission, args, sender, self.mention(cmd))
 }
 
 func (self *worker) respondGet(cmd string, sender bot.Sender) {
-	response, exists := self.commands[cmd]
+	cc, exists := self.commands[cmd]
 	if !exists {
 		sender.Respond("there is no custom command named '" + cmd + "'.")
 		return
 	}
 
-	sender.Respond("!" + cmd + " = " + response)
+	sender.Respond(self.mention(cmd) + " = " + strings.Join(cc.Responses, " | "))
 }
 
-func (self *worker) respondSet(cmd string, args []string, sender bot.Sender) {
-	if len(args) < 1 {
-		sender.Respond("you did not give any response text for the new !" + cmd + " command.")
+func (self *worker) respondSet(cmd string, response string, sender bot.Sender) {
+	if isPluginCommand(cmd) {
+		sender.Respond("you cannot overwrite cc_* commands.")
 		return
 	}
 
-	if isPluginCommand(cmd) {
-		sender.Respond("you cannot overwrite cc_* commands.")
+	owner, taken := self.providedByPlugin(cmd)
+	if taken {
+		sender.Respond(self.mention(cmd) + " is already provided by the " + owner + " plugin.")
 		return
 	}
 
-	_, exists := self.commands[cmd]
-	response := strings.Join(args, " ")
+	target, isAlias := self.aliases[cmd]
+	if isAlias {
+		sender.Respond(self.mention(cmd) + " is an alias for " + self.mention(target) + ", remove it first via `" + self.mention("cc_unalias") + " " + cmd + "`.")
+		return
+	}
+
+	cc, exists := self.commands[cmd]
 
-	self.commands[cmd] = response
+	// cc_set always replaces all responses
+	responses := []string{response}
+
+	// the command and its responses are stored together or not at all
+	tx, err := self.db.Beginx()
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not start transaction: %s", err)
+		return
+	}
 
 	if exists {
-		sender.Respond("command !" + cmd + " has been updated.")
+		_, err = tx.Exec("UPDATE custom_commands SET message = ? WHERE channel = ? AND command = ?", response, self.channel.Name(), cmd)
+	} else {
+		_, err = tx.Exec("INSERT INTO custom_commands (channel, command, message) VALUES (?, ?, ?)", self.channel.Name(), cmd, response)
+	}
 
-		_, err := self.db.Exec("UPDATE custom_commands SET message = ? WHERE channel = ? AND command = ?", response, self.channel.Name(), cmd)
-		if err != nil {
-			log.Fatal("Could not update new custom command: " + err.Error())
-		}
+	if err == nil {
+		err = self.storeResponses(tx, cmd, responses)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		bot.DatabaseError(self.log, sender, "Could not store custom command: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store custom command: %s", err)
+		return
+	}
+
+	cc.Responses = responses
+	self.commands[cmd] = cc
+
+	if exists {
+		sender.Respond("command " + self.mention(cmd) + " has been updated.")
 	} else {
-		sender.Respond("command !" + cmd + " has been created. Do not forget to set permissions via `!cc_allow " + cmd + " $mods,someone,etc`.")
+		sender.Respond("command " + self.mention(cmd) + " has been created. Do not forget to set permissions via `" + self.mention("cc_allow") + " " + cmd + " $mods,someone,etc`.")
+	}
+}
+
+func (self *worker) respondAdd(cmd string, response string, sender bot.Sender) {
+	cc, exists := self.commands[cmd]
+	if !exists {
+		sender.Respond("there is no custom command named '" + cmd + "', create it via `" + self.mention("cc_set") + " " + cmd + " <text>` first.")
+		return
+	}
+
+	cc.Responses = append(cc.Responses, response)
+
+	tx, err := self.db.Beginx()
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not start transaction: %s", err)
+		return
+	}
+
+	err = self.storeResponses(tx, cmd, cc.Responses)
+	if err != nil {
+		tx.Rollback()
+		bot.DatabaseError(self.log, sender, "Could not store custom command responses: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store custom command responses: %s", err)
+		return
+	}
+
+	self.commands[cmd] = cc
+
+	sender.Respond(fmt.Sprintf("added response #%d to %s.", len(cc.Responses), self.mention(cmd)))
+}
+
+// storeResponses replaces all responses of a command; as this takes multiple
+// queries, it has to be run in a transaction.
+func (self *worker) storeResponses(tx *sqlx.Tx, cmd string, responses []string) error {
+	_, err := tx.Exec("DELETE FROM custom_command_responses WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+	if err != nil {
+		return err
+	}
 
-		_, err := self.db.Exec("INSERT INTO custom_commands (channel, command, message) VALUES (?, ?, ?)", self.channel.Name(), cmd, response)
+	for idx, response := range responses {
+		_, err := tx.Exec("INSERT INTO custom_command_responses (channel, command, position, message) VALUES (?, ?, ?, ?)", self.channel.Name(), cmd, idx, response)
 		if err != nil {
-			log.Fatal("Could not store new custom command: " + err.Error())
+			return err
 		}
 	}
+
+	return nil
 }
 
 func (self *worker) respondDelete(cmd string, sender bot.Sender) {
@@ -202,22 +474,477 @@ func (self *worker) respondDelete(cmd string, sender bot.Sender) {
 		return
 	}
 
-	sender.Respond("!" + cmd + " has been deleted.")
+	// cleanup database, including the ACL entries
+	err := self.acl.DeletePermission(permissionForCommand(cmd), func(tx *sqlx.Tx) error {
+		return self.deleteFromDatabase(tx, cmd)
+	})
+
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not delete custom command: %s", err)
+		return
+	}
 
 	delete(self.commands, cmd)
 
-	// cleanup database
-	_, err := self.db.Exec("DELETE FROM custom_commands WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+	// aliases would be dangling now
+	for alias, target := range self.aliases {
+		if target == cmd {
+			delete(self.aliases, alias)
+		}
+	}
+
+	sender.Respond(self.mention(cmd) + " has been deleted.")
+}
+
+func (self *worker) respondCooldown(cmd string, args []string, sender bot.Sender) {
+	cc, exists := self.commands[cmd]
+	if !exists {
+		sender.Respond("there is no custom command named '" + cmd + "'.")
+		return
+	}
+
+	// without "warn", invocations during the cooldown are silently ignored
+	warn := 0
+
+	if len(args) > 1 && args[len(args)-1] == "warn" {
+		warn = 1
+		args = args[:len(args)-1]
+	}
+
+	global, err := strconv.Atoi(args[0])
+	if err != nil || global < 0 {
+		sender.Respond("invalid global cooldown given, expected a number of seconds.")
+		return
+	}
+
+	user := 0
+
+	if len(args) > 1 {
+		user, err = strconv.Atoi(args[1])
+		if err != nil || user < 0 {
+			sender.Respond("invalid per-user cooldown given, expected a number of seconds.")
+			return
+		}
+	}
+
+	_, err = self.db.Exec("UPDATE custom_commands SET cooldown = ?, user_cooldown = ?, warn_cooldown = ? WHERE channel = ? AND command = ?", global, user, warn, self.channel.Name(), cmd)
 	if err != nil {
-		log.Fatal("Could not delete new custom command: " + err.Error())
+		bot.DatabaseError(self.log, sender, "Could not update custom command cooldown: %s", err)
+		return
+	}
+
+	cc.Cooldown = time.Duration(global) * time.Second
+	cc.UserCooldown = time.Duration(user) * time.Second
+	cc.WarnCooldown = warn != 0
+
+	self.commands[cmd] = cc
+
+	response := fmt.Sprintf("the cooldown for %s is now %ds globally and %ds per user", self.mention(cmd), global, user)
+	if cc.WarnCooldown {
+		response += ", users are warned once when they have to wait"
 	}
 
-	// cleanup ACL entries
-	self.acl.DeletePermission(permissionForCommand(cmd))
+	sender.Respond(response + ".")
+}
+
+func (self *worker) respondGroup(cmd string, args []string, sender bot.Sender) {
+	cc, exists := self.commands[cmd]
+	if !exists {
+		sender.Respond("there is no custom command named '" + cmd + "'.")
+		return
+	}
+
+	if args[0] == "off" {
+		_, err := self.db.Exec("DELETE FROM custom_command_groups WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+		if err != nil {
+			bot.DatabaseError(self.log, sender, "Could not delete custom command group: %s", err)
+			return
+		}
+
+		cc.Group = ""
+		self.commands[cmd] = cc
+
+		sender.Respond(self.mention(cmd) + " has its own cooldown again.")
+		return
+	}
+
+	group := normalizeCommand(args[0])
+	if len(group) < 1 {
+		sender.Respond("invalid group name given.")
+		return
+	}
+
+	tx, err := self.db.Beginx()
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store custom command group: %s", err)
+		return
+	}
+
+	tx.Exec("DELETE FROM custom_command_groups WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+
+	_, err = tx.Exec("INSERT INTO custom_command_groups (channel, command, group_name) VALUES (?, ?, ?)", self.channel.Name(), cmd, group)
+	if err != nil {
+		tx.Rollback()
+		bot.DatabaseError(self.log, sender, "Could not store custom command group: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store custom command group: %s", err)
+		return
+	}
+
+	cc.Group = group
+	self.commands[cmd] = cc
+
+	sender.Respond(self.mention(cmd) + " now shares its cooldown with the '" + group + "' group.")
+}
+
+func (self *worker) respondAlias(cmd string, args []string, sender bot.Sender) {
+	_, exists := self.commands[cmd]
+	if !exists {
+		sender.Respond("there is no custom command named '" + cmd + "'.")
+		return
+	}
+
+	alias := normalizeCommand(args[0])
+	if len(alias) < 1 {
+		sender.Respond("invalid alias given.")
+		return
+	}
+
+	if isPluginCommand(alias) {
+		sender.Respond("you cannot overwrite cc_* commands.")
+		return
+	}
+
+	owner, taken := self.providedByPlugin(alias)
+	if taken {
+		sender.Respond(self.mention(alias) + " is already provided by the " + owner + " plugin.")
+		return
+	}
+
+	_, exists = self.commands[alias]
+	if exists {
+		sender.Respond("there already is a custom command named '" + alias + "'.")
+		return
+	}
+
+	target, exists := self.aliases[alias]
+	if exists {
+		sender.Respond(self.mention(alias) + " is already an alias for " + self.mention(target) + ".")
+		return
+	}
+
+	_, err := self.db.Exec("INSERT INTO custom_command_aliases (channel, alias, command) VALUES (?, ?, ?)", self.channel.Name(), alias, cmd)
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store custom command alias: %s", err)
+		return
+	}
+
+	self.aliases[alias] = cmd
+
+	sender.Respond(self.mention(alias) + " is now an alias for " + self.mention(cmd) + ".")
+}
+
+func (self *worker) respondUnalias(alias string, sender bot.Sender) {
+	target, exists := self.aliases[alias]
+	if !exists {
+		sender.Respond("there is no alias named '" + alias + "'.")
+		return
+	}
+
+	_, err := self.db.Exec("DELETE FROM custom_command_aliases WHERE channel = ? AND alias = ?", self.channel.Name(), alias)
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not delete custom command alias: %s", err)
+		return
+	}
+
+	delete(self.aliases, alias)
+
+	sender.Respond(self.mention(alias) + " is no longer an alias for " + self.mention(target) + ".")
+}
+
+func (self *worker) respondRename(cmd string, args []string, sender bot.Sender) {
+	cc, exists := self.commands[cmd]
+	if !exists {
+		sender.Respond("there is no custom command named '" + cmd + "'.")
+		return
+	}
+
+	name := normalizeCommand(args[0])
+	if len(name) < 1 {
+		sender.Respond("invalid command name given.")
+		return
+	}
+
+	if isPluginCommand(name) {
+		sender.Respond("you cannot overwrite cc_* commands.")
+		return
+	}
+
+	owner, taken := self.providedByPlugin(name)
+	if taken {
+		sender.Respond(self.mention(name) + " is already provided by the " + owner + " plugin.")
+		return
+	}
+
+	_, exists = self.commands[name]
+	if exists {
+		sender.Respond("there already is a custom command named '" + name + "'.")
+		return
+	}
+
+	target, exists := self.aliases[name]
+	if exists {
+		sender.Respond(self.mention(name) + " is already an alias for " + self.mention(target) + ".")
+		return
+	}
+
+	// existing grants should still work under the new name
+	err := self.acl.RenamePermission(permissionForCommand(cmd), permissionForCommand(name), func(tx *sqlx.Tx) error {
+		return self.renameInDatabase(tx, cmd, name)
+	})
+
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not rename custom command: %s", err)
+		return
+	}
+
+	delete(self.commands, cmd)
+	self.commands[name] = cc
+
+	for alias, target := range self.aliases {
+		if target == cmd {
+			self.aliases[alias] = name
+		}
+	}
+
+	// keep running cooldowns (and do not warn about them again)
+	prefix := cmd + "/"
+
+	for _, timestamps := range []map[string]time.Time{self.lastUsed, self.warned} {
+		for key, used := range timestamps {
+			if key == cmd {
+				timestamps[name] = used
+				delete(timestamps, key)
+			} else if strings.HasPrefix(key, prefix) {
+				timestamps[name+"/"+strings.TrimPrefix(key, prefix)] = used
+				delete(timestamps, key)
+			}
+		}
+	}
+
+	sender.Respond(self.mention(cmd) + " has been renamed to " + self.mention(name) + ".")
+}
+
+// deleteFromDatabase removes the command, its responses, counter, aliases and
+// group; the transaction also removes the permission to use the command.
+func (self *worker) deleteFromDatabase(tx *sqlx.Tx, cmd string) error {
+	tables := []string{"custom_commands", "custom_command_responses", "custom_command_counters", "custom_command_aliases", "custom_command_groups"}
+
+	for _, table := range tables {
+		_, err := tx.Exec("DELETE FROM "+table+" WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameInDatabase moves the command, its responses, counter and aliases; the
+// transaction also renames the permission to use the command.
+func (self *worker) renameInDatabase(tx *sqlx.Tx, cmd string, name string) error {
+	tables := []string{"custom_commands", "custom_command_responses", "custom_command_counters", "custom_command_aliases", "custom_command_groups"}
+
+	for _, table := range tables {
+		_, err := tx.Exec("UPDATE "+table+" SET command = ? WHERE channel = ? AND command = ?", name, self.channel.Name(), cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (self *worker) respondSetCount(cmd string, args []string, sender bot.Sender) {
+	_, exists := self.commands[cmd]
+	if !exists {
+		sender.Respond("there is no custom command named '" + cmd + "'.")
+		return
+	}
+
+	value, err := strconv.Atoi(args[0])
+	if err != nil {
+		sender.Respond("invalid counter value given, expected a number.")
+		return
+	}
+
+	err = self.setCounter(cmd, value)
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store custom command counter: %s", err)
+		return
+	}
+
+	sender.Respond(fmt.Sprintf("the counter for %s has been set to %d.", self.mention(cmd), value))
+}
+
+// incrementCounter atomically bumps the persistent counter of a command and returns
+// the new value. The row is created on first use.
+func (self *worker) incrementCounter(cmd string) (int, error) {
+	tx, err := self.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("UPDATE custom_command_counters SET value = value + 1 WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		_, err = tx.Exec("INSERT INTO custom_command_counters (channel, command, value) VALUES (?, ?, 1)", self.channel.Name(), cmd)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	value := 0
+
+	err = tx.Get(&value, "SELECT value FROM custom_command_counters WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return value, tx.Commit()
+}
+
+func (self *worker) setCounter(cmd string, value int) error {
+	tx, err := self.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	tx.Exec("DELETE FROM custom_command_counters WHERE channel = ? AND command = ?", self.channel.Name(), cmd)
+
+	_, err = tx.Exec("INSERT INTO custom_command_counters (channel, command, value) VALUES (?, ?, ?)", self.channel.Name(), cmd, value)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// onCooldown checks the global and the per-user cooldown of a command independently
+// and, if the command may be used, remembers this invocation. Grouped commands
+// share their last-used timestamps, but each applies its own cooldown durations.
+// Exempt users are never held back, but still start the cooldown for everybody else.
+// If the command is on cooldown, the time left and the key of the cooldown that
+// held it back are returned.
+func (self *worker) onCooldown(cmd string, cc command, user twitch.User) (time.Duration, string) {
+	now := self.now()
+	exempt := self.isExempt(user)
+
+	// command names never contain "@", so groups cannot collide with them
+	key := cmd
+	if cc.Group != "" {
+		key = "@" + cc.Group
+	}
+
+	userKey := key + "/" + strings.ToLower(user.Name)
+
+	if !exempt && cc.Cooldown > 0 && now.Sub(self.lastUsed[key]) < cc.Cooldown {
+		return cc.Cooldown - now.Sub(self.lastUsed[key]), key
+	}
+
+	if !exempt && cc.UserCooldown > 0 && now.Sub(self.lastUsed[userKey]) < cc.UserCooldown {
+		return cc.UserCooldown - now.Sub(self.lastUsed[userKey]), userKey
+	}
+
+	self.lastUsed[key] = now
+	self.lastUsed[userKey] = now
+
+	return 0, ""
+}
+
+// warnCooldown tells users that the command is on cooldown, but only once per
+// cooldown; further attempts are ignored until it has run out.
+func (self *worker) warnCooldown(cmd string, key string, left time.Duration, sender bot.Sender) {
+	started := self.lastUsed[key]
+
+	if self.warned[key].Equal(started) {
+		return
+	}
+
+	self.warned[key] = started
+
+	seconds := int((left + time.Second - 1) / time.Second)
+	sender.Respond(fmt.Sprintf("%s is on cooldown (%ds left).", self.mention(cmd), seconds))
+}
+
+func (self *worker) isExempt(user twitch.User) bool {
+	if self.acl.IsOperator(user) {
+		return true
+	}
+
+	return self.settings.Bool(self.channel.Name(), "custom_commands.exempt_mods") && self.acl.IsTrusted(user)
+}
+
+var pluginCommands = []string{
+	"cc_set", "cc_add", "cc_get", "cc_del", "cc_list", "cc_allow", "cc_deny",
+	"cc_cooldown", "cc_group", "cc_setcount", "cc_alias", "cc_unalias", "cc_rename",
+}
+
+type commandUsage struct {
+	min         int // number of arguments
+	args        string
+	description string
+}
+
+// how to use the cc_* commands
+var pluginCommandUsages = map[string]commandUsage{
+	"cc_set":      {2, "<command> <text>", "creates a custom command or replaces all of its responses."},
+	"cc_add":      {2, "<command> <text>", "adds another response, one of which is picked at random."},
+	"cc_get":      {1, "<command>", "shows the responses of a custom command."},
+	"cc_del":      {1, "<command>", "deletes a custom command, including its aliases."},
+	"cc_list":     {0, "[page]", "lists all custom commands."},
+	"cc_allow":    {1, "<command> <users/groups>", "lets users use a custom command."},
+	"cc_deny":     {1, "<command> <users/groups>", "stops users from using a custom command."},
+	"cc_cooldown": {2, "<command> <global-seconds> [user-seconds] [warn]", "sets how often a custom command can be used."},
+	"cc_group":    {2, "<command> <group|off>", "lets custom commands in the same group share their cooldown."},
+	"cc_setcount": {2, "<command> <n>", "sets the counter used by $(count)."},
+	"cc_alias":    {2, "<command> <alias>", "makes a custom command available under another name."},
+	"cc_unalias":  {1, "<alias>", "removes an alias."},
+	"cc_rename":   {2, "<command> <new-name>", "renames a custom command."},
 }
 
 func isPluginCommand(cmd string) bool {
-	return cmd == "cc_set" || cmd == "cc_get" || cmd == "cc_del" || cmd == "cc_list" || cmd == "cc_allow" || cmd == "cc_deny"
+	for _, c := range pluginCommands {
+		if c == cmd {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mention formats a command the way users have to type it in this channel
+func (self *worker) mention(cmd string) string {
+	return self.channel.Trigger() + cmd
+}
+
+// providedByPlugin returns the plugin that owns the command, unless it is one of our own
+func (self *worker) providedByPlugin(cmd string) (string, bool) {
+	owner, exists := self.registry.Owner(cmd)
+
+	return owner, exists && owner != "custom_commands"
 }
 
 func requiredPermission(cmd string) string {
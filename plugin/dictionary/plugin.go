@@ -51,7 +51,7 @@ func (self *pluginStruct) handleSet(msg *bot.TextMessage, sender bot.Sender) {
 	}
 
 	key := args[0]
-	value := strings.Join(args[1:], " ")
+	value := msg.ArgumentText(1)
 	exists := self.dict.Has(key)
 
 	self.dict.Set(key, value)
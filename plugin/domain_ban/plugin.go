@@ -17,6 +17,11 @@ func (self *pluginStruct) Name() string {
 	return "domain_ban"
 }
 
+// links to banned domains are removed before anything reacts to them
+func (self *pluginStruct) Priority() int {
+	return bot.ModerationPriority
+}
+
 func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 	self.db = bot.Database()
 }
@@ -232,7 +232,7 @@ func (self *worker) textMessage(msg *bot.TextMessage, sender bot.Sender) {
 		return
 	}
 
-	links := xurls.Relaxed.FindAllString(msg.Text, -1)
+	links := xurls.Relaxed.FindAllString(msg.Normalized(), -1)
 	if len(links) == 0 {
 		return
 	}
@@ -296,7 +296,7 @@ func (self *worker) textMessage(msg *bot.TextMessage, sender bot.Sender) {
 		sender.Ban(name)
 		sender.Respond("posting that link was a bad idea and got you permanently banned.")
 	} else {
-		sender.Timeout(name, int(action.Timeout.Seconds()))
+		sender.Timeout(name, int(action.Timeout.Seconds()), "")
 		sender.Respond(fmt.Sprintf(
 			"posting that link was a bad idea and got you timed out for %s.",
 			bot.FormatDuration(action.Timeout, true),
@@ -0,0 +1,30 @@
+package dry_run
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// The dry_run plugin lets the operator silence the bot, either in a single
+// channel or everywhere. In dry-run mode, everything that would be sent is
+// logged instead, which is handy to watch new plugins against live chat.
+type pluginStruct struct {
+	plugin.BasePlugin
+
+	bot *bot.Kabukibot
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.bot = bot
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		bot:     self.bot,
+		channel: channel.Name(),
+	}
+}
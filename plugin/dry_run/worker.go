@@ -0,0 +1,70 @@
+package dry_run
+
+import (
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	bot     *bot.Kabukibot
+	channel string
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || !msg.IsFromOperator() || !msg.IsGlobalCommand("dryrun") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	args := msg.Arguments()
+	usage := "usage: " + msg.Trigger() + msg.Command() + " [all] [on|off]"
+
+	if len(args) == 0 {
+		self.respondStatus(sender)
+		return
+	}
+
+	// "all" toggles the mode for every channel
+	channel := self.channel
+	where := "in " + channel
+
+	if strings.ToLower(args[0]) == "all" {
+		args = args[1:]
+		channel = ""
+		where = "for all channels"
+	}
+
+	if len(args) == 0 {
+		sender.Respond(usage)
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		// respond first, or the response itself would only be logged
+		sender.Respond("dry-run mode is now on " + where + "; messages will only be logged.")
+		self.bot.SetDryRun(channel, true)
+
+	case "off":
+		self.bot.SetDryRun(channel, false)
+		sender.Respond("dry-run mode is now off " + where + ".")
+
+	default:
+		sender.Respond(usage)
+	}
+}
+
+func (self *worker) respondStatus(sender bot.Sender) {
+	if self.bot.DryRun("") {
+		sender.Respond("dry-run mode is on for all channels.")
+	} else if self.bot.DryRun(self.channel) {
+		sender.Respond("dry-run mode is on in " + self.channel + ".")
+	} else {
+		sender.Respond("dry-run mode is off in " + self.channel + ".")
+	}
+}
@@ -22,12 +22,28 @@ func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
 
 func (self *pluginStruct) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsFromOperator() && (msg.IsGlobalCommand("echo") || msg.IsGlobalCommand("say")) {
-		response := strings.Join(msg.Arguments(), " ")
+		response := msg.ArgumentText(0)
 
 		if len(response) == 0 {
 			response = "err... echo?"
 		}
 
-		sender.SendText(response)
+		// "/me !k_echo" echoes an action
+		if msg.IsAction() {
+			sender.SendAction(response)
+		} else {
+			sender.SendText(response)
+		}
+	} else if msg.IsFromOperator() && msg.IsGlobalCommand("whisper") {
+		args := msg.Arguments()
+
+		if len(args) < 2 {
+			sender.Respond("usage: !" + msg.Command() + " <user> <message>")
+			return
+		}
+
+		user := strings.ToLower(strings.TrimPrefix(args[0], "@"))
+
+		sender.SendWhisper(user, msg.ArgumentText(1))
 	}
 }
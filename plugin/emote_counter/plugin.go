@@ -21,6 +21,7 @@ func (self *pluginStruct) Name() string {
 
 func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 	self.db = bot.Database()
+	bot.Commands().Register(self.Name(), commands...)
 }
 
 func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
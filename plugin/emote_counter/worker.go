@@ -13,6 +13,8 @@ import (
 	"github.com/sgt-kabukiman/kabukibot/plugin"
 )
 
+var commands = []string{"top_emotes", "emote_count", "reset_emote_counter"}
+
 type emoteCountMap map[string]int
 
 type worker struct {
@@ -63,6 +65,14 @@ func (self *worker) Permissions() []string {
 	return []string{"use_emote_counter"}
 }
 
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "use_emote_counter"
+}
+
 func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsProcessed() || msg.IsFromBot() {
 		return
@@ -199,12 +209,9 @@ func (self *worker) sync() {
 func (self *worker) countEmotes(msg *bot.TextMessage) {
 	self.mutex.Lock()
 
-	for _, occurences := range msg.User.Emotes {
-		// find the first instance, so we know what emote we have to deal with
-		emote := msg.Text[occurences[0].FirstChar : occurences[0].LastChar+1]
-
-		count, _ := self.stats[emote]
-		self.stats[emote] = count + len(occurences)
+	for _, occurence := range msg.Emotes() {
+		count, _ := self.stats[occurence.Code]
+		self.stats[occurence.Code] = count + 1
 	}
 
 	self.mutex.Unlock()
@@ -0,0 +1,153 @@
+package greeter
+
+import (
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"greet", "session"}
+
+var defaultTemplate = "Welcome to the chat, $(user)!"
+
+// settings are kept in the bot's key/value store
+type settings struct {
+	Enabled  bool   `json:"enabled"`
+	Template string `json:"template"`
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  string
+	acl      *bot.ACL
+	storage  *bot.Storage
+	settings settings
+	chatters map[string]bool // everyone who chatted during this session
+}
+
+func (self *worker) Enable() {
+	self.settings = settings{false, defaultTemplate}
+	self.storage.GetJSON("greeter", self.channel, "settings", &self.settings)
+
+	self.chatters = make(map[string]bool)
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"configure_greeter"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "configure_greeter"
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBot() {
+		return
+	}
+
+	user := strings.ToLower(msg.User.Name)
+
+	// Twitch tells us about users that never chatted here before; everyone
+	// else is greeted once per session.
+	first := msg.Tags.IsFirstMessage() || !self.chatters[user]
+	self.chatters[user] = true
+
+	if !msg.IsProcessed() {
+		switch msg.Command() {
+		case "greet":
+			msg.SetProcessed()
+
+			if self.acl.IsAllowed(msg.User, "configure_greeter") {
+				self.configure(msg, sender)
+			}
+
+			return
+
+		case "session":
+			msg.SetProcessed()
+
+			if self.acl.IsAllowed(msg.User, "configure_greeter") {
+				self.handleSession(msg, sender)
+			}
+
+			return
+		}
+	}
+
+	if first && self.settings.Enabled {
+		sender.SendText(strings.Replace(self.settings.Template, "$(user)", msg.User.Name, -1))
+	}
+}
+
+func (self *worker) configure(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+	s := self.settings
+
+	if len(args) == 0 {
+		status := "disabled"
+		if s.Enabled {
+			status = "enabled"
+		}
+
+		sender.Respond("greetings are " + status + ", the template is: " + s.Template)
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		s.Enabled = true
+
+	case "off":
+		s.Enabled = false
+
+	case "template":
+		template := msg.ArgumentText(1)
+		if len(template) == 0 {
+			sender.Respond("usage: " + msg.Trigger() + "greet template <text>, $(user) is replaced with the chatter's name.")
+			return
+		}
+
+		s.Template = template
+
+	default:
+		sender.Respond("usage: " + msg.Trigger() + "greet [on|off|template <text>]")
+		return
+	}
+
+	err := self.storage.SetJSON("greeter", self.channel, "settings", s)
+	if err != nil {
+		sender.Respond("the settings could not be saved, sorry.")
+		return
+	}
+
+	self.settings = s
+
+	switch strings.ToLower(args[0]) {
+	case "on":
+		sender.Respond("first-time chatters will be greeted from now on.")
+	case "off":
+		sender.Respond("nobody will be greeted anymore.")
+	default:
+		sender.Respond("the greeting has been updated.")
+	}
+}
+
+func (self *worker) handleSession(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 || strings.ToLower(args[0]) != "reset" {
+		sender.Respond("usage: " + msg.Trigger() + "session reset")
+		return
+	}
+
+	// the moderator resetting the session should not be greeted right away
+	self.chatters = map[string]bool{strings.ToLower(msg.User.Name): true}
+
+	sender.Respond("a new session has begun, everyone will be greeted again.")
+}
@@ -0,0 +1,29 @@
+package help
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type pluginStruct struct {
+	plugin.BasePlugin
+
+	settings *bot.Settings
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.settings = bot.Settings()
+	bot.Commands().Register("help", "commands", "help", "plugins", "plugin")
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:  channel,
+		acl:      channel.ACL(),
+		settings: self.settings,
+	}
+}
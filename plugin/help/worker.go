@@ -0,0 +1,201 @@
+package help
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  bot.Channel
+	acl      *bot.ACL
+	settings *bot.Settings
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"inspect_plugins"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	if msg.IsCommand("plugins") {
+		msg.SetProcessed()
+		self.respondPlugins(sender)
+		return
+	}
+
+	if msg.IsCommand("plugin") {
+		msg.SetProcessed()
+		self.respondPlugin(msg, sender)
+		return
+	}
+
+	if !msg.IsCommand("commands") && !msg.IsCommand("help") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	args := msg.Arguments()
+
+	if msg.IsCommand("help") && len(args) > 0 {
+		self.respondHelp(strings.ToLower(strings.TrimPrefix(args[0], msg.Trigger())), msg, sender)
+		return
+	}
+
+	commands := self.availableCommands(msg)
+
+	if len(commands) == 0 {
+		sender.Respond("there are no commands you could use here.")
+		return
+	}
+
+	page := 1
+	if len(args) > 0 {
+		page = bot.PageNumber(args[0])
+	}
+
+	sender.Respond("you can use " + bot.Paginate(commands, page, self.settings.Int(self.channel.Name(), bot.PageSizeSetting)).Join(", ") + ".")
+}
+
+// respondHelp describes a command, unless the user is not allowed to use it or
+// it has been disabled; in that case, the command is treated as if it did not exist.
+func (self *worker) respondHelp(command string, msg *bot.TextMessage, sender bot.Sender) {
+	for _, w := range self.channel.Workers() {
+		for _, c := range w.Commands() {
+			if c != command || self.channel.IsCommandDisabled(c) {
+				continue
+			}
+
+			info := bot.DescribeCommand(w, command)
+
+			if len(info.Permission) > 0 && !self.acl.IsAllowed(msg.User, info.Permission) {
+				continue
+			}
+
+			help := "usage: " + msg.Trigger() + info.Name
+
+			if len(info.Usage) > 0 {
+				help += " " + info.Usage
+			}
+
+			if len(info.Description) > 0 {
+				help += " - " + info.Description
+			}
+
+			sender.Respond(help)
+			return
+		}
+	}
+
+	sender.Respond("there is no command " + msg.Trigger() + command + " you could use.")
+}
+
+// availableCommands collects the commands of all enabled plugins the user is allowed to run
+func (self *worker) availableCommands(msg *bot.TextMessage) []string {
+	seen := make(map[string]bool)
+	result := make([]string, 0)
+
+	for _, w := range self.channel.Workers() {
+		for _, command := range w.Commands() {
+			if seen[command] || self.channel.IsCommandDisabled(command) {
+				continue
+			}
+
+			permission := bot.CommandPermission(w, command)
+
+			if len(permission) == 0 || self.acl.IsAllowed(msg.User, permission) {
+				seen[command] = true
+				result = append(result, msg.Trigger()+command)
+			}
+		}
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// respondPlugins lists the enabled plugins; core plugins without a name are
+// always there and not worth mentioning.
+func (self *worker) respondPlugins(sender bot.Sender) {
+	names := make([]string, 0)
+
+	for _, p := range self.channel.Plugins() {
+		if len(p.Name()) > 0 {
+			names = append(names, p.Name())
+		}
+	}
+
+	if len(names) == 0 {
+		sender.Respond("there are no plugins active in this channel.")
+		return
+	}
+
+	sort.Strings(names)
+
+	sender.Respond("active plugins are: " + bot.HumanJoin(names, ", ") + ".")
+}
+
+// respondPlugin lists the commands of a single plugin the user is allowed to
+// run; users with inspect_plugins also get to see the permissions it brings.
+func (self *worker) respondPlugin(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		sender.Respond("no plugin name given. See " + msg.Trigger() + "plugins for a list of active plugins.")
+		return
+	}
+
+	name := strings.ToLower(args[0])
+
+	w, err := self.channel.WorkerByName(name)
+	if err != nil || len(name) == 0 {
+		sender.Respond("the plugin " + name + " is not active in this channel.")
+		return
+	}
+
+	commands := make([]string, 0)
+
+	for _, command := range w.Commands() {
+		if self.channel.IsCommandDisabled(command) {
+			continue
+		}
+
+		permission := bot.CommandPermission(w, command)
+
+		if len(permission) == 0 || self.acl.IsAllowed(msg.User, permission) {
+			commands = append(commands, msg.Trigger()+command)
+		}
+	}
+
+	sort.Strings(commands)
+
+	response := name + " provides no commands you could use"
+
+	if len(commands) > 0 {
+		response = name + " provides " + bot.HumanJoin(commands, ", ")
+	}
+
+	if self.acl.IsAllowed(msg.User, "inspect_plugins") {
+		permissions := w.Permissions()
+
+		if len(permissions) == 0 {
+			response += "; it has no permissions"
+		} else {
+			sorted := append([]string(nil), permissions...)
+			sort.Strings(sorted)
+
+			response += "; permissions: " + strings.Join(sorted, ", ")
+		}
+	}
+
+	sender.Respond(response + ".")
+}
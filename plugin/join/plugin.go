@@ -94,6 +94,8 @@ func (self *pluginStruct) handlePart(msg *bot.TextMessage, sender bot.Sender) {
 
 	if toLeave == self.home {
 		sender.Respond("I am not leaving my home, sweet home...")
+	} else if len(toLeave) > 1 && !self.bot.Joined(toLeave) {
+		sender.Respond("I am not in " + toLeave + ".")
 	} else if len(toLeave) > 1 {
 		sender.Respond("I am trying to leave " + toLeave + "...")
 		self.bot.Part(toLeave)
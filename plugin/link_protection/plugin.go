@@ -0,0 +1,38 @@
+package link_protection
+
+import (
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	dict *bot.Dictionary
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "link_protection"
+}
+
+// links by users without a permit are removed before anything reacts to them
+func (self *pluginStruct) Priority() int {
+	return bot.ModerationPriority
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.dict = bot.Dictionary()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		dict:    self.dict,
+		now:     time.Now,
+	}
+}
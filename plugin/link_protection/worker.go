@@ -0,0 +1,168 @@
+package link_protection
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"permit", "link_timeout", "link_message"}
+
+const permitDuration = 60 * time.Second
+const defaultMessage = "please ask a moderator before posting links."
+
+var defaultTimeout = 10 * time.Second
+var minTimeout = 1 * time.Second
+var maxTimeout = 14 * 24 * time.Hour
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	dict    *bot.Dictionary
+	timeout time.Duration
+	message string
+	permits map[string]time.Time // user => until when they may post one link
+	now     func() time.Time
+}
+
+func (self *worker) Enable() {
+	self.permits = make(map[string]time.Time)
+	self.timeout = defaultTimeout
+	self.message = defaultMessage
+
+	seconds, err := strconv.Atoi(self.dict.Get(self.key("timeout")))
+	if err == nil && seconds > 0 {
+		self.timeout = time.Duration(seconds) * time.Second
+	}
+
+	if self.dict.Has(self.key("message")) {
+		self.message = self.dict.Get(self.key("message"))
+	}
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"allow_links", "configure_link_protection"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "configure_link_protection"
+}
+
+func (self *worker) key(setting string) string {
+	return "link_protection_" + strings.TrimPrefix(self.channel, "#") + "_" + setting
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	cmd := msg.Command()
+
+	if cmd == "permit" || cmd == "link_timeout" || cmd == "link_message" {
+		msg.SetProcessed()
+
+		if self.acl.IsAllowed(msg.User, "configure_link_protection") {
+			switch cmd {
+			case "permit":
+				self.permit(msg.Arguments(), sender)
+			case "link_timeout":
+				self.setTimeout(msg.Arguments(), sender)
+			case "link_message":
+				self.setMessage(msg.ArgumentText(0), sender)
+			}
+		}
+
+		return
+	}
+
+	self.checkMessage(msg, sender)
+}
+
+func (self *worker) permit(args []string, sender bot.Sender) {
+	if len(args) == 0 {
+		sender.Respond("you have to give a username.")
+		return
+	}
+
+	user := strings.ToLower(strings.TrimPrefix(args[0], "@"))
+	self.permits[user] = self.now().Add(permitDuration)
+
+	sender.Respond(fmt.Sprintf("%s may post one link within the next %s.", user, bot.FormatDuration(permitDuration, true)))
+}
+
+func (self *worker) setTimeout(args []string, sender bot.Sender) {
+	if len(args) == 0 {
+		sender.Respond(fmt.Sprintf("links are currently timed out for %s.", bot.FormatDuration(self.timeout, true)))
+		return
+	}
+
+	parsed := bot.ParseDuration(strings.Join(args, ""), nil, nil)
+	if parsed == nil || *parsed < minTimeout || *parsed > maxTimeout {
+		sender.Respond("invalid timeout given. Expected a value like 10s or 5m.")
+		return
+	}
+
+	self.timeout = time.Duration(parsed.Seconds()) * time.Second
+	self.dict.Set(self.key("timeout"), strconv.Itoa(int(self.timeout.Seconds())))
+
+	sender.Respond(fmt.Sprintf("links will be timed out for %s.", bot.FormatDuration(self.timeout, true)))
+}
+
+func (self *worker) setMessage(message string, sender bot.Sender) {
+	self.message = message
+	self.dict.Set(self.key("message"), self.message)
+
+	if len(self.message) == 0 {
+		sender.Respond("users will no longer be warned when they post a link.")
+	} else {
+		sender.Respond("the warning message has been updated.")
+	}
+}
+
+func (self *worker) checkMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBroadcaster() || msg.IsFromOperator() {
+		return
+	}
+
+	t := msg.User.Type
+
+	if t == twitch.Moderator || t == twitch.GlobalModerator || t == twitch.TwitchStaff || t == twitch.TwitchAdmin {
+		return
+	}
+
+	if !bot.ContainsURL(msg.Normalized()) || self.acl.IsAllowed(msg.User, "allow_links") {
+		return
+	}
+
+	user := strings.ToLower(msg.User.Name)
+
+	// a permit is good for exactly one message
+	until, permitted := self.permits[user]
+	if permitted {
+		delete(self.permits, user)
+
+		if self.now().Before(until) {
+			return
+		}
+	}
+
+	sender.Timeout(user, int(self.timeout.Seconds()), "")
+
+	if len(self.message) > 0 {
+		sender.Respond(self.message)
+	}
+
+	msg.StopPropagation()
+}
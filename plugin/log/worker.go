@@ -85,6 +85,14 @@ func (self *worker) HandleSubscriberNotificationMessage(msg *twitch.SubscriberNo
 	}
 }
 
+func (self *worker) HandleRaidMessage(msg *twitch.RaidMessage, sender bot.Sender) {
+	if self.file != nil {
+		now := time.Now().Format("2006-Jan-02 15:04:05")
+
+		self.file.WriteString(fmt.Sprintf("[%s] <%s is raiding with %d viewers>\n", now, msg.Raider, msg.Viewers))
+	}
+}
+
 func (self *worker) userPrefix(msg *bot.TextMessage) string {
 	prefix := ""
 	user := msg.User
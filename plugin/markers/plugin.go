@@ -0,0 +1,47 @@
+package markers
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+type pluginStruct struct {
+	db  *sqlx.DB
+	api *twitch.APIClient
+	log bot.Logger
+	now func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "markers"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	self.api = bot.TwitchAPI()
+	self.log = bot.Logger()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		db:      self.db,
+		api:     self.api,
+		log:     self.log,
+		now:     self.now,
+	}
+}
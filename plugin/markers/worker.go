@@ -0,0 +1,140 @@
+package markers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"marker", "markers"}
+
+// how many markers !markers shows
+const recentMarkers = 5
+
+type marker struct {
+	ID        int    `db:"id"`
+	Label     string `db:"label"`
+	CreatedAt int64  `db:"created_at"`
+	Offset    int    `db:"stream_offset"` // in seconds, -1 if the stream was offline
+}
+
+// Editors care about where in the VOD a marker is; if there is no stream,
+// the wall clock is all we have.
+func (self marker) String() string {
+	if self.Offset < 0 {
+		return time.Unix(self.CreatedAt, 0).UTC().Format("2006-01-02 15:04 UTC") + " (offline)"
+	}
+
+	return formatOffset(self.Offset)
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	db      *sqlx.DB
+	api     *twitch.APIClient
+	log     bot.Logger
+	now     func() time.Time
+	mutex   sync.Mutex // markers are added in the background
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"add_markers"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	switch msg.Command() {
+	case "marker":
+		msg.SetProcessed()
+
+		if !self.acl.IsAllowed(msg.User, "add_markers") {
+			return
+		}
+
+		if !msg.RequireArgs(1, "<label>", sender) {
+			return
+		}
+
+		label := msg.ArgumentText(0)
+		user := strings.ToLower(msg.User.Name)
+
+		// do not block the channel while waiting for Twitch
+		go self.addMarker(label, user, self.now(), sender)
+
+	case "markers":
+		msg.SetProcessed()
+		self.respondMarkers(sender)
+	}
+}
+
+func (self *worker) addMarker(label string, user string, now time.Time, sender bot.Sender) {
+	offset := -1
+
+	uptime, err := self.api.StreamUptime(self.channel)
+
+	switch err {
+	case nil:
+		offset = int(now.Sub(uptime.StartedAt) / time.Second)
+	case twitch.ErrStreamOffline:
+		// nothing to measure against
+	default:
+		self.log.Error("Could not query the Twitch API: %s", err.Error())
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	id := 0
+	self.db.Get(&id, "SELECT COALESCE(MAX(id), 0) FROM stream_markers WHERE channel = ?", self.channel)
+	id++
+
+	_, err = self.db.Exec("INSERT INTO stream_markers (channel, id, label, added_by, created_at, stream_offset) VALUES (?, ?, ?, ?, ?, ?)", self.channel, id, label, user, now.Unix(), offset)
+	if err != nil {
+		self.log.Error("Could not store stream marker: %s", err.Error())
+		sender.Respond("could not store the marker, please try again later.")
+		return
+	}
+
+	m := marker{id, label, now.Unix(), offset}
+
+	sender.Respond(fmt.Sprintf("marker #%d has been set at %s.", id, m))
+}
+
+func (self *worker) respondMarkers(sender bot.Sender) {
+	list := make([]marker, 0)
+	self.db.Select(&list, "SELECT id, label, created_at, stream_offset FROM stream_markers WHERE channel = ? ORDER BY id DESC LIMIT ?", self.channel, recentMarkers)
+
+	if len(list) == 0 {
+		sender.Respond("there are no markers yet.")
+		return
+	}
+
+	// oldest first, like on the VOD's timeline
+	parts := make([]string, len(list))
+
+	for idx, m := range list {
+		parts[len(list)-1-idx] = fmt.Sprintf("#%d %s: %s", m.ID, m, m.Label)
+	}
+
+	sender.SendText("Recent markers: " + strings.Join(parts, " | "))
+}
+
+func formatOffset(seconds int) string {
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
@@ -0,0 +1,43 @@
+package mod_log
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	db  *sqlx.DB
+	log bot.Logger
+	now func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "mod_log"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	self.log = bot.Logger()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		db:      self.db,
+		log:     self.log,
+		now:     self.now,
+	}
+}
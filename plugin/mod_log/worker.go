@@ -0,0 +1,146 @@
+package mod_log
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"modlog"}
+
+// how many actions !modlog shows
+const recentActions = 5
+
+const (
+	actionTimeout = "timeout"
+	actionBan     = "ban"
+	actionDelete  = "delete"
+	actionClear   = "clear"
+)
+
+type entry struct {
+	ID        int    `db:"id"`
+	Action    string `db:"action"`
+	Duration  int    `db:"duration"`
+	Moderator string `db:"moderator"`
+	Message   string `db:"message"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+func (self entry) String() string {
+	what := ""
+
+	switch self.Action {
+	case actionTimeout:
+		what = "timed out for " + bot.FormatDuration(time.Duration(self.Duration)*time.Second, false)
+	case actionBan:
+		what = "banned"
+	case actionDelete:
+		what = fmt.Sprintf(`message "%s" deleted`, self.Message)
+	default:
+		what = self.Action
+	}
+
+	// Twitch does not tell us who pressed the button
+	moderator := self.Moderator
+	if moderator == "" {
+		moderator = "unknown mod"
+	}
+
+	when := time.Unix(self.CreatedAt, 0).UTC().Format("2006-01-02 15:04 UTC")
+
+	return fmt.Sprintf("%s (%s, by %s)", what, when, moderator)
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	db      *sqlx.DB
+	log     bot.Logger
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"view_mod_log"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleClearChatMessage(msg *twitch.ClearChatMessage, sender bot.Sender) {
+	switch {
+	case msg.IsTimeout():
+		self.record(msg.User, actionTimeout, msg.Duration, "")
+	case msg.IsBan():
+		self.record(msg.User, actionBan, 0, "")
+	default:
+		self.record("", actionClear, 0, "")
+	}
+}
+
+func (self *worker) HandleClearMsgMessage(msg *twitch.ClearMsgMessage, sender bot.Sender) {
+	self.record(msg.User, actionDelete, 0, msg.Text)
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() || msg.Command() != "modlog" {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "view_mod_log") {
+		return
+	}
+
+	if !msg.RequireArgs(1, "<user>", sender) {
+		return
+	}
+
+	self.respondLog(strings.ToLower(strings.TrimPrefix(msg.Arguments()[0], "@")), sender)
+}
+
+func (self *worker) record(user string, action string, duration int, message string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	id := 0
+	self.db.Get(&id, "SELECT COALESCE(MAX(id), 0) FROM mod_log WHERE channel = ?", self.channel)
+	id++
+
+	_, err := self.db.Exec(
+		"INSERT INTO mod_log (channel, id, username, action, duration, moderator, message, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		self.channel, id, strings.ToLower(user), action, duration, "", message, self.now().Unix(),
+	)
+
+	if err != nil {
+		self.log.Error("Could not log moderation action: %s", err.Error())
+	}
+}
+
+func (self *worker) respondLog(user string, sender bot.Sender) {
+	list := make([]entry, 0)
+	self.db.Select(&list, "SELECT id, action, duration, moderator, message, created_at FROM mod_log WHERE channel = ? AND username = ? ORDER BY id DESC LIMIT ?", self.channel, user, recentActions)
+
+	if len(list) == 0 {
+		sender.Respond("there are no moderation actions against " + user + " on record.")
+		return
+	}
+
+	parts := make([]string, len(list))
+	for idx, e := range list {
+		parts[idx] = e.String()
+	}
+
+	sender.Respond("recent actions against " + user + ": " + strings.Join(parts, " | "))
+}
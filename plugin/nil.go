@@ -21,14 +21,24 @@ func (nw *NilWorker) Disable() {
 	// do nothing
 }
 
+func (nw *NilWorker) Reload() {
+	// do nothing
+}
+
+// Part and Shutdown are called after the worker has already been disabled.
+
 func (nw *NilWorker) Part() {
-	nw.Disable()
+	// do nothing
 }
 
 func (nw *NilWorker) Shutdown() {
-	nw.Disable()
+	// do nothing
 }
 
 func (nw *NilWorker) Permissions() []string {
 	return []string{}
 }
+
+func (nw *NilWorker) Commands() []string {
+	return []string{}
+}
@@ -0,0 +1,54 @@
+package nuke
+
+import "time"
+
+type recentMessage struct {
+	user   string
+	text   string // lowercased
+	sentAt time.Time
+	exempt bool // moderators and the like cannot be timed out
+}
+
+// messageBuffer is a ring buffer of the most recent chat messages.
+type messageBuffer struct {
+	messages []recentMessage
+	next     int
+	full     bool
+}
+
+func newMessageBuffer(size int) *messageBuffer {
+	return &messageBuffer{messages: make([]recentMessage, size)}
+}
+
+func (self *messageBuffer) size() int {
+	return len(self.messages)
+}
+
+func (self *messageBuffer) add(msg recentMessage) {
+	self.messages[self.next] = msg
+	self.next = (self.next + 1) % len(self.messages)
+
+	if self.next == 0 {
+		self.full = true
+	}
+}
+
+// since returns all messages sent at or after the given time, oldest first.
+func (self *messageBuffer) since(t time.Time) []recentMessage {
+	result := make([]recentMessage, 0)
+
+	start, count := 0, self.next
+	if self.full {
+		start, count = self.next, len(self.messages)
+	}
+
+	for i := 0; i < count; i++ {
+		msg := self.messages[(start+i)%len(self.messages)]
+
+		if !msg.sentAt.Before(t) {
+			result = append(result, msg)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,81 @@
+package nuke
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type nukeConfig struct {
+	BufferSize int `yaml:"bufferSize"` // messages remembered per channel
+	Window     int // in seconds, older messages are not nuked
+	Timeout    int // in seconds, unless a duration is given
+}
+
+type pluginStruct struct {
+	config nukeConfig
+	mutex  sync.RWMutex
+	log    bot.Logger
+	now    func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "nuke"
+}
+
+// nuked phrases are timed out before a command can respond to them
+func (self *pluginStruct) Priority() int {
+	return bot.ModerationPriority
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.log = bot.Logger()
+	self.Reconfigure(bot.Configuration())
+	bot.Commands().Register(self.Name(), "nuke")
+}
+
+func (self *pluginStruct) Reconfigure(config *bot.Configuration) {
+	loaded := nukeConfig{
+		BufferSize: 200,
+		Window:     60,
+		Timeout:    600,
+	}
+
+	err := config.PluginConfig("nuke", &loaded)
+	if err != nil {
+		self.log.Warning("Could not load 'nuke' plugin configuration: %s", err)
+	}
+
+	if loaded.BufferSize < 1 {
+		loaded.BufferSize = 1
+	}
+
+	self.mutex.Lock()
+	self.config = loaded
+	self.mutex.Unlock()
+}
+
+func (self *pluginStruct) settings() nukeConfig {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	return self.config
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		acl:    channel.ACL(),
+		plugin: self,
+		now:    self.now,
+	}
+}
@@ -0,0 +1,127 @@
+package nuke
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+// Twitch does not allow longer timeouts
+var maxTimeout = 14 * 24 * time.Hour
+
+type worker struct {
+	plugin.NilWorker
+
+	acl    *bot.ACL
+	plugin *pluginStruct
+	now    func() time.Time
+	buffer *messageBuffer
+}
+
+func (self *worker) Enable() {
+	self.buffer = newMessageBuffer(self.plugin.settings().BufferSize)
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"nuke"}
+}
+
+func (self *worker) Commands() []string {
+	return []string{"nuke"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBot() {
+		return
+	}
+
+	if msg.Command() == "" {
+		self.remember(msg)
+		return
+	}
+
+	if msg.IsProcessed() || msg.Command() != "nuke" {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "nuke") {
+		return
+	}
+
+	self.nuke(msg, sender)
+}
+
+func (self *worker) remember(msg *bot.TextMessage) {
+	config := self.plugin.settings()
+
+	// the buffer starts over when its size has been reconfigured
+	if self.buffer.size() != config.BufferSize {
+		self.buffer = newMessageBuffer(config.BufferSize)
+	}
+
+	t := msg.User.Type
+
+	self.buffer.add(recentMessage{
+		user:   strings.ToLower(msg.User.Name),
+		text:   strings.ToLower(msg.Normalized()),
+		sentAt: self.now(),
+		exempt: msg.IsFromBroadcaster() || msg.IsFromOperator() || t == twitch.Moderator || t == twitch.GlobalModerator || t == twitch.TwitchStaff || t == twitch.TwitchAdmin,
+	})
+}
+
+func (self *worker) nuke(msg *bot.TextMessage, sender bot.Sender) {
+	config := self.plugin.settings()
+	args := msg.Arguments()
+	phrase := strings.ToLower(msg.ArgumentText(0))
+	timeout := time.Duration(config.Timeout) * time.Second
+
+	// a trailing duration is not part of the phrase
+	if len(args) > 1 {
+		last := args[len(args)-1]
+		parsed := bot.ParseDuration(last, nil, nil)
+
+		if parsed != nil && *parsed >= time.Second {
+			timeout = *parsed
+			phrase = strings.TrimSpace(strings.TrimSuffix(phrase, strings.ToLower(last)))
+		}
+	}
+
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+
+	if len(phrase) == 0 {
+		sender.Respond("usage: " + msg.Trigger() + "nuke <phrase> [duration]")
+		return
+	}
+
+	window := time.Duration(config.Window) * time.Second
+	seen := make(map[string]bool)
+	victims := make([]string, 0)
+
+	for _, recent := range self.buffer.since(self.now().Add(-window)) {
+		if recent.exempt || seen[recent.user] || !strings.Contains(recent.text, phrase) {
+			continue
+		}
+
+		seen[recent.user] = true
+		victims = append(victims, recent.user)
+	}
+
+	if len(victims) == 0 {
+		sender.Respond(fmt.Sprintf("nobody said that within the last %s.", bot.FormatDuration(window, true)))
+		return
+	}
+
+	for _, victim := range victims {
+		sender.Timeout(victim, int(timeout.Seconds()), "")
+	}
+
+	sender.Respond(fmt.Sprintf("timed out %s for %s.", bot.HumanJoin(victims, ", "), bot.FormatDuration(timeout, true)))
+}
@@ -23,3 +23,8 @@ func (self *pluginStruct) HandleTextMessage(msg *bot.TextMessage, sender bot.Sen
 		sender.SendText("Pong!")
 	}
 }
+
+// the operator can check on the bot without anyone noticing
+func (self *pluginStruct) HandleWhisper(msg *bot.TextMessage, sender bot.Sender) {
+	self.HandleTextMessage(msg, sender)
+}
@@ -17,18 +17,32 @@ type worker struct {
 	plugins []bot.Plugin
 }
 
+func (self *worker) Permissions() []string {
+	return []string{"toggle_plugins", "toggle_commands"}
+}
+
 func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsProcessed() {
 		return
 	}
 
+	if msg.IsGlobalCommand("command") {
+		msg.SetProcessed()
+
+		if self.channel.ACL().IsAllowed(msg.User, "toggle_commands") {
+			self.handleCommandToggle(msg, sender)
+		}
+
+		return
+	}
+
 	// skip unwanted commands
-	if !msg.IsGlobalCommand("enable") && !msg.IsGlobalCommand("disable") && !msg.IsGlobalCommand("plugins") {
+	if !msg.IsGlobalCommand("enable") && !msg.IsGlobalCommand("disable") && !msg.IsGlobalCommand("reload") && !msg.IsGlobalCommand("plugins") {
 		return
 	}
 
-	// our commands are all priv-only
-	if !msg.IsFromBroadcaster() && !msg.IsFromOperator() {
+	// the broadcaster and operator are always allowed by the ACL
+	if !self.channel.ACL().IsAllowed(msg.User, "toggle_plugins") {
 		return
 	}
 
@@ -42,7 +56,7 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 
 	// everything from now on requires at last a plugin key as the first parameter
 	if len(args) == 0 {
-		sender.Respond("no plugin name given. See !" + self.prefix + "plugins for a list of available plugins.")
+		sender.Respond("no plugin name given. See " + msg.Trigger() + self.prefix + "plugins for a list of available plugins.")
 		return
 	}
 
@@ -70,8 +84,14 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 
 	message := ""
 
-	// enable a plugin
-	if msg.IsGlobalCommand("enable") {
+	// make a plugin re-read its data, e.g. after the database has been edited
+	if msg.IsGlobalCommand("reload") {
+		if self.channel.ReloadPlugin(pluginKey) {
+			message = "the plugin " + pluginKey + " has been reloaded."
+		} else {
+			message = "the plugin " + pluginKey + " is not enabled in this channel."
+		}
+	} else if msg.IsGlobalCommand("enable") { // enable a plugin
 		if self.channel.EnablePlugin(pluginKey) {
 			message = "the plugin " + pluginKey + " has been enabled."
 		} else {
@@ -107,6 +127,8 @@ func (self *worker) respondToListCommand(args []string, sender bot.Sender) {
 		}
 	}
 
+	sort.Strings(nameList)
+
 	var prefix string
 	if enabledOnly {
 		prefix = "enabled"
@@ -117,7 +139,7 @@ func (self *worker) respondToListCommand(args []string, sender bot.Sender) {
 	if len(nameList) == 0 {
 		sender.Respond("there are no " + prefix + " plugins.")
 	} else {
-		sender.Respond(prefix + " plugins are: " + strings.Join(nameList, ", "))
+		sender.Respond(prefix + " plugins are: " + bot.HumanJoin(nameList, ", "))
 	}
 }
 
@@ -160,6 +182,61 @@ func (self *worker) pluginStates() map[string]bool {
 	return result
 }
 
+// handleCommandToggle turns single commands of otherwise enabled plugins off and
+// on again. Unlike denying them via the ACL, disabled commands are hidden from
+// everyone, as if no plugin provided them.
+func (self *worker) handleCommandToggle(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		disabled := self.channel.DisabledCommands()
+
+		if len(disabled) == 0 {
+			sender.Respond("no commands are disabled in this channel.")
+			return
+		}
+
+		for idx, command := range disabled {
+			disabled[idx] = msg.Trigger() + command
+		}
+
+		sender.Respond("disabled commands in this channel: " + bot.HumanJoin(disabled, ", ") + ".")
+		return
+	}
+
+	action := strings.ToLower(args[0])
+
+	if len(args) < 2 || (action != "enable" && action != "disable") {
+		sender.Respond("usage: " + msg.Trigger() + self.prefix + "command [enable|disable <command>]")
+		return
+	}
+
+	command := strings.ToLower(strings.TrimPrefix(args[1], msg.Trigger()))
+	mention := msg.Trigger() + command
+
+	if action == "enable" {
+		if self.channel.EnableCommand(command) {
+			sender.Respond(mention + " has been enabled again.")
+		} else {
+			sender.Respond(mention + " is not disabled in this channel.")
+		}
+
+		return
+	}
+
+	// only plugin commands can be disabled, so nobody locks themselves out of the bot's own commands
+	if _, exists := self.bot.Commands().Owner(command); !exists {
+		sender.Respond(mention + " is not a plugin command.")
+		return
+	}
+
+	if self.channel.DisableCommand(command) {
+		sender.Respond(mention + " has been disabled.")
+	} else {
+		sender.Respond(mention + " is already disabled in this channel.")
+	}
+}
+
 func isOpOnlyPlugin(name string) bool {
 	return strings.ToUpper(name) == name
 }
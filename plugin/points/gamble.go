@@ -0,0 +1,99 @@
+package points
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+var maxSides = 1000
+
+var errInsufficientPoints = errors.New("The user does not have enough points.")
+
+func (self *worker) gamble(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		sender.Respond("usage: " + msg.Trigger() + "gamble <amount>")
+		return
+	}
+
+	amount, err := strconv.Atoi(args[0])
+	if err != nil || amount <= 0 {
+		sender.Respond("the amount must be a number > 0.")
+		return
+	}
+
+	user := strings.ToLower(msg.User.Name)
+
+	self.mutex.Lock()
+	won := self.intn(100) < self.settings.Odds
+
+	balance, err := self.transact(user, func(balance int) (int, error) {
+		if amount > balance {
+			return balance, errInsufficientPoints
+		}
+
+		if won {
+			return balance + amount, nil
+		}
+
+		return balance - amount, nil
+	})
+
+	self.mutex.Unlock()
+
+	switch {
+	case err == errInsufficientPoints:
+		sender.Respond("you only have " + pointsText(self.balance(self.db, user)) + ".")
+	case err != nil:
+		sender.Respond("the points could not be updated, sorry.")
+	case won:
+		sender.Respond(fmt.Sprintf("you won %s and now have %s.", pointsText(amount), pointsText(balance)))
+	default:
+		sender.Respond(fmt.Sprintf("you lost %s and now have %s.", pointsText(amount), pointsText(balance)))
+	}
+}
+
+func (self *worker) roll(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+	sides := 6
+
+	if len(args) > 0 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed < 2 || parsed > maxSides {
+			sender.Respond(fmt.Sprintf("the number of sides must be between 2 and %d.", maxSides))
+			return
+		}
+
+		sides = parsed
+	}
+
+	sender.Respond(fmt.Sprintf("you rolled a %d.", self.intn(sides)+1))
+}
+
+func (self *worker) configureOdds(args []string, sender bot.Sender) {
+	self.mutex.Lock()
+	s := self.settings
+	self.mutex.Unlock()
+
+	if len(args) == 0 {
+		sender.Respond(fmt.Sprintf("the chance to win a gamble is %d%%.", s.Odds))
+		return
+	}
+
+	odds, err := strconv.Atoi(strings.TrimSuffix(args[0], "%"))
+	if err != nil || odds < 0 || odds > 100 {
+		sender.Respond("the odds must be a percentage between 0 and 100.")
+		return
+	}
+
+	s.Odds = odds
+
+	if self.saveSettings(s, sender) {
+		sender.Respond(fmt.Sprintf("the chance to win a gamble is now %d%%.", odds))
+	}
+}
@@ -0,0 +1,356 @@
+package points
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"points", "gamble", "roll"}
+
+var minDuration = 1 * time.Minute
+var maxDuration = 24 * time.Hour
+
+// settings are kept in the bot's key/value store
+type settings struct {
+	Interval int `json:"interval"` // seconds between two awards
+	Amount   int `json:"amount"`   // points per award
+	Window   int `json:"window"`   // seconds a chatter counts as active
+	Odds     int `json:"odds"`     // chance to win a gamble, in percent
+}
+
+var defaultSettings = settings{600, 10, 900, 50}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel     string
+	acl         *bot.ACL
+	db          *sqlx.DB
+	storage     *bot.Storage
+	settings    settings
+	lastSeen    map[string]time.Time // when each chatter wrote their last message
+	lastAward   time.Time
+	mutex       sync.Mutex
+	ticking     chan struct{}
+	stopTicking chan struct{}
+	now         func() time.Time
+	intn        func(int) int // decides gambles and dice rolls
+	resolution  time.Duration // how often to check for due awards
+}
+
+func (self *worker) Enable() {
+	s := defaultSettings
+
+	err := self.storage.GetJSON("points", self.channel, "settings", &s)
+	if err != nil {
+		s = defaultSettings
+	}
+
+	self.mutex.Lock()
+	self.settings = s
+	self.lastSeen = make(map[string]time.Time)
+	self.lastAward = self.now()
+	self.mutex.Unlock()
+
+	self.ticking = make(chan struct{})
+	self.stopTicking = make(chan struct{})
+
+	go self.ticker()
+}
+
+func (self *worker) Disable() {
+	close(self.stopTicking)
+	<-self.ticking
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"manage_points"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBot() {
+		return
+	}
+
+	// catch up before counting this message, so it does not count for past awards
+	self.mutex.Lock()
+	self.award(self.now())
+	self.lastSeen[strings.ToLower(msg.User.Name)] = self.now()
+	self.mutex.Unlock()
+
+	if msg.IsProcessed() {
+		return
+	}
+
+	switch msg.Command() {
+	case "points":
+		msg.SetProcessed()
+		self.handlePoints(msg, sender)
+
+	case "gamble":
+		msg.SetProcessed()
+		self.gamble(msg, sender)
+
+	case "roll":
+		msg.SetProcessed()
+		self.roll(msg, sender)
+	}
+}
+
+func (self *worker) handlePoints(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		self.showBalance(msg.User.Name, true, sender)
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add", "remove":
+		if self.acl.IsAllowed(msg.User, "manage_points") {
+			self.changeBalance(args, msg, sender)
+		}
+
+	case "config":
+		if self.acl.IsAllowed(msg.User, "manage_points") {
+			self.configure(args[1:], msg, sender)
+		}
+
+	case "odds":
+		if self.acl.IsAllowed(msg.User, "manage_points") {
+			self.configureOdds(args[1:], sender)
+		}
+
+	default:
+		self.showBalance(args[0], false, sender)
+	}
+}
+
+func (self *worker) showBalance(user string, own bool, sender bot.Sender) {
+	user = strings.ToLower(strings.TrimPrefix(user, "@"))
+	balance := self.balance(self.db, user)
+
+	if own {
+		sender.Respond("you have " + pointsText(balance) + ".")
+	} else {
+		sender.Respond(user + " has " + pointsText(balance) + ".")
+	}
+}
+
+func (self *worker) changeBalance(args []string, msg *bot.TextMessage, sender bot.Sender) {
+	action := strings.ToLower(args[0])
+
+	if len(args) < 3 {
+		sender.Respond("usage: " + msg.Trigger() + "points " + action + " <user> <amount>")
+		return
+	}
+
+	amount, err := strconv.Atoi(args[2])
+	if err != nil || amount <= 0 {
+		sender.Respond("the amount must be a number > 0.")
+		return
+	}
+
+	if action == "remove" {
+		amount = -amount
+	}
+
+	user := strings.ToLower(strings.TrimPrefix(args[1], "@"))
+
+	self.mutex.Lock()
+	balance, err := self.adjust(user, amount)
+	self.mutex.Unlock()
+
+	if err != nil {
+		sender.Respond("the points could not be updated, sorry.")
+		return
+	}
+
+	sender.Respond(user + " now has " + pointsText(balance) + ".")
+}
+
+func (self *worker) configure(args []string, msg *bot.TextMessage, sender bot.Sender) {
+	self.mutex.Lock()
+	s := self.settings
+	self.mutex.Unlock()
+
+	if len(args) == 0 {
+		sender.Respond(fmt.Sprintf(
+			"every %s, everyone who chatted within the last %s gets %s.",
+			bot.FormatDuration(time.Duration(s.Interval)*time.Second, false),
+			bot.FormatDuration(time.Duration(s.Window)*time.Second, false),
+			pointsText(s.Amount),
+		))
+
+		return
+	}
+
+	if len(args) < 2 {
+		sender.Respond("usage: " + msg.Trigger() + "points config <interval> <amount> [<activity window>], e.g. " + msg.Trigger() + "points config 10m 5 15m")
+		return
+	}
+
+	interval := bot.ParseDuration(args[0], nil, nil)
+	if !validDuration(interval) {
+		sender.Respond(fmt.Sprintf("invalid interval given. Expected a value between %s and %s, like 10m.", bot.FormatDuration(minDuration, false), bot.FormatDuration(maxDuration, false)))
+		return
+	}
+
+	amount, err := strconv.Atoi(args[1])
+	if err != nil || amount <= 0 {
+		sender.Respond("the amount must be a number > 0.")
+		return
+	}
+
+	s.Interval = int(interval.Seconds())
+	s.Amount = amount
+
+	if len(args) > 2 {
+		window := bot.ParseDuration(args[2], nil, nil)
+		if !validDuration(window) {
+			sender.Respond(fmt.Sprintf("invalid activity window given. Expected a value between %s and %s, like 15m.", bot.FormatDuration(minDuration, false), bot.FormatDuration(maxDuration, false)))
+			return
+		}
+
+		s.Window = int(window.Seconds())
+	}
+
+	if self.saveSettings(s, sender) {
+		// start counting anew, so that a shorter interval does not hand out a pile of points at once
+		self.mutex.Lock()
+		self.lastAward = self.now()
+		self.mutex.Unlock()
+
+		sender.Respond("the settings have been updated.")
+	}
+}
+
+func (self *worker) saveSettings(s settings, sender bot.Sender) bool {
+	err := self.storage.SetJSON("points", self.channel, "settings", s)
+	if err != nil {
+		sender.Respond("the settings could not be saved, sorry.")
+		return false
+	}
+
+	self.mutex.Lock()
+	self.settings = s
+	self.mutex.Unlock()
+
+	return true
+}
+
+func (self *worker) ticker() {
+	defer close(self.ticking)
+
+	ticker := time.NewTicker(self.resolution)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			self.mutex.Lock()
+			self.award(self.now())
+			self.mutex.Unlock()
+
+		case <-self.stopTicking:
+			return
+		}
+	}
+}
+
+// award hands out the points for every interval that has passed up to the
+// given time; the caller must hold the mutex.
+func (self *worker) award(now time.Time) {
+	interval := time.Duration(self.settings.Interval) * time.Second
+	window := time.Duration(self.settings.Window) * time.Second
+
+	for !self.lastAward.Add(interval).After(now) {
+		self.lastAward = self.lastAward.Add(interval)
+
+		for user, seen := range self.lastSeen {
+			if self.lastAward.Sub(seen) <= window {
+				self.adjust(user, self.settings.Amount)
+			}
+		}
+	}
+
+	// forget about chatters that have been gone for too long
+	for user, seen := range self.lastSeen {
+		if self.lastAward.Sub(seen) > window {
+			delete(self.lastSeen, user)
+		}
+	}
+}
+
+func (self *worker) balance(q sqlx.Queryer, user string) int {
+	balance := 0
+	sqlx.Get(q, &balance, "SELECT balance FROM points WHERE channel = ? AND username = ?", self.channel, user)
+
+	return balance
+}
+
+// adjust changes the user's balance, which never goes below zero, and
+// returns the new balance; the caller must hold the mutex.
+func (self *worker) adjust(user string, delta int) (int, error) {
+	return self.transact(user, func(balance int) (int, error) {
+		balance += delta
+		if balance < 0 {
+			balance = 0
+		}
+
+		return balance, nil
+	})
+}
+
+// transact reads the balance, lets change() decide on the new one and writes
+// it, all in one transaction. If change() fails, the balance is left alone.
+func (self *worker) transact(user string, change func(int) (int, error)) (int, error) {
+	tx, err := self.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	balance, err := change(self.balance(tx, user))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.Exec("DELETE FROM points WHERE channel = ? AND username = ?", self.channel, user)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.Exec("INSERT INTO points (channel, username, balance) VALUES (?, ?, ?)", self.channel, user, balance)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return balance, tx.Commit()
+}
+
+func validDuration(d *time.Duration) bool {
+	return d != nil && *d >= minDuration && *d <= maxDuration
+}
+
+func pointsText(amount int) string {
+	if amount == 1 {
+		return "1 point"
+	}
+
+	return humanize.FormatInteger("#,###.", amount) + " points"
+}
@@ -0,0 +1,28 @@
+package poll
+
+import "github.com/sgt-kabukiman/kabukibot/bot"
+
+type pluginStruct struct {
+	storage *bot.Storage
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "poll"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.storage = bot.Storage()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		acl:     channel.ACL(),
+		storage: self.storage,
+	}
+}
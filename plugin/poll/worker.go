@@ -0,0 +1,240 @@
+package poll
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"poll", "vote"}
+
+// polls only live in memory; a restart ends them
+type poll struct {
+	question string
+	options  []string
+	votes    map[string]int // user => option index
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	acl     *bot.ACL
+	storage *bot.Storage
+	current *poll
+}
+
+func (self *worker) Enable() {
+	self.current = nil
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"manage_polls"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	switch msg.Command() {
+	case "poll":
+		msg.SetProcessed()
+		self.handlePoll(msg, sender)
+
+	case "vote":
+		msg.SetProcessed()
+		self.vote(msg, sender)
+	}
+}
+
+func (self *worker) handlePoll(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.QuotedArguments()
+
+	if len(args) == 0 {
+		self.showPoll(msg, sender)
+		return
+	}
+
+	if !self.acl.IsAllowed(msg.User, "manage_polls") {
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "end":
+		self.endPoll(sender)
+
+	case "mode":
+		self.configureMode(args[1:], sender)
+
+	default:
+		self.openPoll(args, msg, sender)
+	}
+}
+
+func (self *worker) showPoll(msg *bot.TextMessage, sender bot.Sender) {
+	if self.current == nil {
+		sender.Respond("there is no poll running.")
+		return
+	}
+
+	sender.SendText(self.announcement(msg.Trigger()))
+}
+
+func (self *worker) openPoll(args []string, msg *bot.TextMessage, sender bot.Sender) {
+	if self.current != nil {
+		sender.Respond("there is already a poll running, end it with " + msg.Trigger() + "poll end first.")
+		return
+	}
+
+	question := strings.TrimSpace(args[0])
+	options := make([]string, 0)
+
+	for _, option := range strings.Split(strings.Join(args[1:], " "), "|") {
+		option = strings.TrimSpace(option)
+
+		if len(option) > 0 {
+			options = append(options, option)
+		}
+	}
+
+	if len(question) == 0 || len(options) < 2 {
+		sender.Respond("usage: " + msg.Trigger() + "poll \"<question>\" <option> | <option> [| <option> ...]")
+		return
+	}
+
+	self.current = &poll{question, options, make(map[string]int)}
+	sender.SendText(self.announcement(msg.Trigger()))
+}
+
+func (self *worker) announcement(trigger string) string {
+	options := make([]string, len(self.current.options))
+
+	for idx, option := range self.current.options {
+		options[idx] = fmt.Sprintf("%d) %s", idx+1, option)
+	}
+
+	return fmt.Sprintf("Poll: %s %s -- vote with %svote <number>!", self.current.question, strings.Join(options, ", "), trigger)
+}
+
+func (self *worker) vote(msg *bot.TextMessage, sender bot.Sender) {
+	if self.current == nil {
+		sender.Respond("there is no poll running.")
+		return
+	}
+
+	args := msg.Arguments()
+	count := len(self.current.options)
+
+	number := 0
+	if len(args) > 0 {
+		number, _ = strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	}
+
+	if number < 1 || number > count {
+		sender.Respond(fmt.Sprintf("please vote with a number between 1 and %d.", count))
+		return
+	}
+
+	user := strings.ToLower(msg.User.Name)
+
+	_, voted := self.current.votes[user]
+	if voted && self.mode() == "first" {
+		return
+	}
+
+	// votes are counted silently, so a poll does not flood the chat
+	self.current.votes[user] = number - 1
+}
+
+func (self *worker) endPoll(sender bot.Sender) {
+	if self.current == nil {
+		sender.Respond("there is no poll running.")
+		return
+	}
+
+	p := self.current
+	self.current = nil
+
+	tally := make([]int, len(p.options))
+	for _, option := range p.votes {
+		tally[option]++
+	}
+
+	total := len(p.votes)
+	if total == 0 {
+		sender.SendText(fmt.Sprintf("The poll \"%s\" has ended, but nobody voted.", p.question))
+		return
+	}
+
+	results := make([]string, len(p.options))
+	winners := make([]string, 0)
+	best := 0
+
+	for idx, option := range p.options {
+		votes := tally[idx]
+		percent := int(float64(votes)*100/float64(total) + 0.5)
+
+		results[idx] = fmt.Sprintf("%s: %s (%d%%)", option, votesText(votes), percent)
+
+		if votes > best {
+			best = votes
+			winners = []string{option}
+		} else if votes == best {
+			winners = append(winners, option)
+		}
+	}
+
+	outcome := "The winner is " + winners[0] + "!"
+	if len(winners) > 1 {
+		outcome = "It's a tie between " + bot.HumanJoin(winners, ", ") + "!"
+	}
+
+	sender.SendText(fmt.Sprintf("The poll \"%s\" has ended: %s. %s", p.question, strings.Join(results, ", "), outcome))
+}
+
+// mode decides whether the first or the last vote of a user counts
+func (self *worker) mode() string {
+	mode, exists := self.storage.Get("poll", self.channel, "mode")
+	if !exists {
+		return "last"
+	}
+
+	return mode
+}
+
+func (self *worker) configureMode(args []string, sender bot.Sender) {
+	if len(args) == 0 {
+		sender.Respond("the " + self.mode() + " vote of each user counts.")
+		return
+	}
+
+	mode := strings.ToLower(args[0])
+	if mode != "first" && mode != "last" {
+		sender.Respond("the mode must be either first or last.")
+		return
+	}
+
+	err := self.storage.Set("poll", self.channel, "mode", mode)
+	if err != nil {
+		sender.Respond("the mode could not be saved, sorry.")
+		return
+	}
+
+	sender.Respond("from now on, the " + mode + " vote of each user counts.")
+}
+
+func votesText(votes int) string {
+	if votes == 1 {
+		return "1 vote"
+	}
+
+	return strconv.Itoa(votes) + " votes"
+}
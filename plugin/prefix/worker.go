@@ -0,0 +1,74 @@
+package prefix
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	channel bot.Channel
+	acl     *bot.ACL
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"change_prefix"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || !msg.IsGlobalCommand("prefix") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "change_prefix") {
+		return
+	}
+
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		sender.Respond("commands in this channel start with " + self.channel.Trigger() + ".")
+		return
+	}
+
+	trigger := args[0]
+
+	if !isValidTrigger(trigger) {
+		sender.Respond("the prefix must be one to three special characters, and cannot start with / or . (these are Twitch commands).")
+		return
+	}
+
+	if !self.channel.SetTrigger(trigger) {
+		sender.Respond("commands already start with " + trigger + ".")
+		return
+	}
+
+	sender.Respond("commands now start with " + trigger + ".")
+}
+
+// letters and digits would make regular words look like commands
+func isValidTrigger(trigger string) bool {
+	length := utf8.RuneCountInString(trigger)
+	if length < 1 || length > 3 {
+		return false
+	}
+
+	if strings.HasPrefix(trigger, "/") || strings.HasPrefix(trigger, ".") {
+		return false
+	}
+
+	for _, r := range trigger {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,55 @@
+package quotes
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	db       *sqlx.DB
+	storage  *bot.Storage
+	settings *bot.Settings
+	now      func() time.Time
+	intn     func(int) int
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWith(time.Now, nil)
+}
+
+// NewPluginWith lets the tests control the time and which quote is picked;
+// intn must behave like rand.Intn. Without it, every channel gets its own RNG.
+func NewPluginWith(now func() time.Time, intn func(int) int) *pluginStruct {
+	return &pluginStruct{now: now, intn: intn}
+}
+
+func (self *pluginStruct) Name() string {
+	return "quotes"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	self.storage = bot.Storage()
+	self.settings = bot.Settings()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	intn := self.intn
+	if intn == nil {
+		intn = rand.New(rand.NewSource(time.Now().UnixNano())).Intn
+	}
+
+	return &worker{
+		channel:  channel.Name(),
+		acl:      channel.ACL(),
+		db:       self.db,
+		storage:  self.storage,
+		settings: self.settings,
+		now:      self.now,
+		intn:     intn,
+	}
+}
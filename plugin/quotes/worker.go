@@ -0,0 +1,199 @@
+package quotes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"quote"}
+
+type quote struct {
+	ID   int
+	Text string
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  string
+	acl      *bot.ACL
+	db       *sqlx.DB
+	storage  *bot.Storage
+	settings *bot.Settings
+	quotes   []quote // ordered by ID
+	now      func() time.Time
+	intn     func(int) int
+}
+
+func (self *worker) Enable() {
+	self.quotes = make([]quote, 0)
+	self.db.Select(&self.quotes, "SELECT id, text FROM quotes WHERE channel = ? ORDER BY id", self.channel)
+}
+
+func (self *worker) Reload() {
+	self.Enable()
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"manage_quotes"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() || msg.Command() != "quote" {
+		return
+	}
+
+	msg.SetProcessed()
+
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		self.randomQuote(sender)
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if self.acl.IsAllowed(msg.User, "manage_quotes") {
+			self.addQuote(msg.ArgumentText(1), msg, sender)
+		}
+
+	case "list":
+		page := 1
+		if len(args) > 1 {
+			page = bot.PageNumber(args[1])
+		}
+
+		self.listQuotes(page, sender)
+
+	case "del":
+		if self.acl.IsAllowed(msg.User, "manage_quotes") {
+			if len(args) < 2 {
+				sender.Respond("no quote number given.")
+				return
+			}
+
+			self.deleteQuote(args[1], sender)
+		}
+
+	default:
+		self.getQuote(args[0], sender)
+	}
+}
+
+func (self *worker) randomQuote(sender bot.Sender) {
+	if len(self.quotes) == 0 {
+		sender.Respond("there are no quotes yet.")
+		return
+	}
+
+	q := self.quotes[self.intn(len(self.quotes))]
+	sender.SendText(fmt.Sprintf("Quote #%d: %s", q.ID, q.Text))
+}
+
+// listQuotes shows a page of quotes; the texts can contain commas, so they
+// are separated by pipes.
+func (self *worker) listQuotes(page int, sender bot.Sender) {
+	if len(self.quotes) == 0 {
+		sender.Respond("there are no quotes yet.")
+		return
+	}
+
+	items := make([]string, len(self.quotes))
+	for idx, q := range self.quotes {
+		items[idx] = fmt.Sprintf("#%d: %s", q.ID, q.Text)
+	}
+
+	window := bot.Paginate(items, page, self.settings.Int(self.channel, bot.PageSizeSetting))
+	response := strings.Join(window.Items, " | ")
+
+	if footer := window.Footer(); len(footer) > 0 {
+		response += " (" + footer + ")"
+	}
+
+	sender.SendText(response)
+}
+
+func (self *worker) getQuote(number string, sender bot.Sender) {
+	idx := self.find(number)
+	if idx == -1 {
+		sender.Respond("there is no quote #" + strings.TrimPrefix(number, "#") + ".")
+		return
+	}
+
+	q := self.quotes[idx]
+	sender.SendText(fmt.Sprintf("Quote #%d: %s", q.ID, q.Text))
+}
+
+func (self *worker) addQuote(text string, msg *bot.TextMessage, sender bot.Sender) {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		sender.Respond("you forgot the quote itself: " + msg.Trigger() + "quote add <text>")
+		return
+	}
+
+	id := self.lastID() + 1
+
+	self.quotes = append(self.quotes, quote{id, text})
+	self.db.Exec("INSERT INTO quotes (channel, id, text, added_by, added_at) VALUES (?, ?, ?, ?, ?)", self.channel, id, text, strings.ToLower(msg.User.Name), self.now())
+	self.storage.Set("quotes", self.channel, "last_id", strconv.Itoa(id))
+
+	sender.Respond(fmt.Sprintf("quote #%d has been added.", id))
+}
+
+func (self *worker) deleteQuote(number string, sender bot.Sender) {
+	idx := self.find(number)
+	if idx == -1 {
+		sender.Respond("there is no quote #" + strings.TrimPrefix(number, "#") + ".")
+		return
+	}
+
+	id := self.quotes[idx].ID
+
+	self.quotes = append(self.quotes[:idx], self.quotes[idx+1:]...)
+	self.db.Exec("DELETE FROM quotes WHERE channel = ? AND id = ?", self.channel, id)
+
+	sender.Respond(fmt.Sprintf("quote #%d has been deleted.", id))
+}
+
+// lastID returns the highest ID ever given out. IDs are never reused, not even
+// when the latest quote has been deleted, so it is remembered separately.
+func (self *worker) lastID() int {
+	last := 0
+
+	if stored, exists := self.storage.Get("quotes", self.channel, "last_id"); exists {
+		last, _ = strconv.Atoi(stored)
+	}
+
+	if len(self.quotes) > 0 && self.quotes[len(self.quotes)-1].ID > last {
+		last = self.quotes[len(self.quotes)-1].ID
+	}
+
+	return last
+}
+
+// find returns the index of the quote with the given number (like "3" or "#3") or -1
+func (self *worker) find(number string) int {
+	id, err := strconv.Atoi(strings.TrimPrefix(number, "#"))
+	if err != nil {
+		return -1
+	}
+
+	for idx, q := range self.quotes {
+		if q.ID == id {
+			return idx
+		}
+	}
+
+	return -1
+}
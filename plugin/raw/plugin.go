@@ -0,0 +1,30 @@
+package raw
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// The raw plugin lets the operator look at and send IRC lines directly, to
+// experiment with things the bot does not understand yet.
+type pluginStruct struct {
+	plugin.BasePlugin
+
+	log bot.Logger
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.log = bot.Logger()
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		log:     self.log,
+		watched: make(map[string]bool),
+	}
+}
@@ -0,0 +1,81 @@
+package raw
+
+import (
+	"strings"
+
+	"github.com/sorcix/irc"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	log     bot.Logger
+	watched map[string]bool // IRC commands whose lines are logged
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || !msg.IsFromOperator() || !msg.IsGlobalCommand("raw") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	// the line must be used verbatim, so the arguments cannot be tokenized
+	parts := strings.SplitN(msg.Text, " ", 3)
+	if len(parts) < 3 {
+		sender.Respond("usage: " + msg.Trigger() + msg.Command() + " send|watch|unwatch <line or IRC command>")
+		return
+	}
+
+	switch strings.ToLower(parts[1]) {
+	case "send":
+		line := parts[2]
+
+		// do not block the channel while waiting for the rate limiter
+		go func() {
+			if !<-sender.SendRaw(line) {
+				sender.Respond("the line could not be sent.")
+			}
+		}()
+
+	case "watch":
+		command := strings.ToUpper(strings.TrimSpace(parts[2]))
+		self.watched[command] = true
+		sender.Respond("raw " + command + " lines in " + self.channel + " will now be logged.")
+
+	case "unwatch":
+		command := strings.ToUpper(strings.TrimSpace(parts[2]))
+		delete(self.watched, command)
+		sender.Respond("raw " + command + " lines in " + self.channel + " are no longer logged.")
+
+	default:
+		sender.Respond("usage: " + msg.Trigger() + msg.Command() + " send|watch|unwatch <line or IRC command>")
+	}
+}
+
+func (self *worker) HandleRawLine(msg *twitch.RawLineMessage, sender bot.Sender) {
+	if len(self.watched) == 0 {
+		return
+	}
+
+	// tags are not part of the IRC message itself
+	line := msg.Line
+	if strings.HasPrefix(line, "@") {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) < 2 {
+			return
+		}
+
+		line = parts[1]
+	}
+
+	parsed := irc.ParseMessage(line)
+	if parsed != nil && self.watched[parsed.Command] {
+		self.log.Info("Raw line in %s: %s", self.channel, msg.Line)
+	}
+}
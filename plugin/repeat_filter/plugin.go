@@ -0,0 +1,54 @@
+package repeat_filter
+
+import (
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+// how often the same message may be sent within the window; the last one of
+// these gets the user timed out
+var repeats = bot.IntSetting(3, 2, 20)
+
+var window = bot.DurationSetting(30*time.Second, 5*time.Second, 10*time.Minute)
+
+var timeout = bot.DurationSetting(time.Minute, time.Second, 14*24*time.Hour)
+
+type pluginStruct struct {
+	settings *bot.Settings
+	now      func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "repeat_filter"
+}
+
+// spam should be stopped before a command can respond to it
+func (self *pluginStruct) Priority() int {
+	return bot.ModerationPriority
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.settings = bot.Settings()
+	self.settings.Register(self.Name(), "repeats", repeats)
+	self.settings.Register(self.Name(), "window", window)
+	self.settings.Register(self.Name(), "timeout", timeout)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:  channel.Name(),
+		acl:      channel.ACL(),
+		settings: self.settings,
+		now:      self.now,
+	}
+}
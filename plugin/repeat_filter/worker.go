@@ -0,0 +1,123 @@
+package repeat_filter
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type recentMessage struct {
+	fingerprint string
+	sentAt      time.Time
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  string
+	acl      *bot.ACL
+	settings *bot.Settings
+	now      func() time.Time
+
+	history   map[string][]recentMessage // per user, oldest first
+	lastSweep time.Time
+}
+
+func (self *worker) Enable() {
+	self.history = make(map[string][]recentMessage)
+	self.lastSweep = self.now()
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"bypass_repeat_filter"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	// commands are limited by the throttle instead
+	if msg.IsProcessed() || msg.IsFromBot() || msg.Command() != "" || self.acl.IsTrusted(msg.User) {
+		return
+	}
+
+	now := self.now()
+	since := now.Add(-self.settings.Duration(self.channel, "repeat_filter.window"))
+	user := strings.ToLower(msg.User.Name)
+	fingerprint := Fingerprint(msg.Normalized())
+
+	self.sweep(now, since)
+
+	messages := append(recentSince(self.history[user], since), recentMessage{fingerprint, now})
+	self.history[user] = messages
+
+	count := 0
+
+	for _, m := range messages {
+		if m.fingerprint == fingerprint {
+			count++
+		}
+	}
+
+	if count < self.settings.Int(self.channel, "repeat_filter.repeats") || self.acl.IsAllowed(msg.User, "bypass_repeat_filter") {
+		return
+	}
+
+	delete(self.history, user)
+
+	sender.Timeout(user, int(self.settings.Duration(self.channel, "repeat_filter.timeout").Seconds()), "")
+	sender.Respond("please don't repeat yourself.")
+
+	msg.StopPropagation()
+}
+
+// sweep forgets about users who have been quiet for a whole window, so the
+// history does not grow with everyone who ever said something.
+func (self *worker) sweep(now time.Time, since time.Time) {
+	if self.lastSweep.After(since) {
+		return
+	}
+
+	for user, messages := range self.history {
+		if len(recentSince(messages, since)) == 0 {
+			delete(self.history, user)
+		}
+	}
+
+	self.lastSweep = now
+}
+
+func recentSince(messages []recentMessage, since time.Time) []recentMessage {
+	for i, m := range messages {
+		if !m.sentAt.Before(since) {
+			return messages[i:]
+		}
+	}
+
+	return nil
+}
+
+// Fingerprint reduces a message to what makes it different from others, so
+// that "Hello!!", "hello" and "h e l l o o o" count as the same message.
+// Messages without any letters or digits are compared as they are.
+func Fingerprint(text string) string {
+	result := make([]rune, 0, len(text))
+
+	for _, r := range strings.ToLower(text) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+
+		if len(result) > 0 && result[len(result)-1] == r {
+			continue
+		}
+
+		result = append(result, r)
+	}
+
+	if len(result) == 0 {
+		return strings.Join(strings.Fields(text), " ")
+	}
+
+	return string(result)
+}
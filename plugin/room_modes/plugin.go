@@ -0,0 +1,21 @@
+package room_modes
+
+import "github.com/sgt-kabukiman/kabukibot/bot"
+
+type pluginStruct struct{}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Name() string {
+	return "room_modes"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{acl: channel.ACL()}
+}
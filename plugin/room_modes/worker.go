@@ -0,0 +1,129 @@
+package room_modes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"followersonly", "emoteonly", "subsonly", "slow"}
+
+// the limits Twitch imposes on the room modes
+const (
+	maxFollowersMinutes = 90 * 24 * 60
+	maxSlowSeconds      = 120
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	acl *bot.ACL
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"change_room_modes"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "change_room_modes"
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	cmd := msg.Command()
+	if !isRoomModeCommand(cmd) {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "change_room_modes") {
+		return
+	}
+
+	args := msg.Arguments()
+	arg := ""
+
+	if len(args) > 0 {
+		arg = strings.ToLower(args[0])
+	}
+
+	switch cmd {
+	case "followersonly":
+		self.followersOnly(arg, msg, sender)
+	case "emoteonly":
+		self.toggle(arg, ".emoteonly", msg, sender)
+	case "subsonly":
+		self.toggle(arg, ".subscribers", msg, sender)
+	case "slow":
+		self.slow(arg, msg, sender)
+	}
+}
+
+// followersonly [minutes|off], where the minutes are how long users must have
+// followed the channel before they can chat
+func (self *worker) followersOnly(arg string, msg *bot.TextMessage, sender bot.Sender) {
+	switch arg {
+	case "":
+		sender.SendCommand(".followers")
+	case "off":
+		sender.SendCommand(".followersoff")
+	default:
+		minutes, err := strconv.Atoi(arg)
+		if err != nil || minutes < 0 || minutes > maxFollowersMinutes {
+			sender.Respond("usage: " + msg.Trigger() + "followersonly [minutes|off], with up to " + strconv.Itoa(maxFollowersMinutes) + " minutes.")
+			return
+		}
+
+		sender.SendCommand(".followers " + strconv.Itoa(minutes) + "m")
+	}
+}
+
+func (self *worker) toggle(arg string, command string, msg *bot.TextMessage, sender bot.Sender) {
+	switch arg {
+	case "on":
+		sender.SendCommand(command)
+	case "off":
+		sender.SendCommand(command + "off")
+	default:
+		sender.Respond("usage: " + msg.Trigger() + msg.Command() + " on|off")
+	}
+}
+
+// slow [seconds|off], where the seconds are how long users have to wait
+// between two messages
+func (self *worker) slow(arg string, msg *bot.TextMessage, sender bot.Sender) {
+	switch arg {
+	case "":
+		sender.SendCommand(".slow")
+	case "off":
+		sender.SendCommand(".slowoff")
+	default:
+		seconds, err := strconv.Atoi(arg)
+		if err != nil || seconds < 1 || seconds > maxSlowSeconds {
+			sender.Respond("usage: " + msg.Trigger() + "slow [seconds|off], with 1 to " + strconv.Itoa(maxSlowSeconds) + " seconds.")
+			return
+		}
+
+		sender.SendCommand(".slow " + strconv.Itoa(seconds))
+	}
+}
+
+func isRoomModeCommand(cmd string) bool {
+	for _, c := range commands {
+		if c == cmd {
+			return true
+		}
+	}
+
+	return false
+}
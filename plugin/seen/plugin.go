@@ -0,0 +1,39 @@
+package seen
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	db  *sqlx.DB
+	now func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "seen"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		db:      self.db,
+		now:     self.now,
+	}
+}
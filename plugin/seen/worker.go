@@ -0,0 +1,148 @@
+package seen
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"seen"}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel     string
+	db          *sqlx.DB
+	now         func() time.Time
+	seen        map[string]time.Time // only users that chatted since the last sync
+	mutex       sync.Mutex
+	syncing     chan struct{}
+	stopSyncing chan struct{}
+}
+
+func (self *worker) Enable() {
+	self.mutex.Lock()
+	self.seen = make(map[string]time.Time)
+	self.mutex.Unlock()
+
+	self.syncing = make(chan struct{})
+	self.stopSyncing = make(chan struct{})
+
+	go self.worker()
+}
+
+func (self *worker) Disable() {
+	close(self.stopSyncing)
+	<-self.syncing
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+// Timestamps are only written in the background, so chatty users do not
+// cause a query for every line.
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBot() {
+		return
+	}
+
+	user := strings.ToLower(msg.User.Name)
+
+	self.mutex.Lock()
+	self.seen[user] = self.now()
+	self.mutex.Unlock()
+
+	if msg.IsProcessed() || msg.Command() != "seen" {
+		return
+	}
+
+	msg.SetProcessed()
+
+	args := msg.Arguments()
+	if len(args) == 0 {
+		sender.Respond("usage: " + msg.Trigger() + "seen <user>")
+		return
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(args[0], "@"))
+
+	if name == user {
+		sender.Respond("you are right here.")
+		return
+	}
+
+	last, found := self.lastSeen(name)
+	if !found {
+		sender.Respond("I have never seen " + name + " in this channel.")
+		return
+	}
+
+	sender.Respond(name + " was last seen " + relativeTime(self.now().Sub(last)) + ".")
+}
+
+func (self *worker) lastSeen(user string) (time.Time, bool) {
+	self.mutex.Lock()
+	last, found := self.seen[user]
+	self.mutex.Unlock()
+
+	if found {
+		return last, true
+	}
+
+	timestamps := make([]int64, 0)
+	self.db.Select(&timestamps, "SELECT seen_at FROM last_seen WHERE channel = ? AND username = ?", self.channel, user)
+
+	if len(timestamps) == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(timestamps[0], 0), true
+}
+
+func (self *worker) worker() {
+	defer close(self.syncing)
+
+	for {
+		select {
+		case <-time.After(1 * time.Minute):
+			self.sync()
+
+		case <-self.stopSyncing:
+			self.sync()
+			return
+		}
+	}
+}
+
+// sync writes the timestamps of everyone who chatted since the last time
+func (self *worker) sync() {
+	self.mutex.Lock()
+	changed := self.seen
+	self.seen = make(map[string]time.Time)
+	self.mutex.Unlock()
+
+	for user, last := range changed {
+		self.db.Exec("DELETE FROM last_seen WHERE channel = ? AND username = ?", self.channel, user)
+		self.db.Exec("INSERT INTO last_seen (channel, username, seen_at) VALUES (?, ?, ?)", self.channel, user, last.Unix())
+	}
+}
+
+// relativeTime only uses the largest unit, like "3h ago"
+func relativeTime(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	default:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+}
@@ -0,0 +1,33 @@
+package settings
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// The settings plugin is the one place where the settings all other plugins
+// registered can be looked at and changed.
+type pluginStruct struct {
+	plugin.BasePlugin
+
+	settings *bot.Settings
+	log      bot.Logger
+}
+
+func NewPlugin() *pluginStruct {
+	return &pluginStruct{}
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.settings = bot.Settings()
+	self.log = bot.Logger()
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:  channel.Name(),
+		acl:      channel.ACL(),
+		settings: self.settings,
+		log:      self.log,
+	}
+}
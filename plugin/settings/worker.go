@@ -0,0 +1,100 @@
+package settings
+
+import (
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  string
+	acl      *bot.ACL
+	settings *bot.Settings
+	log      bot.Logger
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"change_settings"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() {
+		return
+	}
+
+	get := msg.IsGlobalCommand("get")
+	if !get && !msg.IsGlobalCommand("set") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "change_settings") {
+		return
+	}
+
+	if get {
+		self.respondGet(msg, sender)
+	} else {
+		self.respondSet(msg, sender)
+	}
+}
+
+func (self *worker) respondGet(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		names := self.settings.Names()
+
+		if len(names) == 0 {
+			sender.Respond("there are no settings.")
+		} else {
+			sender.Respond("available settings: " + strings.Join(names, ", "))
+		}
+
+		return
+	}
+
+	name := strings.ToLower(args[0])
+
+	_, exists := self.settings.Lookup(name)
+	if !exists {
+		sender.Respond("there is no setting named '" + name + "'.")
+		return
+	}
+
+	sender.Respond(name + " is " + self.settings.Get(self.channel, name) + ".")
+}
+
+func (self *worker) respondSet(msg *bot.TextMessage, sender bot.Sender) {
+	if !msg.RequireArgs(2, "<plugin>.<setting> <value>", sender) {
+		return
+	}
+
+	args := msg.Arguments()
+	name := strings.ToLower(args[0])
+
+	setting, exists := self.settings.Lookup(name)
+	if !exists {
+		sender.Respond("there is no setting named '" + name + "'.")
+		return
+	}
+
+	value, err := setting.Normalize(msg.ArgumentText(1))
+	if err != nil {
+		sender.Respond("invalid value for " + name + ", " + err.Error() + ".")
+		return
+	}
+
+	err = self.settings.Set(self.channel, name, value)
+	if err != nil {
+		self.log.Error("Could not store setting %s in %s: %s", name, self.channel, err.Error())
+		sender.Respond("something went wrong, please try again later.")
+		return
+	}
+
+	sender.Respond(name + " is now " + value + ".")
+}
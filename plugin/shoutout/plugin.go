@@ -0,0 +1,56 @@
+package shoutout
+
+import (
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+// raiders get a shoutout automatically, but not more than once per cooldown
+var autoShoutout = bot.BoolSetting(false)
+
+var raidCooldown = bot.DurationSetting(time.Hour, time.Minute, 24*time.Hour)
+
+type pluginStruct struct {
+	api      *twitch.APIClient
+	dict     *bot.Dictionary
+	log      bot.Logger
+	settings *bot.Settings
+	now      func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "shoutout"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.api = bot.TwitchAPI()
+	self.dict = bot.Dictionary()
+	self.log = bot.Logger()
+	self.settings = bot.Settings()
+	self.settings.Register(self.Name(), "auto_shoutout", autoShoutout)
+	self.settings.Register(self.Name(), "raid_cooldown", raidCooldown)
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:  channel.Name(),
+		acl:      channel.ACL(),
+		api:      self.api,
+		dict:     self.dict,
+		log:      self.log,
+		settings: self.settings,
+		now:      self.now,
+	}
+}
@@ -0,0 +1,268 @@
+package shoutout
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"so", "soq", "so_template"}
+
+const defaultTemplate = "Check out @{name}, they were last playing {game} at twitch.tv/{channel}"
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  string
+	acl      *bot.ACL
+	api      *twitch.APIClient
+	dict     *bot.Dictionary
+	log      bot.Logger
+	settings *bot.Settings
+	now      func() time.Time
+	template string
+	raids    map[string]time.Time // when each raider was last shouted out
+	queue    []string             // channels to shout out one after another
+}
+
+func (self *worker) Enable() {
+	self.template = defaultTemplate
+	self.raids = make(map[string]time.Time)
+	self.queue = nil
+
+	if self.dict.Has(self.key()) {
+		self.template = self.dict.Get(self.key())
+	}
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"use_shoutout", "configure_shoutout"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	if command == "so_template" {
+		return "configure_shoutout"
+	}
+
+	return "use_shoutout"
+}
+
+func (self *worker) key() string {
+	return "shoutout_" + strings.TrimPrefix(self.channel, "#") + "_template"
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	if msg.IsCommand("so") {
+		msg.SetProcessed()
+
+		if self.mayShoutout(msg) {
+			self.shoutout(msg, sender)
+		}
+	} else if msg.IsCommand("soq") {
+		msg.SetProcessed()
+
+		if self.mayShoutout(msg) {
+			self.handleQueue(msg, sender)
+		}
+	} else if msg.IsCommand("so_template") {
+		msg.SetProcessed()
+
+		if self.acl.IsAllowed(msg.User, "configure_shoutout") {
+			self.setTemplate(msg.ArgumentText(0), sender)
+		}
+	}
+}
+
+// moderators may give shoutouts until the permission has been granted to someone explicitly
+func (self *worker) mayShoutout(msg *bot.TextMessage) bool {
+	if self.acl.IsAllowed(msg.User, "use_shoutout") {
+		return true
+	}
+
+	if len(self.acl.AllowedUsers("use_shoutout")) > 0 {
+		return false
+	}
+
+	t := msg.User.Type
+
+	return t == twitch.Moderator || t == twitch.GlobalModerator || t == twitch.TwitchStaff || t == twitch.TwitchAdmin
+}
+
+func (self *worker) shoutout(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		sender.Respond("you have to give a channel: `" + msg.Trigger() + "so <channel>`.")
+		return
+	}
+
+	self.shoutoutTo(normalizeChannel(args[0]), sender)
+}
+
+func (self *worker) shoutoutTo(target string, sender bot.Sender) {
+	template := self.template
+
+	// do not block the channel while waiting for Twitch
+	go func() {
+		info, err := self.api.Channel(target)
+
+		switch err {
+		case nil:
+			sender.SendText(render(template, info))
+		case twitch.ErrUnknownUser:
+			sender.Respond("there is no channel named " + target + ".")
+		default:
+			self.log.Error("Could not query the Twitch API: %s", err.Error())
+			sender.Respond("could not reach Twitch, please try again later.")
+		}
+	}()
+}
+
+// handleQueue lets mods collect channels (like a bunch of raiders) and shout
+// them out one at a time, instead of flooding the chat with all of them at once.
+func (self *worker) handleQueue(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		if len(self.queue) == 0 {
+			sender.Respond("the shoutout queue is empty.")
+		} else {
+			sender.Respond("the shoutout queue is: " + bot.HumanJoin(self.queue, ", ") + ".")
+		}
+
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add":
+		if len(args) < 2 {
+			sender.Respond("you have to give a channel: `" + msg.Trigger() + "soq add <channel>`.")
+			return
+		}
+
+		self.enqueue(normalizeChannel(args[1]), sender)
+
+	case "next":
+		if len(self.queue) == 0 {
+			sender.Respond("the shoutout queue is empty.")
+			return
+		}
+
+		target := self.queue[0]
+		self.queue = self.queue[1:]
+
+		self.shoutoutTo(target, sender)
+
+	case "clear":
+		self.queue = nil
+		sender.Respond("the shoutout queue has been cleared.")
+
+	default:
+		sender.Respond("usage: " + msg.Trigger() + "soq [add <channel>|next|clear]")
+	}
+}
+
+func (self *worker) enqueue(target string, sender bot.Sender) {
+	if !channelName.MatchString(target) {
+		sender.Respond(target + " is not a valid channel name.")
+		return
+	}
+
+	for _, queued := range self.queue {
+		if queued == target {
+			sender.Respond(target + " is already in the shoutout queue.")
+			return
+		}
+	}
+
+	self.queue = append(self.queue, target)
+
+	sender.Respond(fmt.Sprintf("%s has been added to the shoutout queue (position %d).", target, len(self.queue)))
+}
+
+var channelName = regexp.MustCompile(`^[a-z0-9_]{1,25}$`)
+
+func normalizeChannel(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "@"))
+}
+
+func (self *worker) HandleRaidMessage(msg *twitch.RaidMessage, sender bot.Sender) {
+	if !self.settings.Bool(self.channel, "shoutout.auto_shoutout") {
+		return
+	}
+
+	raider := strings.ToLower(msg.Raider)
+	now := self.now()
+
+	// the same channel raiding again and again should not flood the chat
+	last, raided := self.raids[raider]
+	if raided && now.Sub(last) < self.settings.Duration(self.channel, "shoutout.raid_cooldown") {
+		return
+	}
+
+	self.raids[raider] = now
+
+	viewers := "1 viewer"
+	if msg.Viewers != 1 {
+		viewers = fmt.Sprintf("%d viewers", msg.Viewers)
+	}
+
+	template := self.template
+
+	go func() {
+		thanks := "Thank you for the raid with " + viewers + "!"
+
+		info, err := self.api.Channel(raider)
+		if err != nil {
+			self.log.Error("Could not query the Twitch API for raider %s: %s", raider, err.Error())
+			sender.SendText(thanks + " Check out @" + raider + " at twitch.tv/" + raider)
+			return
+		}
+
+		sender.SendText(thanks + " " + render(template, info))
+	}()
+}
+
+func (self *worker) setTemplate(template string, sender bot.Sender) {
+	if len(template) == 0 {
+		sender.Respond("the shoutout is currently: " + self.template + " ({channel}, {name}, {game} and {title} will be replaced)")
+		return
+	}
+
+	self.template = template
+	self.dict.Set(self.key(), self.template)
+
+	sender.Respond("the shoutout message has been updated.")
+}
+
+// channels that never streamed have no game, so we cannot talk about it
+func render(template string, info twitch.ChannelInfo) string {
+	name := info.DisplayName
+	if len(name) == 0 {
+		name = info.Login
+	}
+
+	if len(info.Game) == 0 {
+		return "Check out @" + name + " at twitch.tv/" + info.Login
+	}
+
+	message := strings.Replace(template, "{channel}", info.Login, -1)
+	message = strings.Replace(message, "{name}", name, -1)
+	message = strings.Replace(message, "{game}", info.Game, -1)
+	message = strings.Replace(message, "{title}", info.Title, -1)
+
+	return message
+}
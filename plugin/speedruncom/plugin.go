@@ -36,6 +36,7 @@ func (self *Plugin) Name() string {
 func (self *Plugin) Setup(bot *bot.Kabukibot) {
 	self.config = speedruncomConfig{}
 	self.dict = bot.Dictionary()
+	bot.Commands().Register(self.Name(), commands...)
 
 	err := bot.Configuration().PluginConfig(self.Name(), &self.config)
 	if err != nil {
@@ -9,6 +9,8 @@ import (
 	"github.com/sgt-kabukiman/srapi"
 )
 
+var commands = []string{"wr"}
+
 type worker struct {
 	plugin.NilWorker
 
@@ -20,6 +22,14 @@ func (self *worker) Permissions() []string {
 	return []string{"use_speedruncom_commands"}
 }
 
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "use_speedrun_commands"
+}
+
 func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	if msg.IsProcessed() || msg.IsFromBot() {
 		return
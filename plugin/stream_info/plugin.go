@@ -0,0 +1,42 @@
+package stream_info
+
+import (
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+type pluginStruct struct {
+	api *twitch.APIClient
+	log bot.Logger
+	now func() time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time) *pluginStruct {
+	return &pluginStruct{now: now}
+}
+
+func (self *pluginStruct) Name() string {
+	return "stream_info"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.api = bot.TwitchAPI()
+	self.log = bot.Logger()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel: channel.Name(),
+		api:     self.api,
+		log:     self.log,
+		now:     self.now,
+	}
+}
@@ -0,0 +1,115 @@
+package stream_info
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+var commands = []string{"uptime", "followage", "accountage"}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel string
+	api     *twitch.APIClient
+	log     bot.Logger
+	now     func() time.Time
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	if msg.IsCommand("uptime") {
+		msg.SetProcessed()
+
+		// do not block the channel while waiting for Twitch
+		go self.respondUptime(sender)
+	} else if msg.IsCommand("followage") {
+		msg.SetProcessed()
+
+		user := msg.User.Name
+		args := msg.Arguments()
+
+		if len(args) > 0 {
+			user = strings.TrimPrefix(args[0], "@")
+		}
+
+		go self.respondFollowage(strings.ToLower(user), sender)
+	} else if msg.IsCommand("accountage") {
+		msg.SetProcessed()
+
+		user := msg.User.Name
+		args := msg.Arguments()
+
+		if len(args) > 0 {
+			user = strings.TrimPrefix(args[0], "@")
+		}
+
+		go self.respondAccountAge(strings.ToLower(user), sender)
+	}
+}
+
+func (self *worker) respondUptime(sender bot.Sender) {
+	uptime, err := self.api.StreamUptime(self.channel)
+
+	switch err {
+	case nil:
+		sender.Respond("the stream has been live for " + formatDuration(uptime.Duration) + ".")
+	case twitch.ErrStreamOffline:
+		sender.Respond("the stream is currently offline.")
+	default:
+		self.apiError(err, sender)
+	}
+}
+
+func (self *worker) respondFollowage(user string, sender bot.Sender) {
+	followage, err := self.api.FollowAge(self.channel, user)
+
+	switch err {
+	case nil:
+		sender.Respond(user + " has been following for " + formatDuration(followage.Duration) + " (since " + followage.FollowedAt.Format("2006-01-02") + ").")
+	case twitch.ErrNotFollowing:
+		sender.Respond(user + " is not following " + strings.TrimPrefix(self.channel, "#") + ".")
+	case twitch.ErrUnknownUser:
+		sender.Respond("there is no user named " + user + ".")
+	default:
+		self.apiError(err, sender)
+	}
+}
+
+func (self *worker) respondAccountAge(user string, sender bot.Sender) {
+	account, err := self.api.Account(user)
+
+	switch err {
+	case nil:
+		sender.Respond(user + "'s account is " + bot.FormatAge(account.CreatedAt, self.now()) + " old (created " + account.CreatedAt.Format("2006-01-02") + ").")
+	case twitch.ErrUnknownUser:
+		sender.Respond("there is no user named " + user + ".")
+	default:
+		self.apiError(err, sender)
+	}
+}
+
+func (self *worker) apiError(err error, sender bot.Sender) {
+	self.log.Error("Could not query the Twitch API: %s", err.Error())
+	sender.Respond("could not reach Twitch, please try again later.")
+}
+
+// nobody cares about the seconds
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+
+	return bot.FormatDuration(d-d%time.Minute, true)
+}
@@ -16,6 +16,7 @@ func (self *pluginStruct) Name() string {
 
 func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
 	self.dict = bot.Dictionary()
+	bot.Commands().Register(self.Name(), "submsg")
 }
 
 func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
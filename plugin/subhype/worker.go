@@ -1,6 +1,7 @@
 package subhype
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/sgt-kabukiman/kabukibot/bot"
@@ -31,11 +32,11 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 	args := msg.Arguments()
 
 	if len(args) == 0 {
-		sender.Respond("you forgot to add a message: `!submsg PogChamp, {user} just became awesome!`. {user} will be replaced with the user who subscribed. To disable notifications, just disable the plugin.")
+		sender.Respond("you forgot to add a message: `" + msg.Trigger() + "submsg PogChamp, {user} just became awesome!`. {user} will be replaced with the user who subscribed, {months} with the number of months. To disable notifications, just disable the plugin.")
 		return
 	}
 
-	text := strings.Join(args, " ")
+	text := msg.ArgumentText(0)
 	key := "subhype_" + strings.TrimPrefix(msg.ChannelName(), "#") + "_message"
 
 	self.message = text
@@ -55,6 +56,7 @@ func (self *worker) HandleSubscriberNotificationMessage(msg *twitch.SubscriberNo
 	message = strings.Replace(message, "{user}", uname, -1)
 	message = strings.Replace(message, "{username}", uname, -1)
 	message = strings.Replace(message, "{subscriber}", uname, -1)
+	message = strings.Replace(message, "{months}", strconv.Itoa(msg.Months), -1)
 
 	sender.SendText(message)
 }
@@ -29,10 +29,31 @@ func (self *pluginStruct) HandleTextMessage(msg *bot.TextMessage, sender bot.Sen
 			)
 
 			sender.Respond(infoString)
+			return
+		}
+
+		if msg.IsGlobalCommand("health") {
+			// pinging the database must not block the channel
+			go func() {
+				sender.Respond(formatHealth(self.bot.Health()))
+			}()
 		}
 	}
 }
 
+func formatHealth(report bot.HealthReport) string {
+	database := "database ok"
+	if report.DatabaseError != nil {
+		database = "database unreachable (" + report.DatabaseError.Error() + ")"
+	}
+
+	return fmt.Sprintf(
+		"%s, %d channels, %d queued messages, %d queued whispers, %d/%d messages available, up for %s, %d goroutines.",
+		database, report.Channels, report.QueuedMessages, report.QueuedWhispers, report.RateLimitTokens, report.RateLimitBurst,
+		report.Uptime-report.Uptime%time.Second, report.Goroutines,
+	)
+}
+
 func (self *pluginStruct) HandleClearChatMessage(msg *twitch.ClearChatMessage, sender bot.Sender) {
 	self.countMessage()
 }
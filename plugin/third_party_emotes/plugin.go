@@ -0,0 +1,69 @@
+package third_party_emotes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+// !topemotes only looks at the emotes used during this window
+var window = bot.DurationSetting(time.Hour, time.Minute, maxWindow)
+
+const maxWindow = 24 * time.Hour
+
+type emoteConfig struct {
+	Refresh int // minutes between checks whether the emote sets have expired
+	TTL     int `yaml:"ttl"` // minutes for which a fetched emote set is used
+}
+
+type pluginStruct struct {
+	config   emoteConfig
+	sources  *emoteSources
+	log      bot.Logger
+	settings *bot.Settings
+	client   *http.Client
+	now      func() time.Time
+	after    func(time.Duration) <-chan time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClient(&http.Client{Timeout: 10 * time.Second}, time.Now, time.After)
+}
+
+// NewPluginWithClient lets the tests stub the BTTV/FFZ APIs and control the time.
+func NewPluginWithClient(client *http.Client, now func() time.Time, after func(time.Duration) <-chan time.Time) *pluginStruct {
+	return &pluginStruct{client: client, now: now, after: after}
+}
+
+func (self *pluginStruct) Name() string {
+	return "third_party_emotes"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.config = emoteConfig{Refresh: 10, TTL: 60}
+	self.log = bot.Logger()
+	self.settings = bot.Settings()
+	self.settings.Register(self.Name(), "window", window)
+	bot.Commands().Register(self.Name(), commands...)
+
+	err := bot.Configuration().PluginConfig(self.Name(), &self.config)
+	if err != nil {
+		self.log.Warning("Could not load 'third_party_emotes' plugin configuration: %s", err)
+	}
+
+	ttl := time.Duration(self.config.TTL) * time.Minute
+	self.sources = newEmoteSources(self.client, bot.TwitchAPI(), ttl, self.now)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:  channel.Name(),
+		sources:  self.sources,
+		log:      self.log,
+		settings: self.settings,
+		refresh:  time.Duration(self.config.Refresh) * time.Minute,
+		now:      self.now,
+		after:    self.after,
+	}
+}
@@ -0,0 +1,141 @@
+package third_party_emotes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/twitch"
+)
+
+const bttvURL = "https://api.betterttv.net/3"
+const ffzURL = "https://api.frankerfacez.com/v1"
+
+// emoteSet contains the codes of all BTTV and FFZ emotes usable in a channel.
+type emoteSet map[string]bool
+
+type cachedSet struct {
+	emotes  emoteSet
+	expires time.Time
+}
+
+type bttvUser struct {
+	ChannelEmotes []bttvEmote `json:"channelEmotes"`
+	SharedEmotes  []bttvEmote `json:"sharedEmotes"`
+}
+
+type bttvEmote struct {
+	Code string `json:"code"`
+}
+
+type ffzRoom struct {
+	Sets map[string]struct {
+		Emoticons []struct {
+			Name string `json:"name"`
+		} `json:"emoticons"`
+	} `json:"sets"`
+}
+
+// emoteSources fetches the emote sets from BTTV and FFZ and caches them, so
+// that re-enabling the plugin or refreshing often does not hammer their APIs.
+type emoteSources struct {
+	http  *http.Client
+	api   *twitch.APIClient
+	ttl   time.Duration
+	cache map[string]cachedSet
+	mutex sync.Mutex
+	now   func() time.Time
+}
+
+func newEmoteSources(client *http.Client, api *twitch.APIClient, ttl time.Duration, now func() time.Time) *emoteSources {
+	return &emoteSources{
+		http:  client,
+		api:   api,
+		ttl:   ttl,
+		cache: make(map[string]cachedSet),
+		now:   now,
+	}
+}
+
+// Emotes returns the channel's emote set, fetching it anew once the cached one
+// has expired. If that fails, the stale set (if any) is returned alongside the
+// error, as outdated emotes are still better than none at all.
+func (self *emoteSources) Emotes(channel string) (emoteSet, error) {
+	self.mutex.Lock()
+	cached, exists := self.cache[channel]
+	self.mutex.Unlock()
+
+	if exists && self.now().Before(cached.expires) {
+		return cached.emotes, nil
+	}
+
+	emotes, err := self.fetch(channel)
+	if err != nil {
+		return cached.emotes, err
+	}
+
+	self.mutex.Lock()
+	self.cache[channel] = cachedSet{emotes, self.now().Add(self.ttl)}
+	self.mutex.Unlock()
+
+	return emotes, nil
+}
+
+func (self *emoteSources) fetch(channel string) (emoteSet, error) {
+	// both APIs want to know the channel's Twitch ID
+	account, err := self.api.Account(strings.TrimPrefix(channel, "#"))
+	if err != nil {
+		return nil, err
+	}
+
+	emotes := make(emoteSet)
+
+	bttv := bttvUser{}
+
+	err = self.get(bttvURL+"/cached/users/twitch/"+account.ID, &bttv)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, emote := range append(bttv.ChannelEmotes, bttv.SharedEmotes...) {
+		emotes[emote.Code] = true
+	}
+
+	ffz := ffzRoom{}
+
+	err = self.get(ffzURL+"/room/id/"+account.ID, &ffz)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, set := range ffz.Sets {
+		for _, emote := range set.Emoticons {
+			emotes[emote.Name] = true
+		}
+	}
+
+	return emotes, nil
+}
+
+// get leaves dest alone if the channel is not known to the API, as that only
+// means the channel does not use BTTV/FFZ.
+func (self *emoteSources) get(address string, dest interface{}) error {
+	response, err := self.http.Get(address)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with HTTP %d.", address, response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(dest)
+}
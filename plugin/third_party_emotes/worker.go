@@ -0,0 +1,229 @@
+package third_party_emotes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"topemotes"}
+
+// usage counts the emotes used during one minute
+type usage struct {
+	minute time.Time
+	counts map[string]int
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel  string
+	sources  *emoteSources
+	log      bot.Logger
+	settings *bot.Settings
+	emotes   emoteSet
+	usages   []usage // oldest first
+	refresh  time.Duration
+	now      func() time.Time
+	after    func(time.Duration) <-chan time.Time
+	stop     chan struct{}
+	stopped  chan struct{}
+	mutex    sync.RWMutex
+}
+
+func (self *worker) Enable() {
+	// if we for some reason are already refreshing, stop now
+	if self.stop != nil {
+		self.Disable()
+	}
+
+	self.mutex.Lock()
+	self.emotes = nil
+	self.usages = nil
+	self.mutex.Unlock()
+
+	self.stop = make(chan struct{})
+	self.stopped = make(chan struct{})
+
+	go self.refresher()
+}
+
+func (self *worker) Disable() {
+	if self.stop == nil {
+		return
+	}
+
+	close(self.stop)
+	<-self.stopped
+
+	self.stop = nil
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.IsFromBot() {
+		return
+	}
+
+	if msg.IsCommand("topemotes") {
+		self.handleTopEmotesCommand(msg, sender)
+		msg.SetProcessed()
+	} else {
+		self.countEmotes(msg)
+	}
+}
+
+func (self *worker) handleTopEmotesCommand(msg *bot.TextMessage, sender bot.Sender) {
+	max := 5
+	args := msg.Arguments()
+
+	if len(args) > 0 {
+		value, err := strconv.Atoi(args[0])
+		if err == nil {
+			if value > 10 {
+				max = 10
+			} else if value < 1 {
+				max = 1
+			} else {
+				max = value
+			}
+		}
+	}
+
+	window := self.settings.Duration(self.channel, "third_party_emotes.window")
+	since := bot.FormatDuration(window, true)
+	top := self.topEmotes(window, max)
+
+	if len(top) == 0 {
+		sender.Respond("no BTTV or FFZ emotes have been used in the last " + since + ".")
+		return
+	}
+
+	output := make([]string, len(top))
+
+	for idx, emote := range top {
+		output[idx] = fmt.Sprintf("%s (%s x)", emote.emote, humanize.FormatInteger("#,###.", emote.count))
+	}
+
+	sender.Respond(fmt.Sprintf("the most used emotes in the last %s are: %s.", since, bot.HumanJoin(output, ", ")))
+}
+
+// countEmotes matches the words against the emote set, as BTTV/FFZ emotes are
+// not part of the emotes tag Twitch sends along.
+func (self *worker) countEmotes(msg *bot.TextMessage) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if len(self.emotes) == 0 {
+		return
+	}
+
+	minute := self.now().Truncate(time.Minute)
+
+	for _, word := range strings.Fields(msg.Text) {
+		if !self.emotes[word] {
+			continue
+		}
+
+		if len(self.usages) == 0 || !self.usages[len(self.usages)-1].minute.Equal(minute) {
+			self.usages = append(self.usages, usage{minute, make(map[string]int)})
+		}
+
+		self.usages[len(self.usages)-1].counts[word]++
+	}
+
+	// nothing older than the largest possible window is ever needed again
+	cutoff := minute.Add(-maxWindow)
+
+	for len(self.usages) > 0 && self.usages[0].minute.Before(cutoff) {
+		self.usages = self.usages[1:]
+	}
+}
+
+type emoteFlat struct {
+	emote string
+	count int
+}
+
+// emoteSorter puts the most used emotes first, ties are sorted by name.
+type emoteSorter []emoteFlat
+
+func (a emoteSorter) Len() int {
+	return len(a)
+}
+
+func (a emoteSorter) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+
+func (a emoteSorter) Less(i, j int) bool {
+	if a[i].count != a[j].count {
+		return a[i].count > a[j].count
+	}
+
+	return a[i].emote < a[j].emote
+}
+
+func (self *worker) topEmotes(window time.Duration, max int) []emoteFlat {
+	since := self.now().Truncate(time.Minute).Add(-window)
+	totals := make(map[string]int)
+
+	self.mutex.RLock()
+
+	for _, u := range self.usages {
+		if u.minute.After(since) {
+			for emote, count := range u.counts {
+				totals[emote] += count
+			}
+		}
+	}
+
+	self.mutex.RUnlock()
+
+	result := make([]emoteFlat, 0, len(totals))
+
+	for emote, count := range totals {
+		result = append(result, emoteFlat{emote, count})
+	}
+
+	sort.Sort(emoteSorter(result))
+
+	if len(result) > max {
+		return result[:max]
+	}
+
+	return result
+}
+
+func (self *worker) refresher() {
+	defer close(self.stopped)
+
+	for {
+		emotes, err := self.sources.Emotes(self.channel)
+		if err != nil {
+			self.log.Warning("Could not fetch the BTTV/FFZ emotes for %s: %s", self.channel, err)
+		}
+
+		if emotes != nil {
+			self.mutex.Lock()
+			self.emotes = emotes
+			self.mutex.Unlock()
+		}
+
+		select {
+		case <-self.after(self.refresh):
+		case <-self.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+type worker struct {
+	plugin.NilWorker
+
+	channel bot.Channel
+	acl     *bot.ACL
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"change_throttle"}
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || !msg.IsGlobalCommand("throttle") {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "change_throttle") {
+		return
+	}
+
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		limit, interval := self.channel.Throttle()
+
+		if limit == 0 {
+			sender.Respond("commands are not throttled in this channel.")
+		} else {
+			sender.Respond("users can send " + describe(limit, interval) + ".")
+		}
+
+		return
+	}
+
+	if args[0] == "off" {
+		self.channel.SetThrottle(0, 0)
+		sender.Respond("commands are no longer throttled.")
+		return
+	}
+
+	if !msg.RequireArgs(2, "<commands> <seconds>|off", sender) {
+		return
+	}
+
+	limit, err := strconv.Atoi(args[0])
+	if err != nil || limit < 1 {
+		sender.Respond("the number of commands must be a positive number.")
+		return
+	}
+
+	seconds, err := strconv.Atoi(args[1])
+	if err != nil || seconds < 1 || seconds > 3600 {
+		sender.Respond("the interval must be between 1 and 3600 seconds.")
+		return
+	}
+
+	interval := time.Duration(seconds) * time.Second
+
+	self.channel.SetThrottle(limit, interval)
+	sender.Respond("users can now send " + describe(limit, interval) + "; moderators are not affected.")
+}
+
+func describe(limit int, interval time.Duration) string {
+	commands := "commands"
+	if limit == 1 {
+		commands = "command"
+	}
+
+	return "at most " + strconv.Itoa(limit) + " " + commands + " every " + strconv.Itoa(int(interval/time.Second)) + "s"
+}
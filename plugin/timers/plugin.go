@@ -0,0 +1,47 @@
+package timers
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+)
+
+type pluginStruct struct {
+	db    *sqlx.DB
+	log   bot.Logger
+	now   func() time.Time
+	after func(time.Duration) <-chan time.Time
+}
+
+func NewPlugin() *pluginStruct {
+	return NewPluginWithClock(time.Now, time.After)
+}
+
+// NewPluginWithClock lets the tests control the time.
+func NewPluginWithClock(now func() time.Time, after func(time.Duration) <-chan time.Time) *pluginStruct {
+	return &pluginStruct{now: now, after: after}
+}
+
+func (self *pluginStruct) Name() string {
+	return "timers"
+}
+
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.db = bot.Database()
+	self.log = bot.Logger()
+	bot.Commands().Register(self.Name(), commands...)
+}
+
+func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &worker{
+		channel:    channel.Name(),
+		acl:        channel.ACL(),
+		db:         self.db,
+		log:        self.log,
+		sender:     channel.Sender(),
+		now:        self.now,
+		after:      self.after,
+		resolution: 10 * time.Second,
+	}
+}
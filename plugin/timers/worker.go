@@ -0,0 +1,323 @@
+package timers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+var commands = []string{"timer_add", "timer_del", "timer_list"}
+
+var minInterval = 1 * time.Minute
+var maxInterval = 24 * time.Hour
+
+var timerName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+type timer struct {
+	Message  string
+	Interval time.Duration
+	MinLines int
+
+	lines      int // chat lines since the last post
+	lastPosted time.Time
+}
+
+type worker struct {
+	plugin.NilWorker
+
+	channel     string
+	acl         *bot.ACL
+	db          *sqlx.DB
+	log         bot.Logger
+	sender      bot.Sender
+	timers      map[string]*timer
+	mutex       sync.Mutex
+	ticking     chan struct{}
+	stopTicking chan struct{}
+	now         func() time.Time
+	after       func(time.Duration) <-chan time.Time
+	resolution  time.Duration // how often to check for due timers
+}
+
+type timerDbStruct struct {
+	Name     string
+	Message  string
+	Seconds  int
+	MinLines int `db:"min_lines"`
+}
+
+func (self *worker) Enable() {
+	list := make([]timerDbStruct, 0)
+	self.db.Select(&list, "SELECT name, message, seconds, min_lines FROM timers WHERE channel = ? ORDER BY name", self.channel)
+
+	now := self.now()
+
+	self.mutex.Lock()
+	self.timers = make(map[string]*timer)
+
+	for _, item := range list {
+		self.timers[item.Name] = &timer{
+			Message:    item.Message,
+			Interval:   time.Duration(item.Seconds) * time.Second,
+			MinLines:   item.MinLines,
+			lastPosted: now,
+		}
+	}
+
+	stop, ticking := make(chan struct{}), make(chan struct{})
+	self.stopTicking, self.ticking = stop, ticking
+	self.mutex.Unlock()
+
+	go self.ticker(stop, ticking)
+}
+
+// Disable stops the ticker; it does nothing if the ticker is not running, so
+// that the worker can safely be torn down more than once.
+func (self *worker) Disable() {
+	self.mutex.Lock()
+	stop, ticking := self.stopTicking, self.ticking
+	self.stopTicking = nil
+	self.mutex.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-ticking
+}
+
+// timers that still exist afterwards keep their progress
+func (self *worker) Reload() {
+	self.Disable()
+
+	previous := self.timers
+
+	self.Enable()
+
+	self.mutex.Lock()
+
+	for name, t := range self.timers {
+		old, existed := previous[name]
+		if existed {
+			t.lines = old.lines
+			t.lastPosted = old.lastPosted
+		}
+	}
+
+	self.mutex.Unlock()
+}
+
+func (self *worker) Permissions() []string {
+	return []string{"configure_timers"}
+}
+
+func (self *worker) Commands() []string {
+	return commands
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "configure_timers"
+}
+
+func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsFromBot() {
+		return
+	}
+
+	// every line counts towards the minimum number of lines between two posts
+	self.mutex.Lock()
+
+	for _, t := range self.timers {
+		t.lines++
+	}
+
+	self.mutex.Unlock()
+
+	if msg.IsProcessed() {
+		return
+	}
+
+	cmd := msg.Command()
+	if cmd != "timer_add" && cmd != "timer_del" && cmd != "timer_list" {
+		return
+	}
+
+	msg.SetProcessed()
+
+	if !self.acl.IsAllowed(msg.User, "configure_timers") {
+		return
+	}
+
+	args := msg.Arguments()
+
+	switch cmd {
+	case "timer_add":
+		self.addTimer(msg, sender)
+	case "timer_del":
+		self.deleteTimer(args, sender)
+	case "timer_list":
+		self.listTimers(sender)
+	}
+}
+
+func (self *worker) addTimer(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+	trigger := msg.Trigger()
+
+	if len(args) < 4 {
+		sender.Respond("usage: " + trigger + "timer_add <name> <interval> <min. lines> <message>, e.g. " + trigger + "timer_add socials 15m 5 Follow me on Twitter!")
+		return
+	}
+
+	name := strings.ToLower(args[0])
+	if !timerName.MatchString(name) {
+		sender.Respond("timer names can only consist of letters, numbers, dashes and underscores.")
+		return
+	}
+
+	interval := bot.ParseDuration(args[1], nil, nil)
+	if interval == nil || *interval < minInterval || *interval > maxInterval {
+		sender.Respond(fmt.Sprintf("invalid interval given. Expected a value between %s and %s, like 15m.", bot.FormatDuration(minInterval, false), bot.FormatDuration(maxInterval, false)))
+		return
+	}
+
+	lines, err := strconv.Atoi(args[2])
+	if err != nil || lines < 0 {
+		sender.Respond("the minimum number of chat lines must be a number >= 0.")
+		return
+	}
+
+	t := &timer{
+		Message:    msg.ArgumentText(3),
+		Interval:   time.Duration(interval.Seconds()) * time.Second,
+		MinLines:   lines,
+		lastPosted: self.now(),
+	}
+
+	self.mutex.Lock()
+	_, exists := self.timers[name]
+	self.mutex.Unlock()
+
+	if exists {
+		_, err = self.db.Exec("UPDATE timers SET message = ?, seconds = ?, min_lines = ? WHERE channel = ? AND name = ?", t.Message, int(t.Interval.Seconds()), t.MinLines, self.channel, name)
+	} else {
+		_, err = self.db.Exec("INSERT INTO timers (channel, name, message, seconds, min_lines) VALUES (?, ?, ?, ?, ?)", self.channel, name, t.Message, int(t.Interval.Seconds()), t.MinLines)
+	}
+
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not store timer: %s", err)
+		return
+	}
+
+	self.mutex.Lock()
+	self.timers[name] = t
+	self.mutex.Unlock()
+
+	if exists {
+		sender.Respond("timer " + name + " has been updated.")
+	} else {
+		sender.Respond("timer " + name + " has been added.")
+	}
+}
+
+func (self *worker) deleteTimer(args []string, sender bot.Sender) {
+	if len(args) == 0 {
+		sender.Respond("no timer name given.")
+		return
+	}
+
+	name := strings.ToLower(args[0])
+
+	self.mutex.Lock()
+	_, exists := self.timers[name]
+	self.mutex.Unlock()
+
+	if !exists {
+		sender.Respond("there is no timer named " + name + ".")
+		return
+	}
+
+	_, err := self.db.Exec("DELETE FROM timers WHERE channel = ? AND name = ?", self.channel, name)
+	if err != nil {
+		bot.DatabaseError(self.log, sender, "Could not delete timer: %s", err)
+		return
+	}
+
+	self.mutex.Lock()
+	delete(self.timers, name)
+	self.mutex.Unlock()
+
+	sender.Respond("timer " + name + " has been deleted.")
+}
+
+func (self *worker) listTimers(sender bot.Sender) {
+	self.mutex.Lock()
+
+	list := make([]string, 0, len(self.timers))
+
+	for _, name := range self.sortedNames() {
+		t := self.timers[name]
+		list = append(list, fmt.Sprintf("%s (every %s, %d lines)", name, bot.FormatDuration(t.Interval, false), t.MinLines))
+	}
+
+	self.mutex.Unlock()
+
+	if len(list) == 0 {
+		sender.Respond("there are no timers yet.")
+	} else {
+		sender.Respond("the following timers exist: " + bot.HumanJoin(list, ", "))
+	}
+}
+
+func (self *worker) ticker(stop chan struct{}, ticking chan struct{}) {
+	defer close(ticking)
+
+	for {
+		select {
+		case <-self.after(self.resolution):
+			self.tick(self.now())
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// tick posts all timers that are due at the given time
+func (self *worker) tick(now time.Time) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for _, name := range self.sortedNames() {
+		t := self.timers[name]
+
+		if now.Sub(t.lastPosted) >= t.Interval && t.lines >= t.MinLines {
+			self.sender.SendText(t.Message)
+
+			t.lastPosted = now
+			t.lines = 0
+		}
+	}
+}
+
+// must be called with the mutex being held
+func (self *worker) sortedNames() []string {
+	names := make([]string, 0, len(self.timers))
+
+	for name := range self.timers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -1,8 +1,9 @@
 package troll
 
 import (
+	"time"
+
 	"github.com/sgt-kabukiman/kabukibot/bot"
-	"github.com/sgt-kabukiman/kabukibot/plugin"
 )
 
 var trollResponses = map[string][]string{
@@ -59,8 +60,14 @@ var trollResponses = map[string][]string{
 	},
 }
 
+// 1-2-1 is just chatting, so pyramids need to be at least 3 high
+var pyramidHeight = bot.IntSetting(3, 3, 20)
+
+var pyramidTimeout = bot.DurationSetting(time.Minute, time.Second, 14*24*time.Hour)
+
 type pluginStruct struct {
-	plugin.BasePlugin
+	storage  *bot.Storage
+	settings *bot.Settings
 }
 
 func NewPlugin() *pluginStruct {
@@ -71,6 +78,19 @@ func (self *pluginStruct) Name() string {
 	return "troll"
 }
 
+func (self *pluginStruct) Setup(bot *bot.Kabukibot) {
+	self.storage = bot.Storage()
+	self.settings = bot.Settings()
+	bot.Commands().Register(self.Name(), "pyramids")
+	self.settings.Register(self.Name(), "pyramid_height", pyramidHeight)
+	self.settings.Register(self.Name(), "pyramid_timeout", pyramidTimeout)
+}
+
 func (self *pluginStruct) CreateWorker(channel bot.Channel) bot.PluginWorker {
-	return &worker{acl: channel.ACL()}
+	return &worker{
+		channel:  channel.Name(),
+		acl:      channel.ACL(),
+		storage:  self.storage,
+		settings: self.settings,
+	}
 }
@@ -0,0 +1,104 @@
+package troll
+
+import "strings"
+
+// pyramidDetector follows chat line by line and recognizes pyramids like
+//
+//	Kappa
+//	Kappa Kappa
+//	Kappa Kappa Kappa
+//	Kappa Kappa
+//	Kappa
+//
+// no matter whether they are built by one user or by many.
+type pyramidDetector struct {
+	brick      string
+	height     int
+	peak       int
+	descending bool
+	builders   []string
+}
+
+// feed checks the next chat line and returns the peak height and the builders
+// if it completed a pyramid of at least minHeight; otherwise it returns 0 and nil.
+func (self *pyramidDetector) feed(user string, text string, minHeight int) (int, []string) {
+	brick, height := pyramidLayer(text)
+
+	if height == 0 {
+		self.reset()
+		return 0, nil
+	}
+
+	if brick != self.brick {
+		self.reset()
+	}
+
+	switch {
+	case height == 1 && self.descending && self.height == 2:
+		self.grow(height, user)
+
+		peak := self.peak
+		builders := self.builders
+
+		self.reset()
+
+		return peak, builders
+
+	case height == 1:
+		self.start(brick, user)
+
+	case !self.descending && height == self.height+1:
+		self.grow(height, user)
+		self.peak = height
+
+	case height == self.height-1 && (self.descending || self.peak >= minHeight):
+		self.grow(height, user)
+		self.descending = true
+
+	default:
+		self.reset()
+	}
+
+	return 0, nil
+}
+
+func (self *pyramidDetector) start(brick string, user string) {
+	self.reset()
+	self.brick = brick
+	self.height = 1
+	self.peak = 1
+	self.builders = []string{user}
+}
+
+func (self *pyramidDetector) grow(height int, user string) {
+	self.height = height
+
+	for _, builder := range self.builders {
+		if builder == user {
+			return
+		}
+	}
+
+	self.builders = append(self.builders, user)
+}
+
+func (self *pyramidDetector) reset() {
+	*self = pyramidDetector{}
+}
+
+// pyramidLayer returns the repeated word and how often it was repeated, or
+// a height of 0 if the line is not made of one word only.
+func pyramidLayer(text string) (string, int) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return "", 0
+	}
+
+	for _, word := range words[1:] {
+		if word != words[0] {
+			return "", 0
+		}
+	}
+
+	return words[0], len(words)
+}
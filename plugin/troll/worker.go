@@ -1,29 +1,80 @@
 package troll
 
 import (
+	"fmt"
 	"math/rand"
+	"strings"
 
 	"github.com/sgt-kabukiman/kabukibot/bot"
 	"github.com/sgt-kabukiman/kabukibot/plugin"
 )
 
+// how the channel reacts to pyramids
+const (
+	pyramidsIgnored   = "off"
+	pyramidsAnnounced = "announce"
+	pyramidsPunished  = "punish"
+)
+
 type worker struct {
 	plugin.NilWorker
 
-	acl *bot.ACL
+	channel  string
+	acl      *bot.ACL
+	storage  *bot.Storage
+	settings *bot.Settings
+	pyramids string
+	detector pyramidDetector
+}
+
+func (self *worker) Enable() {
+	mode, exists := self.storage.Get("troll", self.channel, "pyramids")
+	if !exists {
+		mode = pyramidsIgnored
+	}
+
+	self.pyramids = mode
+	self.detector.reset()
 }
 
 func (self *worker) Permissions() []string {
-	return []string{"trolling"}
+	return []string{"trolling", "configure_pyramids"}
+}
+
+func (self *worker) Commands() []string {
+	return []string{"pyramids"}
+}
+
+func (self *worker) CommandPermission(command string) string {
+	return "configure_pyramids"
 }
 
 func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
-	if msg.IsProcessed() || msg.IsFromBot() {
+	if msg.IsFromBot() {
 		return
 	}
 
 	cmd := msg.Command()
+
 	if len(cmd) == 0 {
+		self.checkPyramid(msg, sender)
+		return
+	}
+
+	// commands interrupt pyramids just like any other line
+	self.detector.reset()
+
+	if msg.IsProcessed() {
+		return
+	}
+
+	if cmd == "pyramids" {
+		msg.SetProcessed()
+
+		if self.acl.IsAllowed(msg.User, "configure_pyramids") {
+			self.configurePyramids(msg, sender)
+		}
+
 		return
 	}
 
@@ -39,3 +90,72 @@ func (self *worker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
 
 	msg.SetProcessed()
 }
+
+func (self *worker) checkPyramid(msg *bot.TextMessage, sender bot.Sender) {
+	if self.pyramids == pyramidsIgnored {
+		return
+	}
+
+	minHeight := self.settings.Int(self.channel, "troll.pyramid_height")
+
+	height, builders := self.detector.feed(strings.ToLower(msg.User.Name), msg.Text, minHeight)
+	if height == 0 {
+		return
+	}
+
+	if self.pyramids == pyramidsPunished {
+		timeout := self.settings.Duration(self.channel, "troll.pyramid_timeout")
+
+		for _, builder := range builders {
+			sender.Timeout(builder, int(timeout.Seconds()), "No pyramids, please.")
+		}
+
+		sender.SendText("The pyramid has been demolished. No building without a permit!")
+		return
+	}
+
+	sender.SendText(fmt.Sprintf("Congratulations to %s for building a pyramid of height %d!", bot.HumanJoin(builders, ", "), height))
+}
+
+func (self *worker) configurePyramids(msg *bot.TextMessage, sender bot.Sender) {
+	args := msg.Arguments()
+
+	if len(args) == 0 {
+		switch self.pyramids {
+		case pyramidsAnnounced:
+			sender.Respond("pyramids are announced.")
+		case pyramidsPunished:
+			sender.Respond("pyramid builders are timed out.")
+		default:
+			sender.Respond("pyramids are ignored.")
+		}
+
+		return
+	}
+
+	mode := strings.ToLower(args[0])
+
+	if mode != pyramidsIgnored && mode != pyramidsAnnounced && mode != pyramidsPunished {
+		sender.Respond("usage: " + msg.Trigger() + "pyramids [off|announce|punish]")
+		return
+	}
+
+	err := self.storage.Set("troll", self.channel, "pyramids", mode)
+	if err != nil {
+		sender.Respond("the setting could not be saved, sorry.")
+		return
+	}
+
+	self.pyramids = mode
+	self.detector.reset()
+
+	switch mode {
+	case pyramidsAnnounced:
+		sender.Respond("pyramids will be announced from now on.")
+	case pyramidsPunished:
+		timeout := self.settings.Duration(self.channel, "troll.pyramid_timeout")
+		sender.Respond(fmt.Sprintf("pyramid builders will be timed out for %ds from now on.", int(timeout.Seconds())))
+	default:
+		sender.Respond("pyramids will be ignored from now on.")
+	}
+}
@@ -19,17 +19,28 @@ var db *sqlx.DB
 func init() {
 	var err error
 
-	// load configuration
-	config, err = bot.LoadConfiguration("config-test.yaml")
+	// load configuration; KABUKIBOT_TEST_CONFIG can point to another file,
+	// like config-test-sqlite.yaml
+	filename := os.Getenv("KABUKIBOT_TEST_CONFIG")
+	if filename == "" {
+		filename = "config-test.yaml"
+	}
+
+	config, err = bot.LoadConfiguration(filename)
 	if err != nil {
 		panic(err)
 	}
 
 	// connect to database
-	db, err = sqlx.Connect("mysql", config.Database.DSN)
+	db, err = sqlx.Connect(config.DatabaseDriver(), config.Database.DSN)
 	if err != nil {
 		panic(err)
 	}
+
+	// every connection to :memory: would get its own empty database
+	if config.DatabaseDriver() == "sqlite3" {
+		db.SetMaxOpenConns(1)
+	}
 }
 
 func runScript(t *testing.T, filename string) {
@@ -45,6 +56,70 @@ func runScript(t *testing.T, filename string) {
 	tester.Run(t)
 }
 
+func TestContext(t *testing.T) {
+	runScript(t, "bot/context.test")
+}
+
+func TestDeduplicate(t *testing.T) {
+	runScript(t, "bot/deduplicate.test")
+}
+
+func TestFilters(t *testing.T) {
+	runScript(t, "bot/filters.test")
+}
+
+func TestJoins(t *testing.T) {
+	runScript(t, "bot/joins.test")
+}
+
+func TestLeaks(t *testing.T) {
+	runScript(t, "bot/leaks.test")
+}
+
+func TestLifecycle(t *testing.T) {
+	runScript(t, "bot/lifecycle.test")
+}
+
+func TestListeners(t *testing.T) {
+	runScript(t, "bot/listeners.test")
+}
+
+func TestMetrics(t *testing.T) {
+	runScript(t, "bot/metrics.test")
+}
+
+func TestMigrations(t *testing.T) {
+	runScript(t, "bot/migrations.test")
+}
+
+func TestMode(t *testing.T) {
+	runScript(t, "bot/mode.test")
+}
+
+func TestRatelimit(t *testing.T) {
+	runScript(t, "bot/ratelimit.test")
+}
+
+func TestShutdown(t *testing.T) {
+	runScript(t, "bot/shutdown.test")
+}
+
+func TestStorage(t *testing.T) {
+	runScript(t, "bot/storage.test")
+}
+
+func TestTags(t *testing.T) {
+	runScript(t, "bot/tags.test")
+}
+
+func TestTester(t *testing.T) {
+	runScript(t, "bot/tester.test")
+}
+
+func TestWhispers(t *testing.T) {
+	runScript(t, "bot/whispers.test")
+}
+
 func TestAclAllow(t *testing.T) {
 	runScript(t, "plugin/acl/allow.test")
 }
@@ -57,10 +132,50 @@ func TestAclDeny(t *testing.T) {
 	runScript(t, "plugin/acl/deny.test")
 }
 
+func TestAclGroups(t *testing.T) {
+	runScript(t, "plugin/acl/groups.test")
+}
+
 func TestAclPermissions(t *testing.T) {
 	runScript(t, "plugin/acl/permissions.test")
 }
 
+func TestAclRoster(t *testing.T) {
+	runScript(t, "plugin/acl/roster.test")
+}
+
+func TestAclTemporary(t *testing.T) {
+	runScript(t, "plugin/acl/temporary.test")
+}
+
+func TestAclVips(t *testing.T) {
+	runScript(t, "plugin/acl/vips.test")
+}
+
+func TestAclWildcard(t *testing.T) {
+	runScript(t, "plugin/acl/wildcard.test")
+}
+
+func TestAliasAlias(t *testing.T) {
+	runScript(t, "plugin/alias/alias.test")
+}
+
+func TestBanhammerBotClear(t *testing.T) {
+	runScript(t, "plugin/banhammer_bot/clear.test")
+}
+
+func TestBanphraseBanphrase(t *testing.T) {
+	runScript(t, "plugin/banphrase/banphrase.test")
+}
+
+func TestBanphraseFuzzy(t *testing.T) {
+	runScript(t, "plugin/banphrase/fuzzy.test")
+}
+
+func TestBanphrasePropagation(t *testing.T) {
+	runScript(t, "plugin/banphrase/propagation.test")
+}
+
 func TestBlacklistBasicCommands(t *testing.T) {
 	runScript(t, "plugin/blacklist/basic-commands.test")
 }
@@ -69,6 +184,30 @@ func TestBlacklistBasicFunctionality(t *testing.T) {
 	runScript(t, "plugin/blacklist/basic-functionality.test")
 }
 
+func TestBlacklistPriority(t *testing.T) {
+	runScript(t, "plugin/blacklist/priority.test")
+}
+
+func TestCapsFilterCaps(t *testing.T) {
+	runScript(t, "plugin/caps_filter/caps.test")
+}
+
+func TestChannelInfoChannelInfo(t *testing.T) {
+	runScript(t, "plugin/channel_info/channel_info.test")
+}
+
+func TestCommandStatsPriority(t *testing.T) {
+	runScript(t, "plugin/command_stats/priority.test")
+}
+
+func TestCommandStatsStats(t *testing.T) {
+	runScript(t, "plugin/command_stats/stats.test")
+}
+
+func TestContentCollision(t *testing.T) {
+	runScript(t, "plugin/content/collision.test")
+}
+
 func TestContentDefine(t *testing.T) {
 	runScript(t, "plugin/content/define.test")
 }
@@ -81,26 +220,86 @@ func TestCustomCommandsAcl(t *testing.T) {
 	runScript(t, "plugin/custom_commands/acl.test")
 }
 
+func TestCustomCommandsAlias(t *testing.T) {
+	runScript(t, "plugin/custom_commands/alias.test")
+}
+
+func TestCustomCommandsCollision(t *testing.T) {
+	runScript(t, "plugin/custom_commands/collision.test")
+}
+
+func TestCustomCommandsCooldown(t *testing.T) {
+	runScript(t, "plugin/custom_commands/cooldown.test")
+}
+
+func TestCustomCommandsCounter(t *testing.T) {
+	runScript(t, "plugin/custom_commands/counter.test")
+}
+
 func TestCustomCommandsCreate(t *testing.T) {
 	runScript(t, "plugin/custom_commands/create.test")
 }
 
+func TestCustomCommandsDatabase(t *testing.T) {
+	runScript(t, "plugin/custom_commands/database.test")
+}
+
 func TestCustomCommandsDelete(t *testing.T) {
 	runScript(t, "plugin/custom_commands/delete.test")
 }
 
+func TestCustomCommandsDependencies(t *testing.T) {
+	runScript(t, "plugin/custom_commands/dependencies.test")
+}
+
 func TestCustomCommandsGet(t *testing.T) {
 	runScript(t, "plugin/custom_commands/get.test")
 }
 
+func TestCustomCommandsGroup(t *testing.T) {
+	runScript(t, "plugin/custom_commands/group.test")
+}
+
+func TestCustomCommandsInterpolate(t *testing.T) {
+	runScript(t, "plugin/custom_commands/interpolate.test")
+}
+
 func TestCustomCommandsList(t *testing.T) {
 	runScript(t, "plugin/custom_commands/list.test")
 }
 
+func TestCustomCommandsPages(t *testing.T) {
+	runScript(t, "plugin/custom_commands/pages.test")
+}
+
+func TestCustomCommandsQuoting(t *testing.T) {
+	runScript(t, "plugin/custom_commands/quoting.test")
+}
+
+func TestCustomCommandsRename(t *testing.T) {
+	runScript(t, "plugin/custom_commands/rename.test")
+}
+
+func TestCustomCommandsResponses(t *testing.T) {
+	runScript(t, "plugin/custom_commands/responses.test")
+}
+
+func TestCustomCommandsRouting(t *testing.T) {
+	runScript(t, "plugin/custom_commands/routing.test")
+}
+
 func TestCustomCommandsUpdate(t *testing.T) {
 	runScript(t, "plugin/custom_commands/update.test")
 }
 
+func TestCustomCommandsUsage(t *testing.T) {
+	runScript(t, "plugin/custom_commands/usage.test")
+}
+
+func TestCustomCommandsWarn(t *testing.T) {
+	runScript(t, "plugin/custom_commands/warn.test")
+}
+
 func TestDictionaryGet(t *testing.T) {
 	runScript(t, "plugin/dictionary/get.test")
 }
@@ -129,10 +328,62 @@ func TestDomainBanUnban(t *testing.T) {
 	runScript(t, "plugin/domain_ban/unban.test")
 }
 
+func TestDryRunDryRun(t *testing.T) {
+	runScript(t, "plugin/dry_run/dry_run.test")
+}
+
+func TestEchoAction(t *testing.T) {
+	runScript(t, "plugin/echo/action.test")
+}
+
 func TestEchoEcho(t *testing.T) {
 	runScript(t, "plugin/echo/echo.test")
 }
 
+func TestEchoEnvironment(t *testing.T) {
+	runScript(t, "plugin/echo/environment.test")
+}
+
+func TestEchoQuoting(t *testing.T) {
+	runScript(t, "plugin/echo/quoting.test")
+}
+
+func TestEchoReload(t *testing.T) {
+	runScript(t, "plugin/echo/reload.test")
+}
+
+func TestEchoSplit(t *testing.T) {
+	runScript(t, "plugin/echo/split.test")
+}
+
+func TestEchoValidation(t *testing.T) {
+	runScript(t, "plugin/echo/validation.test")
+}
+
+func TestEchoWhisper(t *testing.T) {
+	runScript(t, "plugin/echo/whisper.test")
+}
+
+func TestEmoteCounterEmotes(t *testing.T) {
+	runScript(t, "plugin/emote_counter/emotes.test")
+}
+
+func TestGreeterGreeter(t *testing.T) {
+	runScript(t, "plugin/greeter/greeter.test")
+}
+
+func TestHelpCommands(t *testing.T) {
+	runScript(t, "plugin/help/commands.test")
+}
+
+func TestHelpDescribe(t *testing.T) {
+	runScript(t, "plugin/help/describe.test")
+}
+
+func TestHelpPlugins(t *testing.T) {
+	runScript(t, "plugin/help/plugins.test")
+}
+
 func TestJoinJoin(t *testing.T) {
 	runScript(t, "plugin/join/join.test")
 }
@@ -141,10 +392,146 @@ func TestJoinLeave(t *testing.T) {
 	runScript(t, "plugin/join/leave.test")
 }
 
+func TestJoinRestart(t *testing.T) {
+	runScript(t, "plugin/join/restart.test")
+}
+
+func TestLinkProtectionLinks(t *testing.T) {
+	runScript(t, "plugin/link_protection/links.test")
+}
+
+func TestMarkersMarkers(t *testing.T) {
+	runScript(t, "plugin/markers/markers.test")
+}
+
+func TestModLogModLog(t *testing.T) {
+	runScript(t, "plugin/mod_log/mod_log.test")
+}
+
+func TestNukeNuke(t *testing.T) {
+	runScript(t, "plugin/nuke/nuke.test")
+}
+
 func TestPingPing(t *testing.T) {
 	runScript(t, "plugin/ping/ping.test")
 }
 
+func TestPingReconnect(t *testing.T) {
+	runScript(t, "plugin/ping/reconnect.test")
+}
+
+func TestPingWhisper(t *testing.T) {
+	runScript(t, "plugin/ping/whisper.test")
+}
+
+func TestPluginControlCommands(t *testing.T) {
+	runScript(t, "plugin/plugin_control/commands.test")
+}
+
+func TestPluginControlList(t *testing.T) {
+	runScript(t, "plugin/plugin_control/list.test")
+}
+
+func TestPluginControlReload(t *testing.T) {
+	runScript(t, "plugin/plugin_control/reload.test")
+}
+
+func TestPluginControlToggle(t *testing.T) {
+	runScript(t, "plugin/plugin_control/toggle.test")
+}
+
+func TestPointsGamble(t *testing.T) {
+	runScript(t, "plugin/points/gamble.test")
+}
+
+func TestPointsPoints(t *testing.T) {
+	runScript(t, "plugin/points/points.test")
+}
+
+func TestPollPoll(t *testing.T) {
+	runScript(t, "plugin/poll/poll.test")
+}
+
+func TestPrefixPrefix(t *testing.T) {
+	runScript(t, "plugin/prefix/prefix.test")
+}
+
+func TestQuotesPages(t *testing.T) {
+	runScript(t, "plugin/quotes/pages.test")
+}
+
+func TestQuotesQuotes(t *testing.T) {
+	runScript(t, "plugin/quotes/quotes.test")
+}
+
+func TestRawRaw(t *testing.T) {
+	runScript(t, "plugin/raw/raw.test")
+}
+
+func TestRepeatFilterRepeat(t *testing.T) {
+	runScript(t, "plugin/repeat_filter/repeat.test")
+}
+
+func TestRoomModesModes(t *testing.T) {
+	runScript(t, "plugin/room_modes/modes.test")
+}
+
+func TestSeenSeen(t *testing.T) {
+	runScript(t, "plugin/seen/seen.test")
+}
+
+func TestSettingsSettings(t *testing.T) {
+	runScript(t, "plugin/settings/settings.test")
+}
+
+func TestShoutoutQueue(t *testing.T) {
+	runScript(t, "plugin/shoutout/queue.test")
+}
+
+func TestShoutoutRaid(t *testing.T) {
+	runScript(t, "plugin/shoutout/raid.test")
+}
+
+func TestShoutoutShoutout(t *testing.T) {
+	runScript(t, "plugin/shoutout/shoutout.test")
+}
+
+func TestStreamInfoAccountage(t *testing.T) {
+	runScript(t, "plugin/stream_info/accountage.test")
+}
+
+func TestStreamInfoStreamInfo(t *testing.T) {
+	runScript(t, "plugin/stream_info/stream_info.test")
+}
+
+func TestSubhypeSubscription(t *testing.T) {
+	runScript(t, "plugin/subhype/subscription.test")
+}
+
+func TestSysinfoHealth(t *testing.T) {
+	runScript(t, "plugin/sysinfo/health.test")
+}
+
+func TestThirdPartyEmotesThirdPartyEmotes(t *testing.T) {
+	runScript(t, "plugin/third_party_emotes/third_party_emotes.test")
+}
+
+func TestThrottleThrottle(t *testing.T) {
+	runScript(t, "plugin/throttle/throttle.test")
+}
+
+func TestTimersCommands(t *testing.T) {
+	runScript(t, "plugin/timers/commands.test")
+}
+
+func TestTimersPosting(t *testing.T) {
+	runScript(t, "plugin/timers/posting.test")
+}
+
 func TestTrollCommands(t *testing.T) {
 	runScript(t, "plugin/troll/commands.test")
 }
+
+func TestTrollPyramid(t *testing.T) {
+	runScript(t, "plugin/troll/pyramid.test")
+}
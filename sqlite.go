@@ -0,0 +1,8 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+// The SQLite driver needs cgo, so it is only compiled in when building with
+// `-tags sqlite`. This is mostly useful to run the tests without MySQL.
+import _ "github.com/mattn/go-sqlite3"
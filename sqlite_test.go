@@ -0,0 +1,16 @@
+//go:build sqlite
+// +build sqlite
+
+package main
+
+import "testing"
+
+// TestSQLiteSmoke makes sure the test scripts work without a MySQL server.
+// Run it via `make sqlitetest`.
+func TestSQLiteSmoke(t *testing.T) {
+	if db.DriverName() != "sqlite3" {
+		t.Skip("not running against SQLite; set KABUKIBOT_TEST_CONFIG=config-test-sqlite.yaml")
+	}
+
+	runScript(t, "plugin/quotes/quotes.test")
+}
@@ -1,6 +1,8 @@
 package test
 
 import (
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/sgt-kabukiman/kabukibot/twitch"
@@ -10,15 +12,51 @@ type fakeClient struct {
 	incoming chan twitch.IncomingMessage
 	outgoing chan twitch.OutgoingMessage
 	ready    chan struct{}
+	lost     chan struct{}
+
+	// the bot parts all channels when shutting down, so the echoed PARTs
+	// must not be sent after disconnecting
+	disconnected bool
+	joins        int // how many JOINs have been sent
+	mutex        sync.Mutex
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		incoming: make(chan twitch.IncomingMessage),
+		outgoing: make(chan twitch.OutgoingMessage, 10),
+		ready:    make(chan struct{}),
+		lost:     make(chan struct{}, 1),
+	}
 }
 
 func (c *fakeClient) Connect() error {
-	close(c.ready)
+	c.mutex.Lock()
+	disconnected := c.disconnected
+	c.mutex.Unlock()
+
+	// like the real client, there is no way back after disconnecting
+	if disconnected {
+		return errors.New("the client has been disconnected for good")
+	}
+
+	// when reconnecting, we are still ready from the first time
+	select {
+	case <-c.ready:
+	default:
+		close(c.ready)
+	}
+
 	return nil
 }
 
 func (c *fakeClient) Disconnect() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.disconnected = true
 	close(c.incoming)
+
 	return nil
 }
 
@@ -30,6 +68,10 @@ func (c *fakeClient) Ready() <-chan struct{} {
 	return c.ready
 }
 
+func (c *fakeClient) ConnectionLost() <-chan struct{} {
+	return c.lost
+}
+
 func (c *fakeClient) QueueLen() int {
 	return 0
 }
@@ -42,9 +84,20 @@ func (c *fakeClient) MessagesReceived() uint64 {
 	return 0
 }
 
+func (c *fakeClient) Joins() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.joins
+}
+
 func (c *fakeClient) Send(msg twitch.OutgoingMessage) <-chan bool {
 	asserted, okay := msg.(twitch.JoinMessage)
 	if okay {
+		c.mutex.Lock()
+		c.joins++
+		c.mutex.Unlock()
+
 		// respond to a JOIN with a JOIN
 		c.incoming <- asserted
 	} else {
@@ -55,7 +108,13 @@ func (c *fakeClient) Send(msg twitch.OutgoingMessage) <-chan bool {
 			// a chance to process the "i sent the PART request" event.
 			go func() {
 				<-time.After(100 * time.Millisecond)
-				c.incoming <- asserted2
+
+				c.mutex.Lock()
+				defer c.mutex.Unlock()
+
+				if !c.disconnected {
+					c.incoming <- asserted2
+				}
 			}()
 		} else {
 			// send all other messages
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock only moves when the script tells it to.
+type fakeClock struct {
+	now    time.Time
+	timers []fakeTimer
+	mutex  sync.Mutex
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	fire     chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2016, time.January, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// After works like time.After, but fires when the clock is advanced far enough.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fire := make(chan time.Time, 1)
+
+	if d <= 0 {
+		fire <- c.now
+	} else {
+		c.timers = append(c.timers, fakeTimer{c.now.Add(d), fire})
+	}
+
+	return fire
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+
+	pending := c.timers[:0]
+
+	for _, timer := range c.timers {
+		if timer.deadline.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.fire <- c.now
+		}
+	}
+
+	c.timers = pending
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// faultyPlugin has a bug in its cleanup code: its workers panic when being
+// disabled. It lets scripts check how the bot copes with that.
+type faultyPlugin struct {
+	plugin.BasePlugin
+}
+
+func NewFaultyPlugin() bot.Plugin {
+	return &faultyPlugin{}
+}
+
+func (self *faultyPlugin) Name() string {
+	return "faulty"
+}
+
+func (self *faultyPlugin) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &faultyWorker{}
+}
+
+type faultyWorker struct {
+	plugin.NilWorker
+}
+
+func (self *faultyWorker) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+}
+
+func (self *faultyWorker) Disable() {
+	panic("forgot to check for nil")
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// filterPlugin registers two outbound filters: the first one redacts a password,
+// the second one drops spoilers in #chan and, if it ever saw the password, would
+// drop that as well. Scripts use it to check the order of the pipeline. It also
+// turns Cyrillic a's into Latin ones on the way in, and !texts shows both the
+// raw and the normalized text of a message.
+type filterPlugin struct {
+	plugin.BasePlugin
+	plugin.NilWorker
+}
+
+func NewFilterPlugin() bot.Plugin {
+	return &filterPlugin{}
+}
+
+func (self *filterPlugin) Name() string {
+	return "filters"
+}
+
+func (self *filterPlugin) Setup(bot *bot.Kabukibot) {
+	bot.AddOutboundFilter(func(channel string, text string) (string, bool) {
+		return strings.Replace(text, "hunter2", "*******", -1), true
+	})
+
+	bot.AddOutboundFilter(func(channel string, text string) (string, bool) {
+		if strings.Contains(text, "hunter2") {
+			return "", false
+		}
+
+		return text, channel != "#chan" || !strings.Contains(text, "spoiler")
+	})
+
+	bot.AddInboundFilter(func(channel string, text string) string {
+		return strings.Replace(text, "\u0430", "a", -1)
+	})
+}
+
+func (self *filterPlugin) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return self
+}
+
+func (self *filterPlugin) HandleTextMessage(msg *bot.TextMessage, sender bot.Sender) {
+	if msg.IsProcessed() || msg.Command() != "texts" {
+		return
+	}
+
+	msg.SetProcessed()
+	sender.Respond(fmt.Sprintf("raw %q, normalized %q", msg.Raw(), msg.Normalized()))
+}
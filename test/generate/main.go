@@ -14,10 +14,29 @@ type testcase struct {
 }
 
 func main() {
-	root := "plugin"
 	exploder := regexp.MustCompile(`[^a-z0-9]`)
 	testcases := make([]testcase, 0)
 
+	// function names are derived from the path relative to the root
+	for _, root := range []string{"bot", "plugin"} {
+		walk(root, exploder, &testcases)
+	}
+
+	filename := "plugin_test.go"
+	fp, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0660)
+	if err != nil {
+		panic(err)
+	}
+
+	cTemplate, _ := template.ParseFiles("test/generate/testfile.got")
+	cTemplate.Execute(fp, map[string]interface{}{
+		"testcases": testcases,
+	})
+
+	fp.Close()
+}
+
+func walk(root string, exploder *regexp.Regexp, testcases *[]testcase) {
 	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil || !strings.HasSuffix(path, ".test") {
 			return nil
@@ -33,24 +52,11 @@ func main() {
 			parts[i] = strings.ToUpper(string(parts[i][0])) + parts[i][1:]
 		}
 
-		testcases = append(testcases, testcase{
+		*testcases = append(*testcases, testcase{
 			Filename: root + "/" + rel,
 			Function: strings.Join(parts, ""),
 		})
 
 		return nil
 	})
-
-	filename := root + "/../plugin_test.go"
-	fp, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0660)
-	if err != nil {
-		panic(err)
-	}
-
-	cTemplate, _ := template.ParseFiles("test/generate/testfile.got")
-	cTemplate.Execute(fp, map[string]interface{}{
-		"testcases": testcases,
-	})
-
-	fp.Close()
 }
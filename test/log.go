@@ -1,11 +1,33 @@
 package test
 
-type fakeLog struct{}
-
-func (f *fakeLog) SetLevel(int)                   {}
-func (f *fakeLog) Debug(string, ...interface{})   {}
-func (f *fakeLog) Info(string, ...interface{})    {}
-func (f *fakeLog) Warning(string, ...interface{}) {}
-func (f *fakeLog) Warn(string, ...interface{})    {}
-func (f *fakeLog) Error(string, ...interface{})   {}
-func (f *fakeLog) Fatal(string, ...interface{})   {}
+import (
+	"fmt"
+	"sync"
+)
+
+// fakeLog remembers everything that was logged, so that scripts can check for it
+type fakeLog struct {
+	messages []string
+	mutex    sync.Mutex
+}
+
+func (f *fakeLog) record(format string, args []interface{}) {
+	f.mutex.Lock()
+	f.messages = append(f.messages, fmt.Sprintf(format, args...))
+	f.mutex.Unlock()
+}
+
+func (f *fakeLog) logged() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, f.messages...)
+}
+
+func (f *fakeLog) SetLevel(int)                       {}
+func (f *fakeLog) Debug(string, ...interface{})       {}
+func (f *fakeLog) Info(s string, a ...interface{})    { f.record(s, a) }
+func (f *fakeLog) Warning(s string, a ...interface{}) { f.record(s, a) }
+func (f *fakeLog) Warn(s string, a ...interface{})    { f.record(s, a) }
+func (f *fakeLog) Error(s string, a ...interface{})   { f.record(s, a) }
+func (f *fakeLog) Fatal(s string, a ...interface{})   { f.record(s, a) }
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"sync"
+
+	"github.com/sgt-kabukiman/kabukibot/bot"
+	"github.com/sgt-kabukiman/kabukibot/plugin"
+)
+
+// spyPlugin does nothing but remember how its workers were started and
+// stopped, so that scripts can check the order of the lifecycle calls.
+type spyPlugin struct {
+	plugin.BasePlugin
+
+	calls map[string][]string // per channel
+	mutex sync.Mutex
+}
+
+// NewSpyPlugin returns the plugin the lifecycle command checks; it is shared by
+// all bots of a script, so calls before and after a restart are recorded.
+func (test *Tester) NewSpyPlugin() bot.Plugin {
+	test.spyMutex.Lock()
+	defer test.spyMutex.Unlock()
+
+	if test.spy == nil {
+		test.spy = &spyPlugin{calls: make(map[string][]string)}
+	}
+
+	return test.spy
+}
+
+func (self *spyPlugin) Name() string {
+	return "spy"
+}
+
+func (self *spyPlugin) CreateWorker(channel bot.Channel) bot.PluginWorker {
+	return &spyWorker{plugin: self, channel: channel.Name()}
+}
+
+func (self *spyPlugin) record(channel string, call string) {
+	self.mutex.Lock()
+	self.calls[channel] = append(self.calls[channel], call)
+	self.mutex.Unlock()
+}
+
+// recorded returns the calls since the last time and forgets about them
+func (self *spyPlugin) recorded(channel string) []string {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	calls := self.calls[channel]
+	delete(self.calls, channel)
+
+	return calls
+}
+
+type spyWorker struct {
+	plugin.NilWorker
+
+	plugin  *spyPlugin
+	channel string
+}
+
+func (self *spyWorker) Enable() {
+	self.plugin.record(self.channel, "enable")
+}
+
+func (self *spyWorker) Disable() {
+	self.plugin.record(self.channel, "disable")
+}
+
+func (self *spyWorker) Part() {
+	self.plugin.record(self.channel, "part")
+}
+
+func (self *spyWorker) Shutdown() {
+	self.plugin.record(self.channel, "shutdown")
+}
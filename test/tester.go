@@ -2,9 +2,21 @@ package test
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,6 +32,21 @@ type Tester struct {
 	config         *bot.Configuration
 	db             *sqlx.DB
 	pluginBuilders map[string]pluginBuilder
+	plugins        []string
+	failed         bool // whether the bot failed to connect on purpose
+	cleanups       []func()
+	api            *httptest.Server
+	apiResponses   map[string]string
+	apiRequests    map[string]string // the last body sent to "METHOD /path?query"
+	apiMutex       sync.Mutex
+	clock          *fakeClock
+	random         []int
+	tags           twitch.Tags // for the next injected message
+	randomMutex    sync.Mutex
+	spy            *spyPlugin
+	spyMutex       sync.Mutex
+	cancel         context.CancelFunc // stops the running bot
+	stopped        chan struct{}      // closed when the running bot has stopped
 }
 
 func NewTester(file io.Reader, config *bot.Configuration, db *sqlx.DB) *Tester {
@@ -28,35 +55,100 @@ func NewTester(file io.Reader, config *bot.Configuration, db *sqlx.DB) *Tester {
 		config:         config,
 		db:             db,
 		pluginBuilders: make(map[string]pluginBuilder),
+		clock:          newFakeClock(),
 	}
 }
 
+// Now can be given to plugins instead of time.Now, so that scripts control the
+// time using the clock command.
+func (test *Tester) Now() time.Time {
+	return test.clock.Now()
+}
+
+// After works like time.After, but fires once a script has moved the clock far
+// enough.
+func (test *Tester) After(d time.Duration) <-chan time.Time {
+	return test.clock.After(d)
+}
+
+// HTTPClient can be given to plugins talking to other APIs than Twitch's. All
+// of its requests end up at the fake API, so scripts can stub them using api
+// (the host is ignored, only the path and query matter).
+func (test *Tester) HTTPClient() *http.Client {
+	return &http.Client{Transport: fakeTransport{test}}
+}
+
+type fakeTransport struct {
+	test *Tester
+}
+
+func (self fakeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	self.test.apiMutex.Lock()
+	api := self.test.api
+	self.test.apiMutex.Unlock()
+
+	if api == nil {
+		return nil, errors.New("the script has not set up any API responses")
+	}
+
+	target, err := url.Parse(api.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	redirected := request.Clone(request.Context())
+	redirected.URL.Scheme = target.Scheme
+	redirected.URL.Host = target.Host
+	redirected.Host = ""
+
+	return http.DefaultTransport.RoundTrip(redirected)
+}
+
 func (test *Tester) AddPlugin(name string, builder pluginBuilder) {
 	test.pluginBuilders[name] = builder
 }
 
-var injectedMessage = regexp.MustCompile(`< \[(#[a-z0-9_]+)\] ([$%&@!~+]*[a-z0-9_]+): (.+)$`)
-var expectedMessage = regexp.MustCompile(`> \[(#[a-z0-9_]+)\] ([$%&@!~+]*[a-z0-9_]+): (.+)$`)
+var injectedMessage = regexp.MustCompile(`< \[([#@][a-z0-9_]+)\] ([$%&@!~+]*[a-z0-9_]+): (.+)$`)
+var injectedSubscription = regexp.MustCompile(`^sub \[(#[a-z0-9_]+)\] ([a-z0-9_]+) ([0-9]+) ([a-zA-Z0-9]+)(?: (.+))?$`)
+var injectedMode = regexp.MustCompile(`^mode \[(#[a-z0-9_]+)\] ([+-])o ([a-z0-9_]+)$`)
+var expectedMessage = regexp.MustCompile(`> \[([#@][a-z0-9_]+)\] ([$%&@!~+]*[a-z0-9_]+): (.+)$`)
 
 func (test *Tester) WipeDatabase() {
-	rows, _ := test.db.Queryx("SHOW TABLES")
-	for rows.Next() {
-		var table string
-		rows.Scan(&table)
+	for _, table := range test.tables() {
+		// keep the migrations, the tables are still there after all
+		if table != "schema_migrations" {
+			test.db.MustExec("DELETE FROM `" + table + "` WHERE 1")
+		}
+	}
+}
 
-		test.db.MustExec("DELETE FROM `" + table + "` WHERE 1")
+func (test *Tester) tables() []string {
+	query := "SHOW TABLES"
+	if test.db.DriverName() == "sqlite3" {
+		query = "SELECT name FROM sqlite_master WHERE type = 'table'"
 	}
+
+	// collect the tables first, SQLite only has a single connection to work with
+	tables := make([]string, 0)
+	test.db.Select(&tables, query)
+
+	return tables
 }
 
 func (test *Tester) Run(t *testing.T) {
 	log := &fakeLog{}
-	tc := &fakeClient{
-		incoming: make(chan twitch.IncomingMessage),
-		outgoing: make(chan twitch.OutgoingMessage, 10),
-		ready:    make(chan struct{}),
+	tc := newFakeClient()
+
+	// scripts may change the configuration (e.g. to point to a fake API)
+	config := *test.config
+
+	testBot, err := bot.NewKabukibot(tc, log, test.db, &config)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	testBot, _ := bot.NewKabukibot(tc, log, test.db, test.config)
+	testBot.SetClock(test.clock.Now)
+	testBot.SetPacingClock(test.clock.Now, test.clock.After)
 
 	lineNr := 0
 	lastLine := ""
@@ -76,24 +168,96 @@ func (test *Tester) Run(t *testing.T) {
 		case "plugin":
 			test.pluginCommand(t, testBot, lineNr, parts[1:])
 		case "connect":
-			test.connectCommand(t, testBot, lineNr)
+			test.connectCommand(t, testBot, lineNr, parts[1:])
 		case "join":
 			test.joinCommand(t, testBot, lineNr, parts[1:])
+		case "part":
+			test.partCommand(t, testBot, lineNr, parts[1:])
+		case "break":
+			test.breakCommand(t, testBot, lineNr, parts[1:])
+		case "migrate":
+			test.migrateCommand(t, lineNr, parts[1:])
+		case "storage":
+			test.storageCommand(t, testBot, lineNr, parts[1:])
+		case "sql":
+			test.sqlCommand(t, lineNr, parts[1:])
+		case "metrics":
+			test.metricsCommand(t, testBot, lineNr, parts[1:])
+		case "log":
+			test.logCommand(t, log, lineNr, parts[1:])
+		case "api":
+			test.apiCommand(t, &config, lineNr, parts[1:])
+		case "requested":
+			test.requestedCommand(t, lineNr, parts[1:])
+		case "env":
+			test.envCommand(t, testBot, lineNr, parts[1:])
+		case "reload":
+			test.reloadCommand(t, testBot, lineNr, parts[1:])
+		case "restart":
+			// start over with a fresh bot (and its plugins) on the same database
+			testBot.Shutdown()
+
+			tc = newFakeClient()
+			testBot, _ = bot.NewKabukibot(tc, log, test.db, &config)
+			testBot.SetClock(test.clock.Now)
+			testBot.SetPacingClock(test.clock.Now, test.clock.After)
+
+			for _, plugin := range test.plugins {
+				testBot.AddPlugin(test.pluginBuilders[plugin]())
+			}
+		case "disconnect":
+			test.disconnectCommand(t, testBot, lineNr, tc)
+		case "shutdown":
+			test.shutdownCommand(t, lineNr)
 		case "wait":
 			test.waitCommand(t, testBot, lineNr, parts[1:])
+		case "clock":
+			test.clockCommand(t, lineNr, parts[1:])
+		case "random":
+			test.randomCommand(t, lineNr, parts[1:])
+		case "tags":
+			test.tagsCommand(t, lineNr, parts[1:])
 		case "<":
 			test.sendCommand(t, testBot, lineNr, line, tc)
+		case "sub":
+			test.subCommand(t, testBot, lineNr, line, tc)
+		case "mode":
+			test.modeCommand(t, lineNr, line, tc)
+		case "raw":
+			test.rawCommand(t, log, lineNr, parts[1:], tc)
+		case "sent":
+			test.sentCommand(t, lineNr, parts[1:], tc)
+		case "joins":
+			test.joinsCommand(t, lineNr, parts[1:], tc)
+		case "moderator":
+			test.moderatorCommand(t, testBot, lineNr, parts[1:])
+		case "listeners":
+			test.listenersCommand(t, testBot, lineNr, parts[1:])
+		case "lifecycle":
+			test.lifecycleCommand(t, lineNr, parts[1:])
 		case ">":
 			test.receiveCommand(t, testBot, lineNr, line, tc)
 		case "silence":
 			test.silenceCommand(t, testBot, lineNr, lastLine, tc)
+		case "whisper":
+			test.whisperCommand(t, lineNr, line, parts[1:], tc)
+		case "timeout":
+			test.moderationCommand(t, lineNr, line, ".timeout", parts[1:], tc)
+		case "ban":
+			test.moderationCommand(t, lineNr, line, ".ban", parts[1:], tc)
 		}
 
 		lastLine = line
 	}
 
-	// shutdown
-	testBot.Shutdown()
+	for _, cleanup := range test.cleanups {
+		cleanup()
+	}
+
+	// shutdown; if connecting failed, the bot never started working
+	if !test.failed {
+		testBot.Shutdown()
+	}
 }
 
 func (test *Tester) pluginCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
@@ -105,25 +269,498 @@ func (test *Tester) pluginCommand(t *testing.T, bot *bot.Kabukibot, lineNr int,
 	}
 
 	bot.AddPlugin(builder())
+	test.plugins = append(test.plugins, plugin)
 }
 
-func (test *Tester) connectCommand(t *testing.T, bot *bot.Kabukibot, lineNr int) {
-	err := bot.Connect()
+// "connect fails: <regex>" expects connecting to fail with a matching error
+func (test *Tester) connectCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	err := bot.ConnectContext(ctx)
+
+	if len(args) > 0 {
+		expected := regexp.MustCompile("^" + strings.TrimPrefix(args[0], "fails: ") + "$")
+
+		if err == nil {
+			t.Errorf("[line %d] expected connecting to fail, but it succeeded.", lineNr)
+			go bot.Work()
+			test.cancel = cancel
+			return
+		}
+
+		cancel()
+
+		test.failed = true
+
+		if !expected.MatchString(err.Error()) {
+			t.Errorf("[line %d] expected error `%s`, but got '%s' instead.", lineNr, expected.String(), err.Error())
+		}
+
+		return
+	}
+
 	if err != nil {
 		t.Errorf("[line %d] could not connect: %s", lineNr, err.Error())
 	}
 
-	go bot.Work()
+	stopped := make(chan struct{})
+
+	test.cancel = cancel
+	test.stopped = stopped
+
+	go func() {
+		bot.Run(ctx)
+		close(stopped)
+	}()
 
 	// wait a bit for everything to settle, especially the join on the bot channel
 	<-time.After(50 * time.Millisecond)
 }
 
+// break <table> renames a table until the script ends, so that queries fail
+func (test *Tester) breakCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	table := args[0]
+
+	_, err := test.db.Exec("ALTER TABLE `" + table + "` RENAME TO `" + table + "_broken`")
+	if err != nil {
+		t.Errorf("[line %d] could not break table %s: %s", lineNr, table, err.Error())
+		return
+	}
+
+	test.cleanups = append(test.cleanups, func() {
+		test.db.MustExec("ALTER TABLE `" + table + "_broken` RENAME TO `" + table + "`")
+	})
+}
+
+// migrate [fresh|drop|upgrade] runs the migrations again and expects nothing
+// to be left to do. "drop" drops all tables without migrating, so a script can
+// set up an old schema via sql; "upgrade" then expects every migration to
+// apply, and "fresh" does both in one go.
+func (test *Tester) migrateCommand(t *testing.T, lineNr int, args []string) {
+	mode := ""
+	if len(args) > 0 {
+		mode = args[0]
+	}
+
+	if mode == "fresh" || mode == "drop" {
+		for _, table := range test.tables() {
+			test.db.MustExec("DROP TABLE `" + table + "`")
+		}
+
+		if mode == "drop" {
+			return
+		}
+	}
+
+	applied, err := bot.Migrate(test.db)
+	if err != nil {
+		t.Errorf("[line %d] could not migrate: %s", lineNr, err.Error())
+		return
+	}
+
+	if mode == "" {
+		if applied != 0 {
+			t.Errorf("[line %d] expected no migrations to be applied, but %d were.", lineNr, applied)
+		}
+
+		return
+	}
+
+	versions := 0
+	test.db.Get(&versions, "SELECT COUNT(*) FROM schema_migrations")
+
+	if applied == 0 || applied != versions {
+		t.Errorf("[line %d] expected all migrations to be applied, but %d of %d were.", lineNr, applied, versions)
+	}
+}
+
+// storedStruct is what the storage's JSON commands work with
+type storedStruct struct {
+	Name    string   `json:"name"`
+	Seconds int      `json:"seconds"`
+	Tags    []string `json:"tags"`
+}
+
+// storage set <plugin> <channel> <key> <value>
+// storage get <plugin> <channel> <key> [<value>] (no value means the key must not exist)
+// storage del <plugin> <channel> <key>
+// storage setjson <plugin> <channel> <key> <json>
+// storage getjson <plugin> <channel> <key> <json|missing|invalid>
+// storage all <plugin> <channel> [<key>=<value> ...] (sorted by key)
+// work directly on the bot's key/value store
+// sql <statement> changes the database behind the bot's back, like someone
+// editing it by hand
+func (test *Tester) sqlCommand(t *testing.T, lineNr int, args []string) {
+	_, err := test.db.Exec(args[0])
+	if err != nil {
+		t.Errorf("[line %d] could not execute statement: %s", lineNr, err.Error())
+	}
+}
+
+func (test *Tester) storageCommand(t *testing.T, kabukibot *bot.Kabukibot, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 5)
+	storage := kabukibot.Storage()
+
+	switch parts[0] {
+	case "set":
+		err := storage.Set(parts[1], parts[2], parts[3], parts[4])
+		if err != nil {
+			t.Errorf("[line %d] could not set value: %s", lineNr, err.Error())
+		}
+
+	case "get":
+		value, exists := storage.Get(parts[1], parts[2], parts[3])
+
+		if len(parts) < 5 {
+			if exists {
+				t.Errorf("[line %d] expected no value, but got '%s'.", lineNr, value)
+			}
+		} else if !exists {
+			t.Errorf("[line %d] expected '%s', but the value does not exist.", lineNr, parts[4])
+		} else if value != parts[4] {
+			t.Errorf("[line %d] expected '%s', but got '%s'.", lineNr, parts[4], value)
+		}
+
+	case "setjson":
+		value := storedStruct{}
+		json.Unmarshal([]byte(parts[4]), &value)
+
+		err := storage.SetJSON(parts[1], parts[2], parts[3], value)
+		if err != nil {
+			t.Errorf("[line %d] could not set value: %s", lineNr, err.Error())
+		}
+
+	case "getjson":
+		value := storedStruct{}
+		err := storage.GetJSON(parts[1], parts[2], parts[3], &value)
+
+		switch parts[4] {
+		case "missing":
+			if err != bot.ErrNoValue {
+				t.Errorf("[line %d] expected the value to be missing, but got %v (%v).", lineNr, value, err)
+			}
+
+		case "invalid":
+			if err == nil || err == bot.ErrNoValue {
+				t.Errorf("[line %d] expected the value to be invalid, but got %v (%v).", lineNr, value, err)
+			}
+
+		default:
+			expected := storedStruct{}
+			json.Unmarshal([]byte(parts[4]), &expected)
+
+			if err != nil {
+				t.Errorf("[line %d] could not get value: %s", lineNr, err.Error())
+			} else if !reflect.DeepEqual(value, expected) {
+				t.Errorf("[line %d] expected %v, but got %v.", lineNr, expected, value)
+			}
+		}
+
+	case "del":
+		err := storage.Delete(parts[1], parts[2], parts[3])
+		if err != nil {
+			t.Errorf("[line %d] could not delete value: %s", lineNr, err.Error())
+		}
+
+	case "all":
+		values := storage.All(parts[1], parts[2])
+		pairs := make([]string, 0, len(values))
+
+		for key, value := range values {
+			pairs = append(pairs, key+"="+value)
+		}
+
+		sort.Strings(pairs)
+
+		expected := strings.Join(parts[3:], " ")
+		actual := strings.Join(pairs, " ")
+
+		if actual != expected {
+			t.Errorf("[line %d] expected values '%s', but got '%s'.", lineNr, expected, actual)
+		}
+	}
+}
+
+// metrics <regex> scrapes the metrics endpoint and expects a matching line
+func (test *Tester) metricsCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	// give the rate limiter time to count sent messages
+	<-time.After(50 * time.Millisecond)
+
+	response, err := http.Get("http://" + bot.MetricsAddress() + "/metrics")
+	if err != nil {
+		t.Errorf("[line %d] could not scrape metrics: %s", lineNr, err.Error())
+		return
+	}
+	defer response.Body.Close()
+
+	body, _ := ioutil.ReadAll(response.Body)
+	expected := regexp.MustCompile("(?m)^" + args[0] + "$")
+
+	if !expected.Match(body) {
+		t.Errorf("[line %d] expected a metric matching `%s`, but got:\n%s", lineNr, args[0], string(body))
+	}
+}
+
+// log <regex> expects a matching message to have been logged
+// lifecycle <channel> [<call> ...] expects exactly these calls (enable, disable,
+// part, shutdown) to the spy plugin's worker in the channel since the last check
+func (test *Tester) lifecycleCommand(t *testing.T, lineNr int, args []string) {
+	fields := strings.Fields(strings.Join(args, " "))
+
+	test.spyMutex.Lock()
+	spy := test.spy
+	test.spyMutex.Unlock()
+
+	if spy == nil {
+		t.Errorf("[line %d] the spy plugin has not been added.", lineNr)
+		return
+	}
+
+	// stopping workers happens in the background
+	<-time.After(50 * time.Millisecond)
+
+	expected := strings.Join(fields[1:], " ")
+	recorded := strings.Join(spy.recorded(fields[0]), " ")
+
+	if recorded != expected {
+		t.Errorf("[line %d] expected the calls `%s` in %s, but got `%s`.", lineNr, expected, fields[0], recorded)
+	}
+}
+
+func (test *Tester) logCommand(t *testing.T, log *fakeLog, lineNr int, args []string) {
+	expected := regexp.MustCompile("^" + args[0] + "$")
+
+	for _, message := range log.logged() {
+		if expected.MatchString(message) {
+			return
+		}
+	}
+
+	t.Errorf("[line %d] expected a log message matching `%s`, but none was logged.", lineNr, args[0])
+}
+
+// api [METHOD] <path?query> <json|status> makes the fake Twitch API respond
+// with the given JSON or, if it is a number, just that HTTP status code; the
+// method defaults to GET. Requests without a canned response get a 404. Use
+// this before connecting.
+func (test *Tester) apiCommand(t *testing.T, config *bot.Configuration, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 2)
+	if len(parts) < 2 {
+		t.Errorf("[line %d] usage: api [METHOD] <path?query> <json|status>", lineNr)
+		return
+	}
+
+	method := "GET"
+
+	if !strings.HasPrefix(parts[0], "/") {
+		method = parts[0]
+		parts = strings.SplitN(parts[1], " ", 2)
+
+		if len(parts) < 2 {
+			t.Errorf("[line %d] usage: api [METHOD] <path?query> <json|status>", lineNr)
+			return
+		}
+	}
+
+	if test.api == nil {
+		test.apiResponses = make(map[string]string)
+		test.apiRequests = make(map[string]string)
+
+		api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Method + " " + r.URL.RequestURI()
+			body, _ := ioutil.ReadAll(r.Body)
+
+			test.apiMutex.Lock()
+			test.apiRequests[key] = string(body)
+			response, exists := test.apiResponses[key]
+			test.apiMutex.Unlock()
+
+			if !exists {
+				http.NotFound(w, r)
+				return
+			}
+
+			if status, err := strconv.Atoi(response); err == nil {
+				w.WriteHeader(status)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(response))
+		}))
+
+		// plugins using HTTPClient look this up from their own goroutines
+		test.apiMutex.Lock()
+		test.api = api
+		test.apiMutex.Unlock()
+
+		test.cleanups = append(test.cleanups, func() {
+			api.Close()
+
+			test.apiMutex.Lock()
+			test.api = nil
+			test.apiMutex.Unlock()
+		})
+
+		config.TwitchAPI.BaseURL = test.api.URL
+	}
+
+	test.apiMutex.Lock()
+	test.apiResponses[method+" "+parts[0]] = parts[1]
+	test.apiMutex.Unlock()
+}
+
+// requested <METHOD> <path?query> <regex> expects the last request of that kind
+// to the fake API to have had a matching body
+func (test *Tester) requestedCommand(t *testing.T, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 3)
+	if len(parts) < 3 {
+		t.Errorf("[line %d] usage: requested <METHOD> <path?query> <regex>", lineNr)
+		return
+	}
+
+	// give the plugin time to talk to the API
+	<-time.After(50 * time.Millisecond)
+
+	test.apiMutex.Lock()
+	body, exists := test.apiRequests[parts[0]+" "+parts[1]]
+	test.apiMutex.Unlock()
+
+	if !exists {
+		t.Errorf("[line %d] expected a %s request to %s, but there was none.", lineNr, parts[0], parts[1])
+		return
+	}
+
+	expected := regexp.MustCompile("^" + parts[2] + "$")
+	if !expected.MatchString(body) {
+		t.Errorf("[line %d] expected a request body matching `%s`, but got '%s' instead.", lineNr, parts[2], body)
+	}
+}
+
+// env NAME [value] sets an environment variable until the script ends
+func (test *Tester) envCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 2)
+	if len(parts) < 2 {
+		parts = append(parts, "")
+	}
+
+	previous, existed := os.LookupEnv(parts[0])
+	os.Setenv(parts[0], parts[1])
+
+	test.cleanups = append(test.cleanups, func() {
+		if existed {
+			os.Setenv(parts[0], previous)
+		} else {
+			os.Unsetenv(parts[0])
+		}
+	})
+}
+
+// "reload <file> fails: <regex>" expects reloading to fail with a matching error
+func (test *Tester) reloadCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 2)
+	err := bot.ReloadFrom(parts[0])
+
+	if len(parts) > 1 {
+		expected := regexp.MustCompile("^" + strings.TrimPrefix(parts[1], "fails: ") + "$")
+
+		if err == nil {
+			t.Errorf("[line %d] expected reloading to fail, but it succeeded.", lineNr)
+		} else if !expected.MatchString(err.Error()) {
+			t.Errorf("[line %d] expected error `%s`, but got '%s' instead.", lineNr, expected.String(), err.Error())
+		}
+
+		return
+	}
+
+	if err != nil {
+		t.Errorf("[line %d] could not reload configuration: %s", lineNr, err.Error())
+	}
+
+	// give the channel workers time to pick up the changes
+	<-time.After(50 * time.Millisecond)
+}
+
 func (test *Tester) joinCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
 	<-bot.Join(args[0])
 	<-time.After(50 * time.Millisecond)
 }
 
+func (test *Tester) partCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	<-bot.Part(args[0])
+	<-time.After(50 * time.Millisecond)
+}
+
+// simulates the connection dying; the bot should reconnect and rejoin its channels
+func (test *Tester) disconnectCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, client *fakeClient) {
+	reconnecting := bot.Reconnecting()
+	client.lost <- struct{}{}
+
+	select {
+	case <-reconnecting:
+	case <-time.After(100 * time.Millisecond):
+		t.Errorf("[line %d] the bot did not start to reconnect.", lineNr)
+	}
+}
+
+// shutdown cancels the context the bot is running with and waits for it to stop
+func (test *Tester) shutdownCommand(t *testing.T, lineNr int) {
+	if test.cancel == nil {
+		t.Errorf("[line %d] the bot is not running.", lineNr)
+		return
+	}
+
+	test.cancel()
+
+	select {
+	case <-test.stopped:
+	case <-time.After(2 * time.Second):
+		t.Errorf("[line %d] the bot did not stop.", lineNr)
+	}
+}
+
+// Intn can be given to plugins instead of rand.Intn; it returns the numbers
+// queued by the random command, or 0 if there are none.
+func (test *Tester) Intn(n int) int {
+	test.randomMutex.Lock()
+	defer test.randomMutex.Unlock()
+
+	if len(test.random) == 0 {
+		return 0
+	}
+
+	number := test.random[0]
+	test.random = test.random[1:]
+
+	return number % n
+}
+
+// random <number> [<number> ...] queues numbers for Intn
+func (test *Tester) randomCommand(t *testing.T, lineNr int, args []string) {
+	test.randomMutex.Lock()
+	defer test.randomMutex.Unlock()
+
+	for _, arg := range strings.Fields(args[0]) {
+		number, err := strconv.Atoi(arg)
+		if err != nil || number < 0 {
+			t.Errorf("[line %d] invalid number: %s", lineNr, arg)
+			return
+		}
+
+		test.random = append(test.random, number)
+	}
+}
+
+// clock <duration> moves the fake clock forward
+func (test *Tester) clockCommand(t *testing.T, lineNr int, args []string) {
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		t.Errorf("[line %d] invalid duration: %s", lineNr, err.Error())
+		return
+	}
+
+	test.clock.Advance(d)
+}
+
 func (test *Tester) waitCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
 	duration := 50 * time.Millisecond
 
@@ -145,10 +782,182 @@ func (test *Tester) sendCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, li
 		t.Errorf("[line %d] invalid line: '%s'", lineNr, line)
 	}
 
+	// "< [@bot] user: text" whispers to the bot instead
+	if strings.HasPrefix(matched[1], "@") {
+		client.incoming <- twitch.WhisperMessage{
+			User: parseUser(matched[2]).Name,
+			Text: matched[3],
+		}
+
+		return
+	}
+
 	client.incoming <- twitch.TextMessage{
 		Channel: matched[1],
-		User:    twitch.User{Name: matched[2]},
+		User:    parseUser(matched[2]),
 		Text:    matched[3],
+		Tags:    test.tags,
+	}
+
+	test.tags = nil
+}
+
+// tags <name>=<value>[;<name>=<value> ...] attaches IRCv3 tags to the next injected message
+func (test *Tester) tagsCommand(t *testing.T, lineNr int, args []string) {
+	test.tags = make(twitch.Tags)
+
+	for _, pair := range strings.Split(args[0], ";") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			t.Errorf("[line %d] invalid tag: '%s'", lineNr, pair)
+			continue
+		}
+
+		test.tags[parts[0]] = parts[1]
+	}
+}
+
+// users can be prefixed like in IRC: "@mod" is a moderator, "%sub" a subscriber
+func parseUser(name string) twitch.User {
+	user := twitch.User{Name: strings.TrimLeft(name, "$%&@!~+")}
+	modes := name[:len(name)-len(user.Name)]
+
+	if strings.Contains(modes, "@") {
+		user.Type = twitch.Moderator
+	}
+
+	if strings.Contains(modes, "%") {
+		user.Subscriber = true
+	}
+
+	return user
+}
+
+// "sub [#chan] user months plan [message]" injects a (re)subscription
+func (test *Tester) subCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, line string, client *fakeClient) {
+	matched := injectedSubscription.FindStringSubmatch(line)
+	if len(matched) != 6 {
+		t.Errorf("[line %d] invalid line: '%s'", lineNr, line)
+		return
+	}
+
+	months, _ := strconv.Atoi(matched[3])
+
+	client.incoming <- twitch.SubscriberNotificationMessage{
+		Channel: matched[1],
+		User:    matched[2],
+		Months:  months,
+		Plan:    matched[4],
+		Text:    matched[5],
+	}
+}
+
+// "mode [#chan] +o user" (or -o) injects a (un)modding
+func (test *Tester) modeCommand(t *testing.T, lineNr int, line string, client *fakeClient) {
+	matched := injectedMode.FindStringSubmatch(line)
+	if len(matched) != 4 {
+		t.Errorf("[line %d] invalid line: '%s'", lineNr, line)
+		return
+	}
+
+	client.incoming <- twitch.ModeMessage{
+		Channel:   matched[1],
+		User:      matched[3],
+		Moderator: matched[2] == "+",
+	}
+}
+
+// raw <line> injects an IRC line, which is parsed like the real client would;
+// CTCP delimiters can be written as \x01
+func (test *Tester) rawCommand(t *testing.T, log *fakeLog, lineNr int, args []string, client *fakeClient) {
+	parser := twitch.NewTwitchClient("", test.config.Account.Username, "", 0, nil, log)
+	parser.HandleLine(strings.Replace(args[0], `\x01`, "\x01", -1))
+
+	for {
+		select {
+		case msg := <-parser.Incoming():
+			client.incoming <- msg
+		default:
+			return
+		}
+	}
+}
+
+// sent <regex> expects a raw IRC line to be sent
+func (test *Tester) sentCommand(t *testing.T, lineNr int, args []string, client *fakeClient) {
+	timeout := time.After(50 * time.Millisecond)
+	expected := regexp.MustCompile("^" + args[0] + "$")
+
+	select {
+	case actual := <-client.outgoing:
+		asserted, okay := actual.(twitch.RawMessage)
+		if !okay {
+			t.Errorf("[line %d] expected a raw line, but got %#v instead.", lineNr, actual)
+			return
+		}
+
+		if line := asserted.IrcMessage().String(); !expected.MatchString(line) {
+			t.Errorf("[line %d] expected match `%s`, but got '%s' instead.", lineNr, args[0], line)
+		}
+
+	case <-timeout:
+		t.Errorf("[line %d] expected a raw line to be sent, but got nothing.", lineNr)
+	}
+}
+
+// listeners <event> <count> expects that many plugin workers to listen for the
+// event, which can be scoped to a channel like "text#chan"
+func (test *Tester) listenersCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 2)
+	if len(parts) < 2 {
+		t.Errorf("[line %d] usage: listeners <event> <count>", lineNr)
+		return
+	}
+
+	expected, err := strconv.Atoi(parts[1])
+	if err != nil {
+		t.Errorf("[line %d] invalid count: %s", lineNr, parts[1])
+		return
+	}
+
+	if actual := bot.ListenerCount(parts[0]); actual != expected {
+		t.Errorf("[line %d] expected %d listener(s) for %s, but found %d (events: %s).", lineNr, expected, parts[0], actual, strings.Join(bot.Events(), ", "))
+	}
+}
+
+// joins <count> expects that many JOINs to have been sent to Twitch so far
+func (test *Tester) joinsCommand(t *testing.T, lineNr int, args []string, client *fakeClient) {
+	expected, err := strconv.Atoi(args[0])
+	if err != nil {
+		t.Errorf("[line %d] invalid count: %s", lineNr, args[0])
+		return
+	}
+
+	// give the join limiter time to catch up with the clock
+	<-time.After(50 * time.Millisecond)
+
+	if actual := client.Joins(); actual != expected {
+		t.Errorf("[line %d] expected %d JOIN(s) to have been sent, but got %d.", lineNr, expected, actual)
+	}
+}
+
+// moderator <#chan> <yes|no> expects the bot to (not) be a moderator in the channel
+func (test *Tester) moderatorCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, args []string) {
+	parts := strings.SplitN(args[0], " ", 2)
+
+	// give the channel worker time to handle previous messages
+	<-time.After(50 * time.Millisecond)
+
+	channel, err := bot.Channel(parts[0])
+	if err != nil {
+		t.Errorf("[line %d] %s", lineNr, err.Error())
+		return
+	}
+
+	expected := parts[1] == "yes"
+
+	if channel.IsModerator() != expected {
+		t.Errorf("[line %d] expected moderator status to be %v, but it is not.", lineNr, expected)
 	}
 }
 
@@ -159,6 +968,12 @@ func (test *Tester) receiveCommand(t *testing.T, bot *bot.Kabukibot, lineNr int,
 		t.Errorf("[line %d] invalid line: '%s'", lineNr, line)
 	}
 
+	// "> [@user] bot: text" expects a whisper to the user instead of a channel message
+	if strings.HasPrefix(matched[1], "@") {
+		test.receiveWhisper(t, lineNr, line, matched, client)
+		return
+	}
+
 	select {
 	case actual := <-client.outgoing:
 		asserted, okay := actual.(twitch.TextMessage)
@@ -170,6 +985,37 @@ func (test *Tester) receiveCommand(t *testing.T, bot *bot.Kabukibot, lineNr int,
 			t.Errorf("[line %d] expected to message in %s, but got one in %s instead.", lineNr, matched[1], asserted.Channel)
 		}
 
+		// actions are written like they are typed, "/me text"
+		text := asserted.Text
+		if len(asserted.Action) > 0 {
+			text = asserted.Action + " " + text
+		}
+
+		regex := regexp.MustCompile("^" + matched[3] + "$")
+		if !regex.MatchString(text) {
+			t.Errorf("[line %d] expected match `%s`, but got '%s' instead.", lineNr, matched[3], text)
+		}
+
+	case <-timeout:
+		t.Errorf("[line %d] expected to receive '%s', but got no message at all.", lineNr, line)
+	}
+}
+
+func (test *Tester) receiveWhisper(t *testing.T, lineNr int, line string, matched []string, client *fakeClient) {
+	timeout := time.After(50 * time.Millisecond)
+
+	select {
+	case actual := <-client.outgoing:
+		asserted, okay := actual.(twitch.WhisperMessage)
+		if !okay {
+			t.Errorf("[line %d] expected to receive '%s', but did not get a whisper. Got %t instead.", lineNr, line, actual)
+			return
+		}
+
+		if asserted.User != strings.TrimPrefix(matched[1], "@") {
+			t.Errorf("[line %d] expected a whisper to %s, but got one to %s instead.", lineNr, matched[1], asserted.User)
+		}
+
 		regex := regexp.MustCompile("^" + matched[3] + "$")
 		if !regex.MatchString(asserted.Text) {
 			t.Errorf("[line %d] expected match `%s`, but got '%s' instead.", lineNr, matched[3], asserted.Text)
@@ -180,6 +1026,70 @@ func (test *Tester) receiveCommand(t *testing.T, bot *bot.Kabukibot, lineNr int,
 	}
 }
 
+// whisper <user> <regex> expects a whisper to the user; it is the same as
+// "> [@user] bot: <regex>"
+func (test *Tester) whisperCommand(t *testing.T, lineNr int, line string, args []string, client *fakeClient) {
+	parts := strings.SplitN(args[0], " ", 2)
+	if len(parts) < 2 {
+		t.Errorf("[line %d] usage: whisper <user> <regex>", lineNr)
+		return
+	}
+
+	test.receiveWhisper(t, lineNr, line, []string{line, "@" + parts[0], "bot", parts[1]}, client)
+}
+
+// timeout <#chan> <user> <seconds> [<reason regex>] expects the user to be timed
+// out; without a reason, any reason is fine.
+// ban <#chan> <user> expects the user to be banned.
+func (test *Tester) moderationCommand(t *testing.T, lineNr int, line string, command string, args []string, client *fakeClient) {
+	expected := strings.SplitN(args[0], " ", 4)
+	if (command == ".ban" && len(expected) != 2) || (command == ".timeout" && len(expected) < 3) {
+		t.Errorf("[line %d] usage: timeout <#chan> <user> <seconds> [<reason regex>] or ban <#chan> <user>", lineNr)
+		return
+	}
+
+	timeout := time.After(50 * time.Millisecond)
+
+	select {
+	case actual := <-client.outgoing:
+		asserted, okay := actual.(twitch.TextMessage)
+		if !okay {
+			t.Errorf("[line %d] expected '%s', but did not get a chat command. Got %#v instead.", lineNr, line, actual)
+			return
+		}
+
+		if asserted.Channel != expected[0] {
+			t.Errorf("[line %d] expected a %s in %s, but got one in %s instead.", lineNr, command, expected[0], asserted.Channel)
+		}
+
+		// ".timeout <user> <seconds> <reason>", the reason can contain spaces
+		got := strings.SplitN(asserted.Text, " ", 4)
+		if got[0] != command || len(got) < 2 || (command == ".timeout" && len(got) < 3) {
+			t.Errorf("[line %d] expected '%s', but got '%s' instead.", lineNr, line, asserted.Text)
+			return
+		}
+
+		if len(got) < 4 {
+			got = append(got, "")
+		}
+
+		if !strings.EqualFold(got[1], expected[1]) {
+			t.Errorf("[line %d] expected a %s of %s, but got one of %s instead.", lineNr, command, expected[1], got[1])
+		}
+
+		if command == ".timeout" && got[2] != expected[2] {
+			t.Errorf("[line %d] expected a timeout of %s seconds, but got %s seconds instead.", lineNr, expected[2], got[2])
+		}
+
+		if len(expected) == 4 && !regexp.MustCompile("^"+expected[3]+"$").MatchString(got[3]) {
+			t.Errorf("[line %d] expected the reason to match `%s`, but got '%s' instead.", lineNr, expected[3], got[3])
+		}
+
+	case <-timeout:
+		t.Errorf("[line %d] expected '%s', but got no message at all.", lineNr, line)
+	}
+}
+
 func (test *Tester) silenceCommand(t *testing.T, bot *bot.Kabukibot, lineNr int, lastLine string, client *fakeClient) {
 	timeout := time.After(100 * time.Millisecond)
 
@@ -0,0 +1,396 @@
+package twitch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const HelixURL = "https://api.twitch.tv/helix"
+
+var ErrStreamOffline = errors.New("The stream is offline.")
+var ErrNotFollowing = errors.New("The user is not following the channel.")
+var ErrUnknownUser = errors.New("The user does not exist.")
+var ErrUnknownGame = errors.New("The game or category does not exist.")
+var ErrUnauthorized = errors.New("The token is not valid or lacks the required scope.")
+
+type Uptime struct {
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+type Followage struct {
+	FollowedAt time.Time
+	Duration   time.Duration
+}
+
+// Game is empty if the channel has never streamed.
+type ChannelInfo struct {
+	Login       string
+	DisplayName string
+	Game        string
+	Title       string
+}
+
+type Account struct {
+	ID        string
+	Login     string
+	CreatedAt time.Time
+}
+
+type Game struct {
+	ID   string
+	Name string
+}
+
+// accounts hardly ever change, so they can be cached much longer than the rest
+const accountTTL = 12 * time.Hour
+
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// APIClient talks to the Twitch Helix API. Responses are cached for a short
+// while, so that chat spamming !uptime does not run us into Twitch's limits.
+type APIClient struct {
+	http     *http.Client
+	baseURL  string
+	clientID string
+	token    string
+	ttl      time.Duration
+	cache    map[string]cachedResponse
+	mutex    sync.Mutex
+	now      func() time.Time
+}
+
+func NewAPIClient(client *http.Client, baseURL string, clientID string, token string, ttl time.Duration) *APIClient {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	if baseURL == "" {
+		baseURL = HelixURL
+	}
+
+	return &APIClient{
+		http:     client,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		clientID: clientID,
+		token:    strings.TrimPrefix(token, "oauth:"),
+		ttl:      ttl,
+		cache:    make(map[string]cachedResponse),
+		now:      time.Now,
+	}
+}
+
+// SetClock replaces the clock used to expire cached responses and to tell
+// how long ago something happened. Call it before using the client.
+func (self *APIClient) SetClock(now func() time.Time) {
+	self.now = now
+}
+
+type helixUsers struct {
+	Data []struct {
+		ID        string    `json:"id"`
+		Login     string    `json:"login"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"data"`
+}
+
+type helixChannels struct {
+	Data []struct {
+		Login       string `json:"broadcaster_login"`
+		DisplayName string `json:"broadcaster_name"`
+		Game        string `json:"game_name"`
+		Title       string `json:"title"`
+	} `json:"data"`
+}
+
+type helixGames struct {
+	Data []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"data"`
+}
+
+type helixStreams struct {
+	Data []struct {
+		Type      string    `json:"type"`
+		StartedAt time.Time `json:"started_at"`
+	} `json:"data"`
+}
+
+type helixFollowers struct {
+	Data []struct {
+		FollowedAt time.Time `json:"followed_at"`
+	} `json:"data"`
+}
+
+// StreamUptime returns for how long the channel has been live, or ErrStreamOffline.
+func (self *APIClient) StreamUptime(channel string) (Uptime, error) {
+	result := helixStreams{}
+
+	err := self.get("/streams", url.Values{"user_login": {channelLogin(channel)}}, &result)
+	if err != nil {
+		return Uptime{}, err
+	}
+
+	if len(result.Data) == 0 || result.Data[0].Type != "live" {
+		return Uptime{}, ErrStreamOffline
+	}
+
+	started := result.Data[0].StartedAt
+
+	return Uptime{started, self.now().Sub(started)}, nil
+}
+
+// FollowAge returns since when the user follows the channel, or ErrNotFollowing.
+func (self *APIClient) FollowAge(channel string, user string) (Followage, error) {
+	broadcasterID, err := self.userID(channelLogin(channel))
+	if err != nil {
+		return Followage{}, err
+	}
+
+	userID, err := self.userID(strings.ToLower(user))
+	if err != nil {
+		return Followage{}, err
+	}
+
+	result := helixFollowers{}
+
+	err = self.get("/channels/followers", url.Values{"broadcaster_id": {broadcasterID}, "user_id": {userID}}, &result)
+	if err != nil {
+		return Followage{}, err
+	}
+
+	if len(result.Data) == 0 {
+		return Followage{}, ErrNotFollowing
+	}
+
+	followed := result.Data[0].FollowedAt
+
+	return Followage{followed, self.now().Sub(followed)}, nil
+}
+
+// Account returns when the user has signed up, or ErrUnknownUser.
+func (self *APIClient) Account(user string) (Account, error) {
+	result := helixUsers{}
+
+	err := self.getFor("/users", url.Values{"login": {strings.ToLower(user)}}, accountTTL, &result)
+	if err != nil {
+		return Account{}, err
+	}
+
+	if len(result.Data) == 0 {
+		return Account{}, ErrUnknownUser
+	}
+
+	data := result.Data[0]
+
+	return Account{data.ID, data.Login, data.CreatedAt}, nil
+}
+
+// Channel returns the game and title the channel was last streaming with.
+func (self *APIClient) Channel(channel string) (ChannelInfo, error) {
+	id, err := self.userID(channelLogin(channel))
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+
+	result := helixChannels{}
+
+	err = self.get("/channels", url.Values{"broadcaster_id": {id}}, &result)
+	if err != nil {
+		return ChannelInfo{}, err
+	}
+
+	if len(result.Data) == 0 {
+		return ChannelInfo{}, ErrUnknownUser
+	}
+
+	data := result.Data[0]
+
+	return ChannelInfo{data.Login, data.DisplayName, data.Game, data.Title}, nil
+}
+
+// FindGame looks up a game or category by its name.
+func (self *APIClient) FindGame(name string) (Game, error) {
+	result := helixGames{}
+
+	err := self.get("/games", url.Values{"name": {name}}, &result)
+	if err != nil {
+		return Game{}, err
+	}
+
+	if len(result.Data) == 0 {
+		return Game{}, ErrUnknownGame
+	}
+
+	return Game{result.Data[0].ID, result.Data[0].Name}, nil
+}
+
+// UpdateChannel changes the title and/or game of the channel; empty values are
+// left alone. The token must belong to the broadcaster (or one of their
+// editors) and have the channel:manage:broadcast scope.
+func (self *APIClient) UpdateChannel(channel string, title string, gameID string) error {
+	id, err := self.userID(channelLogin(channel))
+	if err != nil {
+		return err
+	}
+
+	payload := make(map[string]string)
+
+	if title != "" {
+		payload["title"] = title
+	}
+
+	if gameID != "" {
+		payload["game_id"] = gameID
+	}
+
+	query := url.Values{"broadcaster_id": {id}}
+
+	err = self.send("PATCH", "/channels", query, payload)
+	if err != nil {
+		return err
+	}
+
+	// make sure the new values are read back
+	self.mutex.Lock()
+	delete(self.cache, self.baseURL+"/channels?"+query.Encode())
+	self.mutex.Unlock()
+
+	return nil
+}
+
+func (self *APIClient) userID(login string) (string, error) {
+	result := helixUsers{}
+
+	err := self.get("/users", url.Values{"login": {login}}, &result)
+	if err != nil {
+		return "", err
+	}
+
+	if len(result.Data) == 0 {
+		return "", ErrUnknownUser
+	}
+
+	return result.Data[0].ID, nil
+}
+
+func (self *APIClient) get(path string, query url.Values, dest interface{}) error {
+	return self.getFor(path, query, self.ttl, dest)
+}
+
+// getFor is like get, but caches the response for the given time
+func (self *APIClient) getFor(path string, query url.Values, ttl time.Duration, dest interface{}) error {
+	address := self.baseURL + path + "?" + query.Encode()
+
+	body, err := self.fetch(address, ttl)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, dest)
+}
+
+// send performs a request that changes something; its response is not cached.
+func (self *APIClient) send(method string, path string, query url.Values, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(method, self.baseURL+path+"?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := self.do(request)
+	if err != nil {
+		return err
+	}
+
+	response.Body.Close()
+
+	return nil
+}
+
+func (self *APIClient) fetch(address string, ttl time.Duration) ([]byte, error) {
+	now := self.now()
+
+	self.mutex.Lock()
+	cached, exists := self.cache[address]
+	self.mutex.Unlock()
+
+	if exists && now.Before(cached.expires) {
+		return cached.body, nil
+	}
+
+	request, err := http.NewRequest("GET", address, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := self.do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	self.mutex.Lock()
+	self.cache[address] = cachedResponse{body, now.Add(ttl)}
+
+	// every user or game looked up adds an entry, so forget the stale ones
+	for key, entry := range self.cache {
+		if !now.Before(entry.expires) {
+			delete(self.cache, key)
+		}
+	}
+
+	self.mutex.Unlock()
+
+	return body, nil
+}
+
+// do authenticates the request and turns unsuccessful responses into errors.
+func (self *APIClient) do(request *http.Request) (*http.Response, error) {
+	request.Header.Set("Client-Id", self.clientID)
+	request.Header.Set("Authorization", "Bearer "+self.token)
+
+	response, err := self.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		response.Body.Close()
+		return nil, ErrUnauthorized
+
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		response.Body.Close()
+		return nil, fmt.Errorf("Twitch API responded with HTTP %d.", response.StatusCode)
+	}
+
+	return response, nil
+}
+
+func channelLogin(channel string) string {
+	return strings.ToLower(strings.TrimPrefix(channel, "#"))
+}
@@ -0,0 +1,38 @@
+package twitch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStaleCacheEntriesAreDropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	now := time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	client := NewAPIClient(server.Client(), server.URL, "id", "token", time.Minute)
+	client.SetClock(func() time.Time { return now })
+
+	if _, err := client.fetch(server.URL+"/users?login=a", time.Minute); err != nil {
+		t.Fatalf("expected the first request to succeed, got %v.", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, err := client.fetch(server.URL+"/users?login=b", time.Minute); err != nil {
+		t.Fatalf("expected the second request to succeed, got %v.", err)
+	}
+
+	if _, exists := client.cache[server.URL+"/users?login=a"]; exists {
+		t.Error("expected the stale entry to be dropped from the cache.")
+	}
+
+	if len(client.cache) != 1 {
+		t.Errorf("expected exactly one cached response, got %d.", len(client.cache))
+	}
+}
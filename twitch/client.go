@@ -2,6 +2,7 @@ package twitch
 
 import (
 	"bufio"
+	"errors"
 	"net"
 	"strings"
 	"sync"
@@ -14,6 +15,24 @@ import (
 // (this applies to OUTGOING messages)
 const queueSize = 50
 
+// how long to wait for Twitch to answer our capability requests
+const capTimeout = 10 * time.Second
+
+// after being idle for this long, we PING Twitch and consider the connection
+// dead if nothing arrives within the PONG timeout
+const DefaultIdleTimeout = 5 * time.Minute
+const DefaultPongTimeout = 10 * time.Second
+
+// DefaultCapabilities are requested unless configured otherwise; membership
+// makes Twitch send every JOIN/PART, which is a lot in big channels.
+var DefaultCapabilities = []string{"membership", "commands", "tags"}
+
+// capRequest tracks which requested capabilities Twitch has yet to answer
+type capRequest struct {
+	pending map[string]bool
+	ready   chan struct{}
+}
+
 // a message on the queue, this is not what the outside world sees
 type queueItem struct {
 	message OutgoingMessage
@@ -44,12 +63,28 @@ type TwitchClient struct {
 	// time between two regular messages are sent
 	delay time.Duration
 
-	// this signal is sent when the client has sent the CAP REQ commands
+	// keepalive, see DefaultIdleTimeout
+	idleTimeout time.Duration
+	pongTimeout time.Duration
+
+	// the IRCv3 capabilities to request after connecting
+	capabilities []string
+	capRequest   *capRequest
+	capMutex     sync.Mutex
+
+	// this signal is sent when Twitch has answered the CAP REQ commands
 	ready chan struct{}
 
 	// this signal is sent when we disconnected
 	alive chan struct{}
 
+	// this signal is sent when the connection died unexpectedly
+	lost chan struct{}
+
+	// whether the sender/receiver are currently running
+	connected bool
+	connMutex sync.Mutex
+
 	// these are fired when .Disconnect() is called
 	stopSending   chan struct{}
 	stopReceiving chan struct{}
@@ -73,17 +108,21 @@ type TwitchClient struct {
 	logger logger
 }
 
-func NewTwitchClient(server string, username string, password string, delay time.Duration, logger logger) *TwitchClient {
+func NewTwitchClient(server string, username string, password string, delay time.Duration, capabilities []string, logger logger) *TwitchClient {
 	client := &TwitchClient{
 		server:           server,
 		username:         username,
 		password:         password,
 		delay:            delay,
+		capabilities:     capabilities,
+		idleTimeout:      DefaultIdleTimeout,
+		pongTimeout:      DefaultPongTimeout,
 		conn:             nil,
 		reader:           nil,
 		writer:           nil,
 		ready:            make(chan struct{}),
 		alive:            make(chan struct{}),
+		lost:             make(chan struct{}, 1),
 		stopReceiving:    make(chan struct{}),
 		stopSending:      make(chan struct{}),
 		stoppedReceiving: make(chan struct{}),
@@ -102,6 +141,19 @@ func NewTwitchClient(server string, username string, password string, delay time
 	return client
 }
 
+// SetKeepalive changes how long the connection may be idle before we PING
+// Twitch and how long we then wait for an answer; zero values keep the
+// defaults. Call this before connecting.
+func (client *TwitchClient) SetKeepalive(idle time.Duration, pong time.Duration) {
+	if idle > 0 {
+		client.idleTimeout = idle
+	}
+
+	if pong > 0 {
+		client.pongTimeout = pong
+	}
+}
+
 func (client *TwitchClient) Ready() <-chan struct{} {
 	return client.ready
 }
@@ -114,6 +166,10 @@ func (client *TwitchClient) Incoming() <-chan IncomingMessage {
 	return client.incoming
 }
 
+func (client *TwitchClient) ConnectionLost() <-chan struct{} {
+	return client.lost
+}
+
 func (client *TwitchClient) Connect() error {
 	client.logger.Debug("Establishing connection...")
 
@@ -123,51 +179,104 @@ func (client *TwitchClient) Connect() error {
 		return err
 	}
 
+	client.connMutex.Lock()
+	defer client.connMutex.Unlock()
+
+	// the incoming channel has been closed, so there is no way back
+	select {
+	case <-client.alive:
+		conn.Close()
+		return errors.New("the client has been disconnected for good")
+	default:
+	}
+
 	client.conn = conn
 	client.reader = bufio.NewReader(conn) // we manually read to properly handle tags
 	client.writer = irc.NewEncoder(conn)
 
+	// when reconnecting, the old signals have already been used up
+	client.ready = make(chan struct{})
+	client.stopReceiving = make(chan struct{})
+	client.stopSending = make(chan struct{})
+	client.stoppedReceiving = make(chan struct{})
+	client.stoppedSending = make(chan struct{})
+
+	// send login info before anything else; this bypasses the queue, because
+	// after a reconnect it could still contain messages from before
+	login := []irc.Message{
+		{Command: irc.PASS, Params: []string{client.password}},
+		{Command: irc.NICK, Params: []string{client.username}},
+		{Command: irc.USER, Params: []string{"kabukibot", "8", "*", client.username}},
+	}
+
+	for _, msg := range login {
+		client.writer.Encode(&msg)
+		client.msgSent++
+	}
+
 	// start working on the queue
 	go client.sender()
 
 	// start receiving
 	go client.receiver()
 
-	// send login info before anything else
-	client.Send(RawMessage{irc.Message{
-		Command: irc.PASS,
-		Params:  []string{client.password},
-	}})
-
-	client.Send(RawMessage{irc.Message{
-		Command: irc.NICK,
-		Params:  []string{client.username},
-	}})
-
-	client.Send(RawMessage{irc.Message{
-		Command: irc.USER,
-		Params:  []string{"kabukibot", "8", "*", client.username},
-	}})
+	client.connected = true
 
 	return nil
 }
 
 func (client *TwitchClient) Disconnect() error {
-	// stop the sender/receiver and wait for them to stop (maybe it will drain the
-	// outgoing queue, maybe it won't, but let's give it time)
-	close(client.stopReceiving)
-	<-client.stoppedReceiving
+	var err error
 
-	close(client.stopSending)
-	<-client.stoppedSending
+	client.connMutex.Lock()
+
+	// for all intents and purposes, we are not alive anymore; this happens
+	// while holding the mutex, so that Connect cannot sneak in a new connection
+	close(client.alive)
+
+	if client.connected {
+		err = client.stop()
+	}
+
+	client.connMutex.Unlock()
 
 	// close the incoming queue
 	close(client.incoming)
 
-	// for all intents and purposes, we are not alive anymore
-	close(client.alive)
+	return err
+}
+
+// connectionLost is called when reading from the connection failed without anyone
+// asking us to disconnect. Everything is stopped, but the incoming channel stays
+// open, so that the client can be connected again.
+func (client *TwitchClient) connectionLost() {
+	client.connMutex.Lock()
+	defer client.connMutex.Unlock()
+
+	if !client.connected {
+		return
+	}
+
+	client.stop()
+
+	select {
+	case client.lost <- struct{}{}:
+	default:
+	}
+}
+
+// stop the sender/receiver and wait for them to stop (maybe it will drain the
+// outgoing queue, maybe it won't, but let's give it time), then close the IRC
+// connection; must be called with connMutex being held
+func (client *TwitchClient) stop() error {
+	client.connected = false
+
+	close(client.stopReceiving)
+	<-client.stoppedReceiving
+
+	close(client.stopSending)
+	<-client.stoppedSending
 
-	// close the IRC connection
 	return client.conn.Close()
 }
 
@@ -203,12 +312,17 @@ func (client *TwitchClient) Send(msg OutgoingMessage) <-chan bool {
 }
 
 func (client *TwitchClient) sender() {
+	// hold on to the current connection's signals, a reconnect will replace them
+	writer := client.writer
+	stop := client.stopSending
+	stopped := client.stoppedSending
+
 	for {
 		select {
 		case msg := <-client.outgoing:
 			ircMsg := msg.message.IrcMessage()
 			// fmt.Println("< " + ircMsg.String())
-			client.writer.Encode(ircMsg)
+			writer.Encode(ircMsg)
 
 			client.msgSent++
 
@@ -223,8 +337,8 @@ func (client *TwitchClient) sender() {
 			// wait a bit
 			<-time.After(client.delay)
 
-		case <-client.stopSending:
-			close(client.stoppedSending)
+		case <-stop:
+			close(stopped)
 			return
 		}
 	}
@@ -233,6 +347,12 @@ func (client *TwitchClient) sender() {
 func (client *TwitchClient) receiver() {
 	reading := make(chan struct{})
 
+	// hold on to the current connection, a reconnect will replace it
+	conn := client.conn
+	reader := client.reader
+	stop := client.stopReceiving
+	stopped := client.stoppedReceiving
+
 	// a buffer between the raw irc input from the net and the goroutine channels
 	buffer := make(chan string, 10)
 
@@ -241,64 +361,116 @@ func (client *TwitchClient) receiver() {
 	go func() {
 		defer close(reading)
 
+		partial := "" // what was read before running into a timeout
+		pinged := false
+
 		for {
 			select {
-			case <-client.stopReceiving:
+			case <-stop:
 				return
 
 			default:
-				// set a 5min timeout
-				client.conn.SetDeadline(time.Now().Add(300 * time.Second))
+				timeout := client.idleTimeout
+				if pinged {
+					timeout = client.pongTimeout
+				}
 
-				line, err := client.reader.ReadString('\n')
+				conn.SetReadDeadline(time.Now().Add(timeout))
+
+				line, err := reader.ReadString('\n')
 				if err != nil {
-					client.logger.Error("Connection died: " + err.Error())
-					client.Disconnect()
+					// after being idle for a while, make sure the connection is still alive
+					if isTimeout(err) && !pinged {
+						client.logger.Debug("Nothing received for %s, sending PING...", timeout)
+						client.Send(pingMessage{"tmi.twitch.tv"})
+
+						partial += line
+						pinged = true
+						continue
+					}
+
+					select {
+					case <-stop:
+						// we are disconnecting on purpose
+					default:
+						client.logger.Error("Connection died: " + err.Error())
+						go client.connectionLost()
+					}
+
 					return
 				}
 
-				buffer <- line
+				// anything arriving proves the connection is alive, not just a PONG
+				line = partial + line
+				partial = ""
+				pinged = false
+
+				select {
+				case buffer <- line:
+				case <-stop:
+					return
+				}
 			}
 		}
 	}()
 
-	defer close(client.stoppedReceiving)
+	defer close(stopped)
 
 	for {
 		select {
 		case rawLine := <-buffer:
 			// fmt.Println("> " + strings.TrimSpace(rawLine))
+			client.HandleLine(rawLine)
 
-			// if the message begins with a '@', we have some tags (IRCv3). The default
-			// IRC decoder will not have properly detected it and mangled its output.
-			// We fix that by manually splitting the tags from the rest of the message
-			// and parse each part individually.
-			tags := make(irc.Tags)
-			msg := &irc.Message{}
+		case <-stop:
+			return
+		}
+	}
+}
 
-			if strings.HasPrefix(rawLine, "@") {
-				parts := strings.SplitN(rawLine, " ", 2)
+func isTimeout(err error) bool {
+	netErr, okay := err.(net.Error)
 
-				tags = irc.ParseTags(strings.TrimPrefix(parts[0], "@"))
-				msg = irc.ParseMessage(parts[1])
-			} else {
-				msg = irc.ParseMessage(rawLine)
-			}
+	return okay && netErr.Timeout()
+}
 
-			client.msgReceived++
+// HandleLine parses a single line as read from the connection and hands it to
+// the matching handler. It is only exported so that tests can feed lines.
+func (client *TwitchClient) HandleLine(rawLine string) {
+	// if the message begins with a '@', we have some tags (IRCv3). The default
+	// IRC decoder will not have properly detected it and mangled its output.
+	// We fix that by manually splitting the tags from the rest of the message
+	// and parse each part individually.
+	tags := make(irc.Tags)
+	msg := &irc.Message{}
+
+	if strings.HasPrefix(rawLine, "@") {
+		parts := strings.SplitN(rawLine, " ", 2)
+
+		tags = parseTags(strings.TrimPrefix(parts[0], "@"))
+		msg = irc.ParseMessage(parts[1])
+	} else {
+		msg = irc.ParseMessage(rawLine)
+	}
 
-			// hand it over to the message handler;
-			// this could be done in goroutines by simply doing "go handler(...)",
-			// but then we could interpret messages out-of-order. There are enough
-			// buffers and goroutines already, so forking here is not really
-			// needed anyway.
-			handler, ok := client.handlers[msg.Command]
-			if ok {
-				handler(msg, tags)
-			}
+	client.msgReceived++
 
-		case <-client.stopReceiving:
-			return
-		}
+	if msg == nil {
+		return
+	}
+
+	// give plugins a chance to see what the parsed messages leave out
+	if len(msg.Params) > 0 && strings.HasPrefix(msg.Params[0], "#") {
+		client.incoming <- RawLineMessage{msg.Params[0], strings.TrimRight(rawLine, "\r\n")}
+	}
+
+	// hand it over to the message handler;
+	// this could be done in goroutines by simply doing "go handler(...)",
+	// but then we could interpret messages out-of-order. There are enough
+	// buffers and goroutines already, so forking here is not really
+	// needed anyway.
+	handler, ok := client.handlers[msg.Command]
+	if ok {
+		handler(msg, tags)
 	}
 }
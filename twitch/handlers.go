@@ -4,6 +4,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sorcix/irc"
 )
@@ -13,24 +14,43 @@ type HandlerFunc func(*irc.Message, irc.Tags)
 func (client *TwitchClient) setupHandlers() {
 	client.handlers = map[string]HandlerFunc{
 		irc.RPL_WELCOME: client.onWelcome,
+		irc.CAP:         client.onCap,
 		irc.PING:        client.onPing,
 		irc.JOIN:        client.onJoin,
 		irc.PART:        client.onPart,
 		irc.PRIVMSG:     client.onPrivmsg,
 
 		// special twitch commands
-		"ROOMSTATE": client.onRoomState,
-		"NOTICE":    client.onRoomState, // re-use the handler
-		"CLEARCHAT": client.onClearChat,
+		"ROOMSTATE":  client.onRoomState,
+		"NOTICE":     client.onRoomState, // re-use the handler
+		"CLEARCHAT":  client.onClearChat,
+		"CLEARMSG":   client.onClearMsg,
+		"USERNOTICE": client.onUserNotice,
+		"USERSTATE":  client.onUserState,
+		"WHISPER":    client.onWhisper,
+		"MODE":       client.onMode,
 	}
 }
 
 func (client *TwitchClient) onWelcome(msg *irc.Message, tags irc.Tags) {
-	var sent <-chan bool
+	request := &capRequest{make(map[string]bool), client.ready}
+
+	for _, capability := range client.capabilities {
+		request.pending[capability] = true
+	}
+
+	client.capMutex.Lock()
+	client.capRequest = request
+	client.capMutex.Unlock()
 
-	caps := []string{"membership", "commands", "tags"}
+	if len(request.pending) == 0 {
+		client.capsNegotiated(request)
+		return
+	}
+
+	var sent <-chan bool
 
-	for _, capability := range caps {
+	for _, capability := range client.capabilities {
 		sent = client.Send(capReqMessage{capability})
 	}
 
@@ -41,8 +61,60 @@ func (client *TwitchClient) onWelcome(msg *irc.Message, tags irc.Tags) {
 		log.Fatal("Could not sent capabilities. Cannot procede.")
 	}
 
-	// signal to the outside world that now everything is set up
-	close(client.ready)
+	// do not hang forever if Twitch never answers
+	go func() {
+		select {
+		case <-time.After(capTimeout):
+			client.logger.Warning("Twitch did not answer all capability requests, continuing anyway.")
+			client.capsNegotiated(request)
+
+		case <-request.ready:
+		}
+	}()
+}
+
+// CAP * ACK :twitch.tv/tags (or NAK if Twitch refuses the capability)
+func (client *TwitchClient) onCap(msg *irc.Message, tags irc.Tags) {
+	if len(msg.Params) < 2 || (msg.Params[1] != irc.CAP_ACK && msg.Params[1] != irc.CAP_NAK) {
+		return
+	}
+
+	client.capMutex.Lock()
+	request := client.capRequest
+	client.capMutex.Unlock()
+
+	if request == nil {
+		return
+	}
+
+	for _, capability := range strings.Fields(msg.Trailing) {
+		capability = strings.TrimPrefix(capability, "twitch.tv/")
+
+		if msg.Params[1] == irc.CAP_NAK {
+			client.logger.Error("Twitch refused the %s capability.", capability)
+		}
+
+		client.capMutex.Lock()
+		delete(request.pending, capability)
+		done := len(request.pending) == 0
+		client.capMutex.Unlock()
+
+		if done {
+			client.capsNegotiated(request)
+		}
+	}
+}
+
+// capsNegotiated signals to the outside world that now everything is set up;
+// this only happens once per connection.
+func (client *TwitchClient) capsNegotiated(request *capRequest) {
+	client.capMutex.Lock()
+	defer client.capMutex.Unlock()
+
+	if client.capRequest == request {
+		client.capRequest = nil
+		close(request.ready)
+	}
 }
 
 func (client *TwitchClient) onPing(msg *irc.Message, tags irc.Tags) {
@@ -82,6 +154,29 @@ func (client *TwitchClient) onRoomState(msg *irc.Message, tags irc.Tags) {
 	client.incoming <- message
 }
 
+// MODE #channel +o user
+func (client *TwitchClient) onMode(msg *irc.Message, tags irc.Tags) {
+	if len(msg.Params) < 3 {
+		return
+	}
+
+	switch msg.Params[1] {
+	case "+o":
+		client.incoming <- ModeMessage{msg.Params[0], msg.Params[2], true}
+	case "-o":
+		client.incoming <- ModeMessage{msg.Params[0], msg.Params[2], false}
+	}
+}
+
+// USERSTATE describes the bot itself and is sent whenever it joins or speaks
+func (client *TwitchClient) onUserState(msg *irc.Message, tags irc.Tags) {
+	if len(msg.Params) < 1 {
+		return
+	}
+
+	client.incoming <- ModeMessage{msg.Params[0], client.username, Tags(tags).IsMod()}
+}
+
 func (client *TwitchClient) onPrivmsg(msg *irc.Message, tags irc.Tags) {
 	nickname := ""
 
@@ -120,7 +215,7 @@ func (client *TwitchClient) onPrivmsg(msg *irc.Message, tags irc.Tags) {
 	value, okay := tags["user-id"]
 	if okay {
 		id, err := strconv.Atoi(value)
-		if err != nil {
+		if err == nil {
 			user.ID = id
 		}
 	}
@@ -158,14 +253,54 @@ func (client *TwitchClient) onPrivmsg(msg *irc.Message, tags irc.Tags) {
 		User:    user,
 		Text:    text,
 		Action:  action,
+		Tags:    Tags(tags),
 	}
 
 	client.incoming <- message
 }
 
+// timeouts carry their duration, permanent bans do not
 func (client *TwitchClient) onClearChat(msg *irc.Message, tags irc.Tags) {
-	client.incoming <- ClearChatMessage{
+	out := ClearChatMessage{
 		Channel: msg.Params[0],
 		User:    msg.Trailing,
 	}
+
+	if duration, err := strconv.Atoi(tags["ban-duration"]); err == nil && duration > 0 {
+		out.Duration = duration
+	}
+
+	client.incoming <- out
+}
+
+func (client *TwitchClient) onClearMsg(msg *irc.Message, tags irc.Tags) {
+	client.incoming <- parseClearMsg(msg, tags)
+}
+
+func (client *TwitchClient) onWhisper(msg *irc.Message, tags irc.Tags) {
+	client.incoming <- parseWhisper(msg, tags)
+}
+
+// :user!user@user.tmi.twitch.tv WHISPER ourbot :message
+func parseWhisper(msg *irc.Message, tags irc.Tags) WhisperMessage {
+	nickname := ""
+
+	if msg.Prefix != nil {
+		nickname = msg.Prefix.User
+	}
+
+	return WhisperMessage{
+		User: nickname,
+		Text: msg.Trailing,
+		Tags: Tags(tags),
+	}
+}
+
+func (client *TwitchClient) onUserNotice(msg *irc.Message, tags irc.Tags) {
+	switch tags["msg-id"] {
+	case "sub", "resub":
+		client.incoming <- parseSubscription(msg, tags)
+	case "raid":
+		client.incoming <- parseRaid(msg, tags)
+	}
 }
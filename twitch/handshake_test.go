@@ -0,0 +1,169 @@
+package twitch
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIRCServer accepts a single connection from the real client, so that the
+// handshake can be checked line by line.
+type fakeIRCServer struct {
+	listener net.Listener
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+func newFakeIRCServer(t *testing.T) *fakeIRCServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &fakeIRCServer{listener: listener}
+}
+
+func (self *fakeIRCServer) accept(t *testing.T) {
+	conn, err := self.listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	self.conn = conn
+	self.reader = bufio.NewReader(conn)
+}
+
+func (self *fakeIRCServer) expect(t *testing.T, expected string) {
+	self.conn.SetReadDeadline(time.Now().Add(time.Second))
+
+	line, err := self.reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("expected '%s' to be sent, but got: %s", expected, err)
+	}
+
+	if strings.TrimSpace(line) != expected {
+		t.Fatalf("expected '%s' to be sent, but got '%s'.", expected, strings.TrimSpace(line))
+	}
+}
+
+func (self *fakeIRCServer) expectSilence(t *testing.T) {
+	self.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+
+	line, err := self.reader.ReadString('\n')
+	if err == nil {
+		t.Fatalf("expected nothing else to be sent, but got '%s'.", strings.TrimSpace(line))
+	}
+}
+
+func (self *fakeIRCServer) send(line string) {
+	self.conn.Write([]byte(line + "\r\n"))
+}
+
+func (self *fakeIRCServer) close() {
+	if self.conn != nil {
+		self.conn.Close()
+	}
+
+	self.listener.Close()
+}
+
+// silentLogger swallows everything, as the bot's logger cannot be used here
+type silentLogger struct{}
+
+func (silentLogger) Debug(string, ...interface{})   {}
+func (silentLogger) Info(string, ...interface{})    {}
+func (silentLogger) Warning(string, ...interface{}) {}
+func (silentLogger) Error(string, ...interface{})   {}
+func (silentLogger) Fatal(string, ...interface{})   {}
+
+func newFakeServerClient(server *fakeIRCServer, capabilities []string) *TwitchClient {
+	return NewTwitchClient(server.listener.Addr().String(), "kabukibot", "oauth:secret", 0, capabilities, silentLogger{})
+}
+
+// connectToFakeServer logs the client in and welcomes it, which makes it request its capabilities
+func connectToFakeServer(t *testing.T, server *fakeIRCServer, client *TwitchClient) {
+	err := client.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.accept(t)
+	server.expect(t, "PASS oauth:secret")
+	server.expect(t, "NICK kabukibot")
+	server.expect(t, "USER kabukibot 8 * kabukibot")
+	server.send(":tmi.twitch.tv 001 kabukibot :Welcome, GLHF!")
+}
+
+func expectReady(t *testing.T, client *TwitchClient, ready bool) {
+	select {
+	case <-client.Ready():
+		if !ready {
+			t.Fatal("expected the client to wait for Twitch to answer its capability requests.")
+		}
+
+	case <-time.After(100 * time.Millisecond):
+		if ready {
+			t.Fatal("expected the client to be ready.")
+		}
+	}
+}
+
+func TestCapabilityNegotiation(t *testing.T) {
+	server := newFakeIRCServer(t)
+	defer server.close()
+
+	client := newFakeServerClient(server, DefaultCapabilities)
+	defer client.Disconnect()
+
+	connectToFakeServer(t, server, client)
+
+	server.expect(t, "CAP REQ :twitch.tv/membership")
+	server.expect(t, "CAP REQ :twitch.tv/commands")
+	server.expect(t, "CAP REQ :twitch.tv/tags")
+
+	expectReady(t, client, false)
+
+	server.send(":tmi.twitch.tv CAP * ACK :twitch.tv/membership")
+	server.send(":tmi.twitch.tv CAP * ACK :twitch.tv/commands")
+
+	expectReady(t, client, false)
+
+	// a refused capability is an answer as well
+	server.send(":tmi.twitch.tv CAP * NAK :twitch.tv/tags")
+
+	expectReady(t, client, true)
+}
+
+func TestConfiguredCapabilities(t *testing.T) {
+	server := newFakeIRCServer(t)
+	defer server.close()
+
+	client := newFakeServerClient(server, []string{"tags", "commands"})
+	defer client.Disconnect()
+
+	connectToFakeServer(t, server, client)
+
+	server.expect(t, "CAP REQ :twitch.tv/tags")
+	server.expect(t, "CAP REQ :twitch.tv/commands")
+	server.expectSilence(t)
+
+	// Twitch may answer multiple requests at once
+	server.send(":tmi.twitch.tv CAP * ACK :twitch.tv/tags twitch.tv/commands")
+
+	expectReady(t, client, true)
+}
+
+func TestNoCapabilities(t *testing.T) {
+	server := newFakeIRCServer(t)
+	defer server.close()
+
+	client := newFakeServerClient(server, nil)
+	defer client.Disconnect()
+
+	connectToFakeServer(t, server, client)
+
+	expectReady(t, client, true)
+	server.expectSilence(t)
+}
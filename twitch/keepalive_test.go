@@ -0,0 +1,70 @@
+package twitch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPingIsAnswered(t *testing.T) {
+	server := newFakeIRCServer(t)
+	defer server.close()
+
+	client := newFakeServerClient(server, nil)
+	defer client.Disconnect()
+
+	connectToFakeServer(t, server, client)
+
+	server.send("PING :tmi.twitch.tv")
+	server.expect(t, "PONG :tmi.twitch.tv")
+}
+
+func TestIdleConnectionIsPinged(t *testing.T) {
+	server := newFakeIRCServer(t)
+	defer server.close()
+
+	client := newFakeServerClient(server, nil)
+	client.SetKeepalive(100*time.Millisecond, 100*time.Millisecond)
+	defer client.Disconnect()
+
+	connectToFakeServer(t, server, client)
+
+	server.expect(t, "PING :tmi.twitch.tv")
+	server.send(":tmi.twitch.tv PONG tmi.twitch.tv :tmi.twitch.tv")
+
+	// the answer keeps the connection alive, until the next PING
+	select {
+	case <-client.ConnectionLost():
+		t.Fatal("expected the connection to be kept alive after answering the PING.")
+	case <-time.After(150 * time.Millisecond):
+	}
+
+	server.expect(t, "PING :tmi.twitch.tv")
+}
+
+func TestMissingPongReconnects(t *testing.T) {
+	server := newFakeIRCServer(t)
+	defer server.close()
+
+	client := newFakeServerClient(server, nil)
+	client.SetKeepalive(100*time.Millisecond, 100*time.Millisecond)
+	defer client.Disconnect()
+
+	connectToFakeServer(t, server, client)
+
+	server.expect(t, "PING :tmi.twitch.tv")
+
+	select {
+	case <-client.ConnectionLost():
+	case <-time.After(time.Second):
+		t.Fatal("expected the connection to be considered lost without an answer to the PING.")
+	}
+
+	// this is what the bot does when the connection is lost
+	connectToFakeServer(t, server, client)
+
+	select {
+	case <-client.Ready():
+	case <-time.After(time.Second):
+		t.Fatal("expected the client to be ready again after reconnecting.")
+	}
+}
@@ -0,0 +1,90 @@
+package twitch
+
+import (
+	"strings"
+
+	"github.com/sorcix/irc"
+)
+
+// Tags holds the IRCv3 tags Twitch attaches to a message, e.g. "display-name" or
+// "subscriber". Values are already unescaped.
+type Tags map[string]string
+
+func (self Tags) IsMod() bool {
+	return self["mod"] == "1" || self["user-type"] == "mod"
+}
+
+func (self Tags) IsSubscriber() bool {
+	return self["subscriber"] == "1"
+}
+
+// IsVIP relies on the badges, as older messages do not carry the "vip" tag.
+func (self Tags) IsVIP() bool {
+	_, vip := self.Badges()["vip"]
+
+	return self["vip"] == "1" || vip
+}
+
+// Badges returns the user's badges and their versions, like "subscriber" => "12".
+func (self Tags) Badges() map[string]string {
+	badges := make(map[string]string)
+
+	for _, badge := range strings.Split(self["badges"], ",") {
+		parts := strings.SplitN(badge, "/", 2)
+
+		if len(parts) == 2 {
+			badges[parts[0]] = parts[1]
+		}
+	}
+
+	return badges
+}
+
+// IsFirstMessage is true for the very first message a user sends in a channel.
+func (self Tags) IsFirstMessage() bool {
+	return self["first-msg"] == "1"
+}
+
+func (self Tags) UserID() string {
+	return self["user-id"]
+}
+
+func (self Tags) DisplayName() string {
+	return self["display-name"]
+}
+
+func (self Tags) Color() string {
+	return self["color"]
+}
+
+var tagUnescaper = strings.NewReplacer(
+	`\s`, " ",
+	`\:`, ";",
+	`\\`, `\`,
+	`\r`, "\r",
+	`\n`, "\n",
+)
+
+// Parses the tag prefix of a raw IRC line, without the leading '@'
+//
+// raw is a string like "color=#0D4200;display-name=Foo\sBar;subscriber=0"
+func parseTags(raw string) irc.Tags {
+	tags := make(irc.Tags)
+
+	for _, tag := range strings.Split(raw, ";") {
+		if len(tag) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(tag, "=", 2)
+		value := ""
+
+		if len(parts) == 2 {
+			value = tagUnescaper.Replace(parts[1])
+		}
+
+		tags[parts[0]] = value
+	}
+
+	return tags
+}
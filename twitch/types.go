@@ -4,6 +4,7 @@ package twitch
 
 import (
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -15,6 +16,7 @@ type Client interface {
 	Disconnect() error
 	Incoming() <-chan IncomingMessage
 	Ready() <-chan struct{}
+	ConnectionLost() <-chan struct{}
 	QueueLen() int
 	MessagesSent() uint64
 	MessagesReceived() uint64
@@ -37,6 +39,19 @@ func (self RawMessage) IrcMessage() *irc.Message {
 	return &self.Message
 }
 
+// RawLineMessage is an incoming IRC line exactly as it was received, without
+// the line break. It is emitted right before the line is parsed, so it arrives
+// ahead of whatever the line is turned into. Only lines that concern a channel
+// are emitted.
+type RawLineMessage struct {
+	Channel string
+	Line    string
+}
+
+func (self RawLineMessage) ChannelName() string {
+	return self.Channel
+}
+
 type JoinMessage struct {
 	Channel string
 }
@@ -79,35 +94,125 @@ func (self RoomStateMessage) ChannelName() string {
 	return self.Channel
 }
 
+// ModeMessage tells that a user has been modded or unmodded in a channel. It is
+// also sent for the bot itself, whenever Twitch reports its USERSTATE.
+type ModeMessage struct {
+	Channel   string
+	User      string
+	Moderator bool // false if the moderator status has been revoked
+}
+
+func (self ModeMessage) ChannelName() string {
+	return self.Channel
+}
+
 type TextMessage struct {
 	Channel string
 	User    User
 	Text    string
 	Action  string
+	Tags    Tags
 }
 
 func (self TextMessage) ChannelName() string {
 	return self.Channel
 }
 
+// Actions ("/me") are sent as CTCP ACTIONs, which Twitch shows in the user's color.
 func (self TextMessage) IrcMessage() *irc.Message {
+	text := self.Text
+
+	if self.Action == "/me" {
+		text = "\x01ACTION " + text + "\x01"
+	}
+
 	return &irc.Message{
 		Command:  irc.PRIVMSG,
 		Params:   []string{self.Channel},
-		Trailing: self.Text,
+		Trailing: text,
 	}
 }
 
+// EmoteOccurrence is a single emote in a message. Start and End are inclusive
+// indices into the message text, counted in runes (as Twitch does), not bytes.
+type EmoteOccurrence struct {
+	ID    int
+	Start int
+	End   int
+	Code  string
+}
+
+// Emotes returns all emotes in the message in the order they appear in the text.
+func (self TextMessage) Emotes() []EmoteOccurrence {
+	runes := []rune(self.Text)
+	result := make([]EmoteOccurrence, 0)
+
+	for emoteID, markers := range self.User.Emotes {
+		for _, marker := range markers {
+			if marker.FirstChar < 0 || marker.LastChar < marker.FirstChar || marker.LastChar >= len(runes) {
+				continue
+			}
+
+			result = append(result, EmoteOccurrence{
+				ID:    emoteID,
+				Start: marker.FirstChar,
+				End:   marker.LastChar,
+				Code:  string(runes[marker.FirstChar : marker.LastChar+1]),
+			})
+		}
+	}
+
+	sort.Sort(emoteOccurrences(result))
+
+	return result
+}
+
+type emoteOccurrences []EmoteOccurrence
+
+func (a emoteOccurrences) Len() int           { return len(a) }
+func (a emoteOccurrences) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a emoteOccurrences) Less(i, j int) bool { return a[i].Start < a[j].Start }
+
+// For outgoing whispers, User is the recipient, for incoming ones the sender.
+type WhisperMessage struct {
+	User string
+	Text string
+	Tags Tags
+}
+
+// whispers are not tied to any channel
+func (self WhisperMessage) ChannelName() string {
+	return ""
+}
+
+func (self WhisperMessage) IrcMessage() *irc.Message {
+	return &irc.Message{
+		Command:  irc.PRIVMSG,
+		Params:   []string{"#jtv"},
+		Trailing: "/w " + self.User + " " + self.Text,
+	}
+}
+
+// ClearChatMessages are sent when a user has been timed out or banned, or, if
+// there is no user, when the whole chat has been cleared.
 type ClearChatMessage struct {
 	Channel  string
 	User     string
-	Duration int // TODO: use time.Duration
+	Duration int // in seconds, 0 for permanent bans; TODO: use time.Duration
 }
 
 func (self ClearChatMessage) ChannelName() string {
 	return self.Channel
 }
 
+func (self ClearChatMessage) IsTimeout() bool {
+	return self.User != "" && self.Duration > 0
+}
+
+func (self ClearChatMessage) IsBan() bool {
+	return self.User != "" && self.Duration == 0
+}
+
 func (self ClearChatMessage) IrcMessage() *irc.Message {
 	text := ""
 
@@ -128,10 +233,40 @@ func (self ClearChatMessage) IrcMessage() *irc.Message {
 	}
 }
 
+// ClearMsgMessages are sent when a single message has been deleted.
+type ClearMsgMessage struct {
+	Channel   string
+	User      string // the login of whoever sent the deleted message
+	MessageID string
+	Text      string // the deleted message
+}
+
+func (self ClearMsgMessage) ChannelName() string {
+	return self.Channel
+}
+
+func (self ClearMsgMessage) IrcMessage() *irc.Message {
+	return &irc.Message{
+		Command:  irc.PRIVMSG,
+		Params:   []string{self.Channel},
+		Trailing: ".delete " + self.MessageID,
+	}
+}
+
+func parseClearMsg(msg *irc.Message, tags irc.Tags) ClearMsgMessage {
+	return ClearMsgMessage{
+		Channel:   msg.Params[0],
+		User:      tags["login"],
+		MessageID: tags["target-msg-id"],
+		Text:      msg.Trailing,
+	}
+}
+
 type SubscriberNotificationMessage struct {
 	Channel string
 	User    string
 	Months  int
+	Plan    string // "Prime", "1000", "2000" or "3000"; empty for legacy notifications
 	Text    string
 }
 
@@ -159,7 +294,7 @@ func parseSubNotification(msg *irc.Message) SubscriberNotificationMessage {
 			out.User = match[1]
 
 			months, err := strconv.Atoi(match[2])
-			if err != nil {
+			if err == nil {
 				out.Months = months
 			}
 		}
@@ -168,6 +303,61 @@ func parseSubNotification(msg *irc.Message) SubscriberNotificationMessage {
 	return out
 }
 
+// parses a USERNOTICE with msg-id=sub or msg-id=resub; the trailing part is the
+// optional message the user attached to their resub
+func parseSubscription(msg *irc.Message, tags irc.Tags) SubscriberNotificationMessage {
+	out := SubscriberNotificationMessage{
+		Channel: msg.Params[0],
+		User:    tags["login"],
+		Months:  1,
+		Plan:    tags["msg-param-sub-plan"],
+		Text:    msg.Trailing,
+	}
+
+	// the login is what the bot works with everywhere else (ACLs, commands, ...)
+	if len(out.User) == 0 {
+		out.User = strings.ToLower(tags["display-name"])
+	}
+
+	months, okay := tags["msg-param-cumulative-months"]
+	if !okay {
+		months = tags["msg-param-months"]
+	}
+
+	if value, err := strconv.Atoi(months); err == nil && value > 0 {
+		out.Months = value
+	}
+
+	return out
+}
+
+type RaidMessage struct {
+	Channel string
+	Raider  string // the channel the raid is coming from, without '#'
+	Viewers int
+}
+
+func (self RaidMessage) ChannelName() string {
+	return self.Channel
+}
+
+func parseRaid(msg *irc.Message, tags irc.Tags) RaidMessage {
+	out := RaidMessage{
+		Channel: msg.Params[0],
+		Raider:  tags["msg-param-login"],
+	}
+
+	if len(out.Raider) == 0 {
+		out.Raider = strings.ToLower(tags["msg-param-displayName"])
+	}
+
+	if viewers, err := strconv.Atoi(tags["msg-param-viewerCount"]); err == nil {
+		out.Viewers = viewers
+	}
+
+	return out
+}
+
 type pongMessage struct {
 	Params   []string
 	Trailing string
@@ -181,6 +371,17 @@ func (self pongMessage) IrcMessage() *irc.Message {
 	}
 }
 
+type pingMessage struct {
+	Server string
+}
+
+func (self pingMessage) IrcMessage() *irc.Message {
+	return &irc.Message{
+		Command:  irc.PING,
+		Trailing: self.Server,
+	}
+}
+
 type capReqMessage struct {
 	Capability string
 }